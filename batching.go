@@ -0,0 +1,181 @@
+package k8svamprouter
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/sroze/kubernetes-vamp-router/vamprouter"
+)
+
+// A change of a route, returning whether the route has been modified.
+type RouteChange func(route *vamprouter.Route) (bool, error)
+
+// A batched change and the channel receiving its result.
+type batchedRouteChange struct {
+	change RouteChange
+	result chan error
+}
+
+// The changes of a route waiting to be sent.
+type routeBatch struct {
+	getRoute func() (*vamprouter.Route, error)
+	changes  []batchedRouteChange
+	timer    *time.Timer
+}
+
+// Batches of changes, by route name.
+type routeBatches struct {
+	mutex   sync.Mutex
+	batches map[string]*routeBatch
+}
+
+// Adds the change to the batch of the route and returns the channel receiving
+// its result. The batch is sent once nothing has been added to it for the
+// `BatchWindow` of the route manager: the changes of the objects synced at the
+// same time by the workers end up in a single update of the route instead of
+// one per object, each of them making the router reload HAProxy.
+func (rm *VampRouteManager) BatchRouteChange(routeName string, getRoute func() (*vamprouter.Route, error), change RouteChange) <-chan error {
+	batches := rm.getRouteBatches()
+	batches.mutex.Lock()
+	defer batches.mutex.Unlock()
+
+	batch, found := batches.batches[routeName]
+	if !found {
+		batch = &routeBatch{
+			getRoute: getRoute,
+		}
+
+		batches.batches[routeName] = batch
+	}
+
+	result := make(chan error, 1)
+	batch.changes = append(batch.changes, batchedRouteChange{
+		change: change,
+		result: result,
+	})
+
+	if batch.timer != nil {
+		batch.timer.Stop()
+	}
+
+	batch.timer = time.AfterFunc(rm.BatchWindow, func() {
+		rm.FlushRouteChanges(routeName)
+	})
+
+	return result
+}
+
+// Applies the batched changes to the route and sends it to the router. Each
+// change gets its own error, or the one of sending the route, so that its
+// object is synced again. Returns the first error.
+func (rm *VampRouteManager) FlushRouteChanges(routeName string) error {
+	batches := rm.getRouteBatches()
+	batches.mutex.Lock()
+	batch, found := batches.batches[routeName]
+	if !found {
+		batches.mutex.Unlock()
+
+		return nil
+	}
+
+	if batch.timer != nil {
+		batch.timer.Stop()
+	}
+
+	// The changes added from now on are sent with the next batch
+	delete(batches.batches, routeName)
+	batches.mutex.Unlock()
+
+	changes := []RouteChange{}
+	for _, batchedChange := range batch.changes {
+		changes = append(changes, batchedChange.change)
+	}
+
+	var errs []error
+	err := rm.MutateRoute(routeName, func() error {
+		errs = rm.SendRouteChangesAtOnce(routeName, batch.getRoute, changes)
+
+		return nil
+	})
+
+	if err != nil {
+		errs = make([]error, len(changes))
+		for index := range errs {
+			errs[index] = err
+		}
+	}
+
+	var firstErr error
+	for index, batchedChange := range batch.changes {
+		if errs[index] != nil && firstErr == nil {
+			firstErr = errs[index]
+		}
+
+		batchedChange.result <- errs[index]
+	}
+
+	if firstErr != nil {
+		log.Println("[error] Unable to send the changes of the route", routeName, firstErr)
+	}
+
+	return firstErr
+}
+
+// Applies the changes to a single read of the route and sends it once. Returns
+// the error of each change: the one of applying it, or else the one of sending
+// the route.
+func (rm *VampRouteManager) SendRouteChangesAtOnce(routeName string, getRoute func() (*vamprouter.Route, error), changes []RouteChange) []error {
+	errs := make([]error, len(changes))
+
+	route, err := getRoute()
+	if err != nil {
+		for index := range errs {
+			errs[index] = err
+		}
+
+		return errs
+	}
+
+	originalRoute := CopyRoute(route)
+	updated := false
+	applied := []int{}
+	for index, change := range changes {
+		changed, err := change(route)
+		if err != nil {
+			errs[index] = err
+
+			continue
+		}
+
+		applied = append(applied, index)
+		updated = updated || changed
+	}
+
+	if !updated {
+		return errs
+	}
+
+	if len(changes) == 1 {
+		err = rm.SendRouteChanges(originalRoute, route)
+	} else {
+		log.Println("Sending", len(applied), "changes of the route", routeName, "at once")
+		_, err = rm.RouterClient.UpdateRoute(route)
+	}
+
+	for _, index := range applied {
+		errs[index] = err
+	}
+
+	return errs
+}
+
+func (rm *VampRouteManager) getRouteBatches() *routeBatches {
+	rm.batchesOnce.Do(func() {
+		rm.batches = &routeBatches{
+			batches: make(map[string]*routeBatch),
+		}
+	})
+
+	return rm.batches
+}
@@ -0,0 +1,122 @@
+package k8svamprouter
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Results of the objects synced in the background.
+var backgroundSyncs []chan error
+
+func theRouteChangesAreBatchedForMilliseconds(window int) error {
+	routeManager.BatchWindow = time.Duration(window) * time.Millisecond
+
+	return nil
+}
+
+func GetBatchedRouteChangeCount(routeName string) int {
+	batches := routeManager.getRouteBatches()
+	batches.mutex.Lock()
+	defer batches.mutex.Unlock()
+
+	batch, found := batches.batches[routeName]
+	if !found {
+		return 0
+	}
+
+	return len(batch.changes)
+}
+
+// Syncs the object in the background, returning once its change of the HTTP
+// route has been batched.
+func SyncInTheBackground(sync func() error) error {
+	batchedChanges := GetBatchedRouteChangeCount(HttpRouteName)
+
+	result := make(chan error, 1)
+	backgroundSyncs = append(backgroundSyncs, result)
+	go func() {
+		result <- sync()
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for GetBatchedRouteChangeCount(HttpRouteName) <= batchedChanges {
+		if time.Now().After(deadline) {
+			return errors.New("The change of the route has not been batched")
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	return nil
+}
+
+func theKsServiceNamedIsCreatedInTheBackground(serviceName string) error {
+	service, err := repository.Get(serviceName)
+	if err != nil {
+		return err
+	}
+
+	return SyncInTheBackground(func() error {
+		return routeManager.CreateObjectRoute(service)
+	})
+}
+
+func theKsServiceNamedIsDeletedInTheBackground(serviceName string) error {
+	service, err := repository.Get(serviceName)
+	if err != nil {
+		return err
+	}
+
+	return SyncInTheBackground(func() error {
+		return routeManager.RemoveObjectRouting(service)
+	})
+}
+
+func WaitForTheBackgroundSyncs() []error {
+	errs := []error{}
+	for _, result := range backgroundSyncs {
+		select {
+		case err := <-result:
+			errs = append(errs, err)
+		case <-time.After(time.Second):
+			errs = append(errs, errors.New("The sync did not return"))
+		}
+	}
+
+	backgroundSyncs = nil
+
+	return errs
+}
+
+func theBackgroundSyncsShouldSucceed() error {
+	for _, err := range WaitForTheBackgroundSyncs() {
+		if err != nil {
+			return fmt.Errorf("A background sync failed: %s", err)
+		}
+	}
+
+	return nil
+}
+
+func theBackgroundSyncsShouldFail() error {
+	for _, err := range WaitForTheBackgroundSyncs() {
+		if err == nil {
+			return errors.New("A background sync succeeded")
+		}
+	}
+
+	return nil
+}
+
+func theBatchedChangesOfTheVampRouteAreSent(routeName string) error {
+	return routeManager.FlushRouteChanges(routeName)
+}
+
+func theBatchedChangesOfTheVampRouteCannotBeSent(routeName string) error {
+	if routeManager.FlushRouteChanges(routeName) == nil {
+		return errors.New("The batched changes have been sent")
+	}
+
+	return nil
+}
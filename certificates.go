@@ -0,0 +1,85 @@
+package k8svamprouter
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// A certificate, and its private key, used to terminate the TLS connections
+// of some hosts.
+type Certificate struct {
+	Name        string
+	Certificate []byte
+	Key         []byte
+}
+
+// Provisions the certificates for the router.
+type CertificateStore interface {
+	StoreCertificate(certificate Certificate) error
+	RemoveCertificate(name string) error
+	ListCertificateNames() ([]string, error)
+}
+
+// Stores the certificates as PEM bundles (the certificate followed by its
+// key) in a directory loaded by the router, such as the `crt` directory of
+// HAProxy.
+type DirectoryCertificateStore struct {
+	Directory string
+}
+
+func (store *DirectoryCertificateStore) StoreCertificate(certificate Certificate) error {
+	bundle := append([]byte{}, certificate.Certificate...)
+	if len(bundle) > 0 && bundle[len(bundle)-1] != '\n' {
+		bundle = append(bundle, '\n')
+	}
+
+	bundle = append(bundle, certificate.Key...)
+
+	path := store.GetCertificatePath(certificate.Name)
+	existing, err := ioutil.ReadFile(path)
+	if err == nil && string(existing) == string(bundle) {
+		return nil
+	}
+
+	// Write then rename, so that the router never loads a partial bundle
+	temporaryPath := path + ".tmp"
+	err = ioutil.WriteFile(temporaryPath, bundle, 0600)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(temporaryPath, path)
+}
+
+func (store *DirectoryCertificateStore) RemoveCertificate(name string) error {
+	err := os.Remove(store.GetCertificatePath(name))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// Returns the names of the PEM bundles of the directory, including the ones
+// not written by the controller.
+func (store *DirectoryCertificateStore) ListCertificateNames() ([]string, error) {
+	files, err := ioutil.ReadDir(store.Directory)
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, file := range files {
+		if !file.IsDir() && strings.HasSuffix(file.Name(), ".pem") {
+			names = append(names, strings.TrimSuffix(file.Name(), ".pem"))
+		}
+	}
+
+	return names, nil
+}
+
+func (store *DirectoryCertificateStore) GetCertificatePath(name string) string {
+	return filepath.Join(store.Directory, name+".pem")
+}
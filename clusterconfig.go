@@ -0,0 +1,149 @@
+package k8svamprouter
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"path/filepath"
+
+	"github.com/sroze/kubernetes-vamp-router/vamprouter"
+	rest "k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// Directory in which Kubernetes mounts the token and the CA of the service
+// account of the pods.
+var ServiceAccountDirectory = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// Returns the configuration of the client of the Kubernetes API. It comes from,
+// by order of precedence, the kubeconfig file, the address of the API, or the
+// service account of the pod the controller runs in. The token, CA and client
+// certificate files given in the configuration replace the ones it has.
+func NewClusterClientConfig(c *ControllerConfiguration, getenv func(string) string) (*rest.Config, error) {
+	var config *rest.Config
+	var err error
+	if c.Kubeconfig != "" {
+		config, err = clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+			&clientcmd.ClientConfigLoadingRules{ExplicitPath: c.Kubeconfig},
+			&clientcmd.ConfigOverrides{CurrentContext: c.KubeconfigContext},
+		).ClientConfig()
+		if err != nil {
+			return nil, fmt.Errorf("Unable to load the kubeconfig %s: %s", c.Kubeconfig, err)
+		}
+	} else if c.ClusterApiAddress != "" {
+		config = &rest.Config{}
+	} else {
+		config, err = NewInClusterClientConfig(getenv)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if c.ClusterApiAddress != "" {
+		err = setClusterApiAddress(config, c.ClusterApiAddress)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if c.ClusterApiTokenFile != "" {
+		setBearerTokenFile(config, c.ClusterApiTokenFile)
+	}
+
+	if c.ClusterApiCaFile != "" {
+		config.CAFile = c.ClusterApiCaFile
+		config.CAData = nil
+	}
+
+	if c.ClusterApiCertFile != "" {
+		config.CertFile = c.ClusterApiCertFile
+		config.CertData = nil
+		config.KeyFile = c.ClusterApiKeyFile
+		config.KeyData = nil
+	}
+
+	if c.InsecureCluster {
+		config.Insecure = true
+		config.CAFile = ""
+		config.CAData = nil
+	}
+
+	return config, nil
+}
+
+// Authenticates with the service account of the pod, whose token is read
+// again when Kubernetes rotates it.
+func NewInClusterClientConfig(getenv func(string) string) (*rest.Config, error) {
+	host, port := getenv("KUBERNETES_SERVICE_HOST"), getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New("Not running in a pod: give the kubeconfig with `KUBECONFIG` or the address of the Kubernetes API with `CLUSTER_API_ADDRESS`")
+	}
+
+	config := &rest.Config{
+		Host: "https://" + net.JoinHostPort(host, port),
+	}
+
+	config.CAFile = filepath.Join(ServiceAccountDirectory, "ca.crt")
+	setBearerTokenFile(config, filepath.Join(ServiceAccountDirectory, "token"))
+
+	return config, nil
+}
+
+// The credentials embedded in the address are sent with the basic
+// authentication, so that they do not appear with the address in the logs.
+func setClusterApiAddress(config *rest.Config, address string) error {
+	parsedAddress, err := url.Parse(address)
+	if err != nil {
+		return fmt.Errorf("Invalid address of the Kubernetes API: %s", err)
+	}
+
+	if parsedAddress.User != nil {
+		config.Username = parsedAddress.User.Username()
+		config.Password, _ = parsedAddress.User.Password()
+		config.BearerToken = ""
+		parsedAddress.User = nil
+	}
+
+	config.Host = parsedAddress.String()
+
+	return nil
+}
+
+func setBearerTokenFile(config *rest.Config, path string) {
+	authenticator := vamprouter.NewBearerTokenFile(path)
+
+	config.BearerToken = ""
+	config.Username = ""
+	config.Password = ""
+	config.WrapTransport = func(next http.RoundTripper) http.RoundTripper {
+		return &authenticatingRoundTripper{
+			Authenticator: authenticator,
+			Next:          next,
+		}
+	}
+}
+
+// Adds the credentials to the requests sent to the Kubernetes API.
+type authenticatingRoundTripper struct {
+	Authenticator vamprouter.Authenticator
+	Next          http.RoundTripper
+}
+
+func (rt *authenticatingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	// The requests must not be modified by the round trippers
+	authenticatedReq := new(http.Request)
+	*authenticatedReq = *req
+	authenticatedReq.Header = make(http.Header, len(req.Header))
+	for name, values := range req.Header {
+		authenticatedReq.Header[name] = append([]string{}, values...)
+	}
+
+	err := rt.Authenticator.Authenticate(authenticatedReq)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to authenticate on the Kubernetes API: %s", err)
+	}
+
+	return rt.Next.RoundTrip(authenticatedReq)
+}
@@ -0,0 +1,176 @@
+package k8svamprouter
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	rest "k8s.io/client-go/rest"
+)
+
+var defaultServiceAccountDirectory = ServiceAccountDirectory
+var clusterClientConfig *rest.Config
+var clusterClientConfigError error
+var clusterCredentialsDirectory string
+var clusterCredentialsWrites int
+
+func ResetClusterClientConfig() {
+	if clusterCredentialsDirectory != "" {
+		os.RemoveAll(clusterCredentialsDirectory)
+	}
+
+	ServiceAccountDirectory = defaultServiceAccountDirectory
+	clusterClientConfig = nil
+	clusterClientConfigError = nil
+	clusterCredentialsDirectory = ""
+}
+
+// Writes a file of the credentials, its modification time changing even when
+// it is written twice in a row.
+func WriteClusterCredentialsFile(name string, contents string) (string, error) {
+	if clusterCredentialsDirectory == "" {
+		directory, err := ioutil.TempDir("", "k8svamprouter-credentials")
+		if err != nil {
+			return "", err
+		}
+
+		clusterCredentialsDirectory = directory
+	}
+
+	path := filepath.Join(clusterCredentialsDirectory, name)
+	err := ioutil.WriteFile(path, []byte(contents), 0600)
+	if err != nil {
+		return "", err
+	}
+
+	clusterCredentialsWrites++
+	modificationTime := time.Now().Add(time.Duration(clusterCredentialsWrites) * time.Second)
+
+	return path, os.Chtimes(path, modificationTime, modificationTime)
+}
+
+func theControllerRunsInAPodWithTheServiceAccountToken(token string) error {
+	_, err := WriteClusterCredentialsFile("token", token)
+	if err != nil {
+		return err
+	}
+
+	_, err = WriteClusterCredentialsFile("ca.crt", "CA")
+	if err != nil {
+		return err
+	}
+
+	ServiceAccountDirectory = clusterCredentialsDirectory
+	configurationEnvironment["KUBERNETES_SERVICE_HOST"] = "10.96.0.1"
+	configurationEnvironment["KUBERNETES_SERVICE_PORT"] = "443"
+
+	return nil
+}
+
+func theServiceAccountTokenIsRotatedTo(token string) error {
+	_, err := WriteClusterCredentialsFile("token", token)
+
+	return err
+}
+
+func theTokenFileOfTheKsApiContains(token string) error {
+	path, err := WriteClusterCredentialsFile("given-token", token)
+	configurationEnvironment["CLUSTER_API_TOKEN_FILE"] = path
+
+	return err
+}
+
+func theKsClientIsConfigured() error {
+	getenv := func(name string) string {
+		return configurationEnvironment[name]
+	}
+
+	configuration, err := LoadControllerConfiguration([]string{}, getenv)
+	if err != nil {
+		return err
+	}
+
+	clusterClientConfig, clusterClientConfigError = NewClusterClientConfig(configuration, getenv)
+
+	return nil
+}
+
+func theKsApiShouldBe(expectedHost string) error {
+	if clusterClientConfigError != nil {
+		return clusterClientConfigError
+	} else if clusterClientConfig.Host != expectedHost {
+		return errors.New(fmt.Sprintf("Expected the k8s API %s, got %s", expectedHost, clusterClientConfig.Host))
+	}
+
+	return nil
+}
+
+type AuthorizationRecorder struct {
+	Authorization string
+}
+
+func (r *AuthorizationRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	r.Authorization = req.Header.Get("Authorization")
+
+	return &http.Response{StatusCode: 200, Body: ioutil.NopCloser(strings.NewReader("")), Request: req}, nil
+}
+
+func theRequestsToTheKsApiShouldHaveTheBearerToken(expectedToken string) error {
+	if clusterClientConfigError != nil {
+		return clusterClientConfigError
+	} else if clusterClientConfig.WrapTransport == nil {
+		return errors.New("The requests to the k8s API are not authenticated with a token file")
+	}
+
+	recorder := &AuthorizationRecorder{}
+	request, err := http.NewRequest("GET", clusterClientConfig.Host+"/api", nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = clusterClientConfig.WrapTransport(recorder).RoundTrip(request)
+	if err != nil {
+		return err
+	} else if recorder.Authorization != "Bearer "+expectedToken {
+		return errors.New(fmt.Sprintf("Expected the bearer token %s, got the authorization \"%s\"", expectedToken, recorder.Authorization))
+	} else if request.Header.Get("Authorization") != "" {
+		return errors.New("The original request has been modified")
+	}
+
+	return nil
+}
+
+func theKsClientShouldAuthenticateWithTheUsernameAndThePassword(username string, password string) error {
+	if clusterClientConfigError != nil {
+		return clusterClientConfigError
+	} else if clusterClientConfig.Username != username || clusterClientConfig.Password != password {
+		return errors.New(fmt.Sprintf("Expected the username %s and the password %s, got %s and %s", username, password, clusterClientConfig.Username, clusterClientConfig.Password))
+	}
+
+	return nil
+}
+
+func theCertificateOfTheKsApiShouldBeCheckedWithTheCaOfTheServiceAccount() error {
+	if clusterClientConfigError != nil {
+		return clusterClientConfigError
+	} else if expectedPath := filepath.Join(ServiceAccountDirectory, "ca.crt"); clusterClientConfig.CAFile != expectedPath {
+		return errors.New(fmt.Sprintf("Expected the CA %s, got \"%s\"", expectedPath, clusterClientConfig.CAFile))
+	}
+
+	return nil
+}
+
+func theKsClientConfigurationShouldFailBecause(reason string) error {
+	if clusterClientConfigError == nil {
+		return errors.New("The k8s client has been configured")
+	} else if !strings.Contains(clusterClientConfigError.Error(), reason) {
+		return errors.New(fmt.Sprintf("Expected the k8s client configuration to fail because %s, got: %s", reason, clusterClientConfigError))
+	}
+
+	return nil
+}
@@ -1,128 +1,284 @@
 package main
 
 import (
+	"flag"
 	"log"
+	"net"
+	"net/http"
 	"os"
+	"os/signal"
 	"sync"
+	"syscall"
+	"time"
 
-	api "k8s.io/client-go/pkg/api/v1"
 	client "k8s.io/client-go/kubernetes"
-	rest "k8s.io/client-go/rest"
-
-	"k8s.io/client-go/pkg/labels"
-	"k8s.io/client-go/pkg/fields"
-	"k8s.io/client-go/pkg/watch"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sroze/kubernetes-vamp-router/vamprouter"
 	k8svamprouter "github.com/sroze/kubernetes-vamp-router"
 )
 
 func main() {
-	client := CreateClusterClient()
+	config, err := k8svamprouter.LoadControllerConfiguration(os.Args[1:], os.Getenv)
+	if err == flag.ErrHelp {
+		os.Exit(0)
+	} else if err != nil {
+		log.Fatalln(err)
+	}
 
-	messages := make(chan int)
-	var wg sync.WaitGroup
-	wg.Add(2)
+	if config.PrintConfig {
+		config.Print(os.Stdout)
+		os.Exit(0)
+	}
 
-	go func() {
-		defer wg.Done()
+	k8svamprouter.DomainNameSeparator = config.DomainNameSeparator
 
-		if "yes" == os.Getenv("WATCH_SERVICES") {
-			WatchServices(client)
+	err = vamprouter.Register(prometheus.DefaultRegisterer)
+	if err != nil {
+		log.Fatalln("Unable to register the metrics of the Vamp Router client", err)
+	}
+
+	client := CreateClusterClient(config)
+	vampClient := CreateRouterClient(config)
+	routerClient, routeCache := CreateCachedRouterClient(config, vampClient)
+	ownership := CreateOwnershipRegistry(config, client)
+
+	// The route managers and the reconciler share the routes
+	routeLocks := k8svamprouter.NewRouteLocks()
+	reconciler := &k8svamprouter.Reconciler{
+		RouterClient: routerClient,
+		RouteLocks:   routeLocks,
+		Ownership:    ownership,
+	}
+
+	var serviceRouteManager, ingressRouteManager *k8svamprouter.VampRouteManager
+	if config.WatchServices {
+		serviceRouteManager = CreateRouteManager(config, routerClient, CreateServiceUpdater(config, client))
+		serviceRouteManager.RouteCache = routeCache
+		serviceRouteManager.RouteLocks = routeLocks
+		serviceRouteManager.Ownership = ownership
+		serviceRouteManager.TcpPortAllocator = CreateTcpPortAllocator(config)
+		if serviceRouteManager.TcpPortAllocator != nil {
+			ReserveTcpPorts(client, serviceRouteManager)
+			ReserveWeightedRoutePorts(routerClient, serviceRouteManager)
 		}
 
-		messages <- 1
-	}()
+		reconciler.Sources = append(reconciler.Sources, k8svamprouter.ReconciliationSource{
+			ObjectLister: &k8svamprouter.KubernetesServiceRepository{
+				Client: client,
+			},
+			RouteManager: serviceRouteManager,
+		})
+	}
 
-	go func() {
-		defer wg.Done()
+	if config.WatchIngresses {
+		ingressRouteManager = CreateRouteManager(config, routerClient, CreateIngressRoutingManager(config, client))
+		ingressRouteManager.RouteCache = routeCache
+		ingressRouteManager.RouteLocks = routeLocks
+		ingressRouteManager.Ownership = ownership
+		ingressRouteManager.CertificateStore = CreateCertificateStore(config)
+		reconciler.Sources = append(reconciler.Sources, k8svamprouter.ReconciliationSource{
+			ObjectLister: &k8svamprouter.KubernetesIngressRepository{
+				Client: client,
+			},
+			RouteManager: ingressRouteManager,
+		})
+	}
 
-		watchIngresses := os.Getenv("WATCH_INGRESSES")
-		if "" == watchIngresses || "yes" == watchIngresses {
-			go WatchIngresses(client)
+	health := &k8svamprouter.HealthChecker{
+		RouterClient:     routerClient,
+		RouterMaxSilence: config.RouterMaxSilence,
+		RouterResponses:  vampClient,
+	}
+
+	reconciler.Health = health
+
+	var queues []*k8svamprouter.WorkQueue
+	if serviceRouteManager != nil {
+		serviceQueue := k8svamprouter.NewWorkQueue("service", serviceRouteManager)
+		queues = append(queues, serviceQueue)
+
+		serviceWatcher := CreateServiceWatcher(client, serviceQueue)
+		health.Watchers = append(health.Watchers, serviceWatcher)
+		if config.RouteToEndpoints {
+			health.Watchers = append(health.Watchers, CreateEndpointsWatcher(client, serviceWatcher, serviceQueue))
 		}
+	}
 
-		messages <- 1
-	}()
+	if ingressRouteManager != nil {
+		ingressQueue := k8svamprouter.NewWorkQueue("ingress", ingressRouteManager)
+		queues = append(queues, ingressQueue)
 
-	wg.Wait()
-}
+		health.Watchers = append(health.Watchers, CreateIngressWatcher(client, ingressQueue))
+	}
 
-func WatchIngresses(kubernetesClient client.Interface) {
-	log.Println("Watching Kubernetes ingresses")
+	// The standby replicas queue the objects until they lead
+	elector := CreateLeaderElector(config, client)
+	if elector != nil {
+		reconciler.Leadership = elector
+		for _, routeManager := range []*k8svamprouter.VampRouteManager{serviceRouteManager, ingressRouteManager} {
+			if routeManager != nil {
+				routeManager.Leadership = elector
+			}
+		}
 
-	ingressType := os.Getenv("INGRESS_TYPE")
-	if "" == ingressType {
-		ingressType = "vamp-router"
+		for _, queue := range queues {
+			queue.Pause()
+		}
 	}
 
-	routingManager := CreateRouteManager(
-		&k8svamprouter.IngressRoutingManager{
-			KubernetesClient: kubernetesClient,
-			Configuration: k8svamprouter.IngressRoutingManagerConfiguration{
-				RootDns: os.Getenv("ROOT_DNS_DOMAIN"),
-				IngressType: ingressType,
-			},
-		},
-	)
+	for _, queue := range queues {
+		go queue.Run(config.Workers, make(chan struct{}))
+	}
 
-	channel, err := kubernetesClient.ExtensionsV1beta1().Ingresses(api.NamespaceAll).Watch(api.ListOptions{
-		LabelSelector: labels.Everything().String(),
-		FieldSelector: fields.Everything().String(),
-	})
+	go ServeMonitoring(config.MonitoringAddress, health)
 
-	if err != nil {
-		log.Fatalln("Unable to watch ingresses:", err)
+	// Catch up with what happened while we were not watching
+	reconciler.ReconcileAndLog()
+
+	var wg sync.WaitGroup
+	for _, watcher := range health.Watchers {
+		wg.Add(1)
+		go func(watcher *k8svamprouter.ObjectWatcher) {
+			defer wg.Done()
+
+			watcher.Run(make(chan struct{}))
+		}(watcher)
 	}
 
-	WatchObjects(routingManager, channel)
-}
+	if config.HttpsRedirectAddress != "" {
+		go ServeHttpsRedirects(config.HttpsRedirectAddress)
+	}
 
-func WatchServices (kubernetesClient client.Interface) {
-	log.Println("Watching Kubernetes services")
+	if config.ResyncInterval != 0 {
+		go reconciler.Run(config.ResyncInterval, make(chan struct{}))
+	}
 
-	routeManager := CreateRouteManager(
-		CreateServiceUpdater(kubernetesClient),
-	)
+	if elector != nil {
+		go RunLeaderElection(elector, reconciler, queues)
+	}
 
-	channel, err := kubernetesClient.CoreV1().Services(api.NamespaceAll).Watch(api.ListOptions{
-		LabelSelector: labels.Everything().String(),
-		FieldSelector: fields.Everything().String(),
-	})
+	wg.Wait()
+}
 
-	if err != nil {
-		log.Fatalln("Unable to watch services:", err)
+// The replicas of the controller elect their leader with a lease stored in a
+// config map. Only one replica runs when it is not configured.
+func CreateLeaderElector(config *k8svamprouter.ControllerConfiguration, kubernetesClient client.Interface) *k8svamprouter.LeaderElector {
+	if config.LeaderElectionConfigMap == "" {
+		return nil
 	}
 
-	WatchObjects(routeManager, channel)
+	namespace, name, _ := k8svamprouter.SplitNamespacedName(config.LeaderElectionConfigMap)
+
+	return &k8svamprouter.LeaderElector{
+		Lock: &k8svamprouter.ConfigMapLeaseLock{
+			Client:    kubernetesClient,
+			Namespace: namespace,
+			Name:      name,
+		},
+		Identity:      config.LeaderElectionIdentity,
+		LeaseDuration: config.LeaderElectionLeaseDuration,
+		RenewDeadline: config.LeaderElectionRenewDeadline,
+		RetryPeriod:   config.LeaderElectionRetryPeriod,
+	}
 }
 
-func WatchObjects(routeManager *k8svamprouter.VampRouteManager, channel watch.Interface) {
-	for event := range channel.ResultChan() {
-		if !routeManager.ShouldHandleObject(event.Object) {
-			continue
-		}
+// The new leader reads the routes and their ownership again and reconciles the
+// routes, until it succeeds, before syncing the objects queued while it was a
+// standby: they are applied to converged routes and do not race with the
+// reconciliation. The lease is released when the controller is stopped, for
+// another replica to take over right away.
+func RunLeaderElection(elector *k8svamprouter.LeaderElector, reconciler *k8svamprouter.Reconciler, queues []*k8svamprouter.WorkQueue) {
+	elector.OnStartedLeading = func() {
+		go func() {
+			err := reconciler.WarmUp()
+			if err != nil {
+				log.Println("[error] Unable to read the routes again before leading:", err)
+			}
+
+			for elector.IsLeader() {
+				if reconciler.ReconcileAndLog() == nil {
+					break
+				}
+
+				time.Sleep(elector.RetryPeriod)
+			}
+
+			if !elector.IsLeader() {
+				return
+			}
+
+			for _, queue := range queues {
+				queue.Resume()
+			}
+		}()
+	}
 
-		if event.Type == watch.Added || event.Type == watch.Modified {
-			routeManager.UpdateObjectRouting(event.Object)
-		} else if event.Type == watch.Deleted {
-			routeManager.RemoveObjectRouting(event.Object)
+	elector.OnStoppedLeading = func() {
+		for _, queue := range queues {
+			queue.Pause()
 		}
 	}
+
+	stop := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-signals
+		close(stop)
+	}()
+
+	elector.Run(stop)
+	os.Exit(0)
+}
+
+func CreateIngressWatcher(kubernetesClient client.Interface, handler k8svamprouter.ObjectEventHandler) *k8svamprouter.ObjectWatcher {
+	return &k8svamprouter.ObjectWatcher{
+		Name: "ingresses",
+		ListWatcher: &k8svamprouter.KubernetesIngressRepository{
+			Client: kubernetesClient,
+		},
+		Handler: handler,
+		RetryPeriod: 5 * time.Second,
+	}
+}
+
+func CreateServiceWatcher(kubernetesClient client.Interface, handler k8svamprouter.ObjectEventHandler) *k8svamprouter.ObjectWatcher {
+	return &k8svamprouter.ObjectWatcher{
+		Name: "services",
+		ListWatcher: &k8svamprouter.KubernetesServiceRepository{
+			Client: kubernetesClient,
+		},
+		Handler: handler,
+		RetryPeriod: 5 * time.Second,
+	}
 }
 
-func CreateClusterClient() client.Interface {
-	clusterAddress := os.Getenv("CLUSTER_API_ADDRESS")
-	if clusterAddress == "" {
-		log.Fatalln("You need to precise the address of Kubernetes API with the `CLUSTER_API_ADDRESS` environment variable")
+// The services of the endpoints are read from the cache of the service watcher.
+func CreateEndpointsWatcher(kubernetesClient client.Interface, serviceWatcher *k8svamprouter.ObjectWatcher, handler k8svamprouter.ObjectEventHandler) *k8svamprouter.ObjectWatcher {
+	return &k8svamprouter.ObjectWatcher{
+		Name: "endpoints",
+		ListWatcher: &k8svamprouter.KubernetesEndpointsRepository{
+			Client: kubernetesClient,
+		},
+		Handler: &k8svamprouter.EndpointsEventHandler{
+			Services: serviceWatcher,
+			Handler: handler,
+		},
+		RetryPeriod: 5 * time.Second,
 	}
+}
 
-	config := rest.Config{
-		Host: clusterAddress,
-		Insecure: os.Getenv("INSECURE_CLUSTER") == "true",
+func CreateClusterClient(config *k8svamprouter.ControllerConfiguration) client.Interface {
+	clusterConfig, err := k8svamprouter.NewClusterClientConfig(config, os.Getenv)
+	if err != nil {
+		log.Fatalln("Can't configure the client of the Kubernetes API:", err)
 	}
 
-	c, err := client.NewForConfig(&config)
+	log.Println("Using the Kubernetes API at", clusterConfig.Host)
+
+	c, err := client.NewForConfig(clusterConfig)
 	if err != nil {
 		log.Fatalln("Can't connect to Kubernetes API:", err)
 	}
@@ -130,36 +286,198 @@ func CreateClusterClient() client.Interface {
 	return c
 }
 
-func CreateRouterClient() *vamprouter.Client {
-	routerAddress := os.Getenv("ROUTER_API_ADDRESS")
-	if routerAddress == "" {
-		log.Fatalln("You need to precise the address of Vamp Router API with the `ROUTER_API_ADDRESS` environment variable")
+func CreateRouterClient(config *k8svamprouter.ControllerConfiguration) *vamprouter.Client {
+	backoff := vamprouter.DefaultBackoff
+	backoff.MaxRetries = config.RouterApiMaxRetries
+
+	routerClient := &vamprouter.Client{
+		URL: config.RouterApiAddress,
+		Timeout: config.RouterApiTimeout,
+		Backoff: &backoff,
 	}
 
-	return &vamprouter.Client{
-		URL: routerAddress,
+	routerClient.Authenticator = CreateRouterAuthenticator(config)
+	if config.RouterApiCaFile != "" || config.RouterApiCertFile != "" {
+		transport, err := vamprouter.NewTLSTransport(config.RouterApiCaFile, config.RouterApiCertFile, config.RouterApiKeyFile)
+		if err != nil {
+			log.Fatalln("Can't load the TLS files of the Vamp Router API:", err)
+		}
+
+		routerClient.HTTP = &http.Client{
+			Transport: transport,
+		}
 	}
+
+	if config.RouterCircuitBreakerFailures > 0 {
+		routerClient.CircuitBreaker = vamprouter.NewCircuitBreaker(config.RouterCircuitBreakerFailures, config.RouterCircuitBreakerReset)
+		routerClient.CircuitBreaker.OnStateChange = func(from vamprouter.CircuitState, to vamprouter.CircuitState) {
+			log.Println("The circuit to the Vamp Router went from", from, "to", to)
+		}
+	}
+
+	return routerClient
 }
 
-func CreateServiceUpdater(client client.Interface) *k8svamprouter.ServiceUpdater {
-	rootDns := os.Getenv("ROOT_DNS_DOMAIN")
-	if rootDns == "" {
-		log.Fatalln("You need to precise your root DNS name with the `ROOT_DNS_DOMAIN` environment variable")
+// The routes are cached by a single cache shared by the route managers and the
+// reconciler, as it has to see all their writes. The cache is nil when it is
+// disabled.
+func CreateCachedRouterClient(config *k8svamprouter.ControllerConfiguration, routerClient vamprouter.Interface) (vamprouter.Interface, *k8svamprouter.RouteCache) {
+	if config.RouteCacheMaxAge == 0 {
+		return routerClient, nil
 	}
 
+	routeCache := k8svamprouter.NewRouteCache(routerClient, config.RouteCacheMaxAge)
+
+	return routeCache, routeCache
+}
+
+// The entries of the shared routes created by the controller are recorded in a
+// config map, unless the setting is explicitly emptied. The config map is read
+// at once so that missing permissions stop the controller at startup rather
+// than failing every update of the routes.
+func CreateOwnershipRegistry(config *k8svamprouter.ControllerConfiguration, kubernetesClient client.Interface) *k8svamprouter.OwnershipRegistry {
+	if config.OwnershipConfigMap == "" {
+		log.Println("[error] The ownership of the entries of the shared routes is kept in memory: the routing of the objects deleted while the controller is down will never be removed, set `OWNERSHIP_CONFIG_MAP` to keep it across restarts")
+
+		return k8svamprouter.NewOwnershipRegistry(nil)
+	}
+
+	namespace, name, _ := k8svamprouter.SplitNamespacedName(config.OwnershipConfigMap)
+	ownership := k8svamprouter.NewOwnershipRegistry(&k8svamprouter.KubernetesOwnershipStore{
+		Client:    kubernetesClient,
+		Namespace: namespace,
+		Name:      name,
+	})
+
+	err := ownership.Reload()
+	if err != nil {
+		log.Fatalln("Can't read the `OWNERSHIP_CONFIG_MAP` config map, check that the controller may get, create and update it:", err)
+	}
+
+	return ownership
+}
+
+// The credentials are read from files, such as the keys of a mounted Secret,
+// so that they can be rotated without restarting the controller.
+func CreateRouterAuthenticator(config *k8svamprouter.ControllerConfiguration) vamprouter.Authenticator {
+	if config.RouterApiTokenFile != "" {
+		return vamprouter.NewBearerTokenFile(config.RouterApiTokenFile)
+	} else if config.RouterApiUsernameFile != "" {
+		return vamprouter.NewBasicAuthFiles(config.RouterApiUsernameFile, config.RouterApiPasswordFile)
+	}
+
+	return nil
+}
+
+func CreateServiceUpdater(config *k8svamprouter.ControllerConfiguration, client client.Interface) *k8svamprouter.ServiceUpdater {
 	return &k8svamprouter.ServiceUpdater{
 		ServiceRepository: &k8svamprouter.KubernetesServiceRepository{
 			Client: client,
 		},
+		EndpointsRepository: &k8svamprouter.KubernetesEndpointsRepository{
+			Client: client,
+		},
 		Configuration: k8svamprouter.Configuration{
-			RootDns: rootDns,
+			RootDns: config.RootDns,
+			EnableTcpRoutes: config.RouteTcpPorts,
+			RouteToEndpoints: config.RouteToEndpoints,
+		},
+	}
+}
+
+// Without a directory to provision their certificates in, the TLS sections of
+// the ingresses are ignored instead of failing each of them.
+func CreateIngressRoutingManager(config *k8svamprouter.ControllerConfiguration, client client.Interface) *k8svamprouter.IngressRoutingManager {
+	if config.TlsCertificatesDirectory == "" {
+		log.Println("The TLS sections of the ingresses are ignored, set `TLS_CERTIFICATES_DIRECTORY` to route their hosts through HTTPS")
+	}
+
+	return &k8svamprouter.IngressRoutingManager{
+		KubernetesClient: client,
+		Configuration: k8svamprouter.IngressRoutingManagerConfiguration{
+			RootDns: config.RootDns,
+			IngressType: config.IngressType,
+			HttpsRedirectBackend: config.HttpsRedirectAddress,
+			IgnoreTls: config.TlsCertificatesDirectory == "",
 		},
 	}
 }
 
-func CreateRouteManager(objectRoutingResolver k8svamprouter.ObjectRoutingResolver) *k8svamprouter.VampRouteManager {
+func CreateRouteManager(config *k8svamprouter.ControllerConfiguration, routerClient vamprouter.Interface, objectRoutingResolver k8svamprouter.ObjectRoutingResolver) *k8svamprouter.VampRouteManager {
 	return &k8svamprouter.VampRouteManager{
-		RouterClient: CreateRouterClient(),
+		RouterClient: routerClient,
 		ObjectRoutingResolver: objectRoutingResolver,
+		HttpsRoutePort: config.HttpsRoutePort,
+		TlsPassthroughPort: config.TlsPassthroughPort,
+		BatchWindow: config.BatchWindow,
 	}
 }
+
+func CreateCertificateStore(config *k8svamprouter.ControllerConfiguration) k8svamprouter.CertificateStore {
+	if config.TlsCertificatesDirectory == "" {
+		return nil
+	}
+
+	return &k8svamprouter.DirectoryCertificateStore{
+		Directory: config.TlsCertificatesDirectory,
+	}
+}
+
+// Serves the metrics of the controller to Prometheus on `/metrics`, and its
+// liveness and readiness to Kubernetes on `/healthz` and `/readyz`.
+func ServeMonitoring(address string, health *k8svamprouter.HealthChecker) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/healthz", health.ServeLiveness)
+	mux.HandleFunc("/readyz", health.ServeReadiness)
+
+	log.Fatalln(http.ListenAndServe(address, mux))
+}
+
+// Listens on the port of the redirect address, the router reaching the
+// controller on its host.
+func ServeHttpsRedirects(redirectAddress string) {
+	_, port, err := net.SplitHostPort(redirectAddress)
+	if err != nil {
+		log.Fatalln("The HTTPS redirect address is not a valid address:", err)
+	}
+
+	log.Fatalln(http.ListenAndServe(":"+port, &k8svamprouter.HttpsRedirectHandler{}))
+}
+
+func CreateTcpPortAllocator(config *k8svamprouter.ControllerConfiguration) *k8svamprouter.PortAllocator {
+	if config.TcpPortRange == "" {
+		return nil
+	}
+
+	portAllocator, err := k8svamprouter.ParsePortRange(config.TcpPortRange)
+	if err != nil {
+		log.Fatalln("The TCP port range is not valid:", err)
+	}
+
+	return portAllocator
+}
+
+// Reserve the router ports already allocated to the services so that they are not given to another one
+func ReserveTcpPorts(kubernetesClient client.Interface, routeManager *k8svamprouter.VampRouteManager) {
+	repository := &k8svamprouter.KubernetesServiceRepository{
+		Client: kubernetesClient,
+	}
+
+	services, _, err := repository.List()
+	if err != nil {
+		log.Fatalln("Can't list the services to reserve their TCP ports:", err)
+	}
+
+	routeManager.ReserveTcpPorts(services)
+}
+
+// Reserve the router ports of the existing weighted routes so that they are not given to another one
+func ReserveWeightedRoutePorts(routerClient vamprouter.Interface, routeManager *k8svamprouter.VampRouteManager) {
+	routes, err := routerClient.ListRoutes()
+	if err != nil {
+		log.Fatalln("Can't list the routes to reserve the ports of the weighted routes:", err)
+	}
+
+	routeManager.ReserveWeightedRoutePorts(routes)
+}
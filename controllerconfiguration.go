@@ -0,0 +1,491 @@
+package k8svamprouter
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/sroze/kubernetes-vamp-router/vamprouter"
+)
+
+// The settings of the controller. Each of them is read from, by order of
+// precedence, the `--workers` flag, the `WORKERS` environment variable and the
+// `workers` key of the YAML configuration file.
+type ControllerConfiguration struct {
+	Kubeconfig          string
+	KubeconfigContext   string
+	ClusterApiAddress   string
+	ClusterApiTokenFile string
+	ClusterApiCaFile    string
+	ClusterApiCertFile  string
+	ClusterApiKeyFile   string
+	InsecureCluster     bool
+
+	RouterApiAddress             string
+	RouterApiTimeout             time.Duration
+	RouterApiMaxRetries          int
+	RouterCircuitBreakerFailures int
+	RouterCircuitBreakerReset    time.Duration
+	RouterApiTokenFile           string
+	RouterApiUsernameFile        string
+	RouterApiPasswordFile        string
+	RouterApiCaFile              string
+	RouterApiCertFile            string
+	RouterApiKeyFile             string
+
+	RootDns             string
+	DomainNameSeparator string
+	WatchServices       bool
+	WatchIngresses      bool
+	IngressType         string
+	RouteToEndpoints    bool
+
+	ResyncInterval   time.Duration
+	Workers          int
+	BatchWindow      time.Duration
+	RouteCacheMaxAge time.Duration
+
+	OwnershipConfigMap string
+
+	MonitoringAddress string
+	RouterMaxSilence  time.Duration
+
+	LeaderElectionConfigMap     string
+	LeaderElectionIdentity      string
+	LeaderElectionLeaseDuration time.Duration
+	LeaderElectionRenewDeadline time.Duration
+	LeaderElectionRetryPeriod   time.Duration
+
+	TlsCertificatesDirectory string
+	HttpsRoutePort           int
+	TlsPassthroughPort       int
+	HttpsRedirectAddress     string
+	TcpPortRange             string
+	RouteTcpPorts            bool
+
+	// Prints the effective configuration instead of running the controller
+	PrintConfig bool
+
+	// The raw value of each setting, and where it comes from
+	values  map[string]string
+	sources map[string]string
+}
+
+type configurationSetting struct {
+	// Name of the environment variable
+	Name        string
+	Description string
+	Default     string
+
+	// Returns a pointer to the field of the setting
+	field func(c *ControllerConfiguration) interface{}
+}
+
+// Name of the flag, and key of the configuration file.
+func (s configurationSetting) Key() string {
+	return strings.Replace(strings.ToLower(s.Name), "_", "-", -1)
+}
+
+var configurationSettings = []configurationSetting{
+	{"KUBECONFIG", "Kubeconfig file giving the address of the Kubernetes cluster API and the credentials", "", func(c *ControllerConfiguration) interface{} { return &c.Kubeconfig }},
+	{"KUBECONFIG_CONTEXT", "Context of the kubeconfig file, its current context when empty", "", func(c *ControllerConfiguration) interface{} { return &c.KubeconfigContext }},
+	{"CLUSTER_API_ADDRESS", "Address of the Kubernetes cluster API, the one of the kubeconfig or of the pod when empty", "", func(c *ControllerConfiguration) interface{} { return &c.ClusterApiAddress }},
+	{"CLUSTER_API_TOKEN_FILE", "File containing the bearer token sent to the Kubernetes cluster API", "", func(c *ControllerConfiguration) interface{} { return &c.ClusterApiTokenFile }},
+	{"CLUSTER_API_CA_FILE", "PEM bundle of the authorities signing the certificate of the Kubernetes cluster API", "", func(c *ControllerConfiguration) interface{} { return &c.ClusterApiCaFile }},
+	{"CLUSTER_API_CERT_FILE", "PEM client certificate presented to the Kubernetes cluster API", "", func(c *ControllerConfiguration) interface{} { return &c.ClusterApiCertFile }},
+	{"CLUSTER_API_KEY_FILE", "PEM key of the client certificate presented to the Kubernetes cluster API", "", func(c *ControllerConfiguration) interface{} { return &c.ClusterApiKeyFile }},
+	{"INSECURE_CLUSTER", "Do not check the certificate of the Kubernetes cluster API, for development purposes only", "no", func(c *ControllerConfiguration) interface{} { return &c.InsecureCluster }},
+	{"ROUTER_API_ADDRESS", "Address of the Vamp Router API", "", func(c *ControllerConfiguration) interface{} { return &c.RouterApiAddress }},
+	{"ROUTER_API_TIMEOUT", "Deadline of each request to the Vamp Router API", vamprouter.DefaultTimeout.String(), func(c *ControllerConfiguration) interface{} { return &c.RouterApiTimeout }},
+	{"ROUTER_API_MAX_RETRIES", "Number of retries of the idempotent requests to the Vamp Router API", strconv.Itoa(vamprouter.DefaultMaxRetries), func(c *ControllerConfiguration) interface{} { return &c.RouterApiMaxRetries }},
+	{"ROUTER_CIRCUIT_BREAKER_FAILURES", "Number of consecutive failures of the router after which the requests are rejected, 0 disables the circuit breaker", "5", func(c *ControllerConfiguration) interface{} { return &c.RouterCircuitBreakerFailures }},
+	{"ROUTER_CIRCUIT_BREAKER_RESET", "Delay after which a request is let through to check if the router recovered", "30s", func(c *ControllerConfiguration) interface{} { return &c.RouterCircuitBreakerReset }},
+	{"ROUTER_API_TOKEN_FILE", "File containing the bearer token sent to the Vamp Router API", "", func(c *ControllerConfiguration) interface{} { return &c.RouterApiTokenFile }},
+	{"ROUTER_API_USERNAME_FILE", "File containing the username of the basic authentication on the Vamp Router API", "", func(c *ControllerConfiguration) interface{} { return &c.RouterApiUsernameFile }},
+	{"ROUTER_API_PASSWORD_FILE", "File containing the password of the basic authentication on the Vamp Router API", "", func(c *ControllerConfiguration) interface{} { return &c.RouterApiPasswordFile }},
+	{"ROUTER_API_CA_FILE", "PEM bundle of the authorities signing the certificate of the Vamp Router API", "", func(c *ControllerConfiguration) interface{} { return &c.RouterApiCaFile }},
+	{"ROUTER_API_CERT_FILE", "PEM client certificate presented to the Vamp Router API", "", func(c *ControllerConfiguration) interface{} { return &c.RouterApiCertFile }},
+	{"ROUTER_API_KEY_FILE", "PEM key of the client certificate presented to the Vamp Router API", "", func(c *ControllerConfiguration) interface{} { return &c.RouterApiKeyFile }},
+	{"ROOT_DNS_DOMAIN", "Root DNS domain, a wildcard to the Vamp Router, of the domain names of the services", "", func(c *ControllerConfiguration) interface{} { return &c.RootDns }},
+	{"DOMAIN_NAME_SEPARATOR", "Separator of the name and the namespace of the objects in their domain names", "-", func(c *ControllerConfiguration) interface{} { return &c.DomainNameSeparator }},
+	{"WATCH_SERVICES", "Routes the LoadBalancer services", "no", func(c *ControllerConfiguration) interface{} { return &c.WatchServices }},
+	{"WATCH_INGRESSES", "Routes the ingresses", "yes", func(c *ControllerConfiguration) interface{} { return &c.WatchIngresses }},
+	{"INGRESS_TYPE", "Type of the routed ingresses", "vamp-router", func(c *ControllerConfiguration) interface{} { return &c.IngressType }},
+	{"ROUTE_TO_ENDPOINTS", "Routes the services to the IPs of their ready pods instead of their cluster IP", "no", func(c *ControllerConfiguration) interface{} { return &c.RouteToEndpoints }},
+	{"RESYNC_INTERVAL", "Interval between two full reconciliations of the routes, 0 disables them", "5m", func(c *ControllerConfiguration) interface{} { return &c.ResyncInterval }},
+	{"WORKERS", "Number of services, and of ingresses, routed at once", "2", func(c *ControllerConfiguration) interface{} { return &c.Workers }},
+	{"BATCH_WINDOW", "The changes of the HTTP route made by the workers are sent at once when nothing changed for this duration", "200ms", func(c *ControllerConfiguration) interface{} { return &c.BatchWindow }},
+	{"ROUTE_CACHE_MAX_AGE", "Age after which the cached routes are read again from the router, 0 disables the cache", "1m", func(c *ControllerConfiguration) interface{} { return &c.RouteCacheMaxAge }},
+	{"OWNERSHIP_CONFIG_MAP", "Config map, as namespace/name, recording the entries of the shared routes created by the controller. Kept in memory only, and lost on restart, when set empty", "default/vamp-router-ownership", func(c *ControllerConfiguration) interface{} { return &c.OwnershipConfigMap }},
+	{"MONITORING_ADDRESS", "Address serving the metrics, the liveness and the readiness of the controller", ":9102", func(c *ControllerConfiguration) interface{} { return &c.MonitoringAddress }},
+	{"ROUTER_MAX_SILENCE", "The readiness check asks the Vamp Router for its routes when it did not answer for this duration", "30s", func(c *ControllerConfiguration) interface{} { return &c.RouterMaxSilence }},
+	{"LEADER_ELECTION_CONFIG_MAP", "Config map, as namespace/name, holding the lease of the leader among the replicas", "", func(c *ControllerConfiguration) interface{} { return &c.LeaderElectionConfigMap }},
+	{"LEADER_ELECTION_IDENTITY", "Identity of the replica in the lease, its hostname when empty", "", func(c *ControllerConfiguration) interface{} { return &c.LeaderElectionIdentity }},
+	{"LEADER_ELECTION_LEASE_DURATION", "Duration after which a lease that has not been renewed is taken over", "15s", func(c *ControllerConfiguration) interface{} { return &c.LeaderElectionLeaseDuration }},
+	{"LEADER_ELECTION_RENEW_DEADLINE", "The leader stops leading when it could not renew its lease for this duration", "10s", func(c *ControllerConfiguration) interface{} { return &c.LeaderElectionRenewDeadline }},
+	{"LEADER_ELECTION_RETRY_PERIOD", "Interval between two attempts to acquire or renew the lease", "2s", func(c *ControllerConfiguration) interface{} { return &c.LeaderElectionRetryPeriod }},
+	{"TLS_CERTIFICATES_DIRECTORY", "Directory, shared with the TLS termination in front of the router, in which the certificates of the ingresses are written", "", func(c *ControllerConfiguration) interface{} { return &c.TlsCertificatesDirectory }},
+	{"HTTPS_ROUTE_PORT", "Router port of the HTTP route receiving the requests decrypted by the TLS termination in front of the router", "4443", func(c *ControllerConfiguration) interface{} { return &c.HttpsRoutePort }},
+	{"TLS_PASSTHROUGH_PORT", "Router port of the TCP route passing the TLS connections through to the annotated objects", "443", func(c *ControllerConfiguration) interface{} { return &c.TlsPassthroughPort }},
+	{"HTTPS_REDIRECT_ADDRESS", "Address of the controller, as reachable from the router, on which it redirects the HTTP requests to HTTPS", "", func(c *ControllerConfiguration) interface{} { return &c.HttpsRedirectAddress }},
+	{"TCP_PORT_RANGE", "Range of router ports given to the TCP ports and to the weighted routes", "", func(c *ControllerConfiguration) interface{} { return &c.TcpPortRange }},
+	{"ROUTE_TCP_PORTS", "Routes the other TCP ports of the LoadBalancer services with TCP routes", "no", func(c *ControllerConfiguration) interface{} { return &c.RouteTcpPorts }},
+}
+
+const (
+	configurationSourceDefault     = "default"
+	configurationSourceEnvironment = "environment"
+	configurationSourceFlag        = "flag"
+)
+
+// Loads the configuration from the command line arguments, the environment
+// variables and the YAML file given by `--config` or `CONFIG_FILE`. All the
+// invalid settings are reported at once.
+func LoadControllerConfiguration(arguments []string, getenv func(string) string) (*ControllerConfiguration, error) {
+	c := &ControllerConfiguration{
+		values:  make(map[string]string),
+		sources: make(map[string]string),
+	}
+
+	for _, setting := range configurationSettings {
+		c.set(setting.Name, setting.Default, configurationSourceDefault)
+	}
+
+	flags := flag.NewFlagSet("k8svamprouter", flag.ContinueOnError)
+	configFile := flags.String("config", getenv("CONFIG_FILE"), "YAML configuration file, whose keys are the names of the flags")
+	flags.BoolVar(&c.PrintConfig, "print-config", false, "Prints the effective configuration and exits")
+
+	flagValues := make(map[string]string)
+	for _, setting := range configurationSettings {
+		flags.Var(&configurationFlag{setting: setting, values: flagValues}, setting.Key(), setting.Description)
+	}
+
+	err := flags.Parse(arguments)
+	if err != nil {
+		return nil, err
+	} else if flags.NArg() > 0 {
+		return nil, fmt.Errorf("Unexpected arguments: %s", strings.Join(flags.Args(), " "))
+	}
+
+	if *configFile != "" {
+		fileValues, err := readConfigurationFile(*configFile)
+		if err != nil {
+			return nil, err
+		}
+
+		for name, value := range fileValues {
+			c.set(name, value, *configFile)
+		}
+	}
+
+	for _, setting := range configurationSettings {
+		if value := getenv(setting.Name); value != "" {
+			c.set(setting.Name, value, configurationSourceEnvironment)
+		}
+	}
+
+	for name, value := range flagValues {
+		c.set(name, value, configurationSourceFlag)
+	}
+
+	if c.values["LEADER_ELECTION_IDENTITY"] == "" {
+		hostname, _ := os.Hostname()
+		c.set("LEADER_ELECTION_IDENTITY", hostname, configurationSourceDefault)
+	}
+
+	invalidSettings := append(c.parse(), c.validate()...)
+	if len(invalidSettings) > 0 {
+		return nil, errors.New("Invalid configuration:\n  - " + strings.Join(invalidSettings, "\n  - "))
+	}
+
+	return c, nil
+}
+
+func (c *ControllerConfiguration) set(name string, value string, source string) {
+	c.values[name] = value
+	c.sources[name] = source
+}
+
+// Reads the settings of the file, by name of environment variable.
+func readConfigurationFile(path string) (map[string]string, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read the configuration file: %s", err)
+	}
+
+	document := make(map[string]interface{})
+	err = yaml.Unmarshal(contents, &document)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid configuration file %s: %s", path, err)
+	}
+
+	settingNames := make(map[string]string)
+	for _, setting := range configurationSettings {
+		settingNames[setting.Key()] = setting.Name
+	}
+
+	values := make(map[string]string)
+	for key, value := range document {
+		name, found := settingNames[key]
+		if !found {
+			return nil, fmt.Errorf("Unknown setting `%s` in the configuration file %s", key, path)
+		}
+
+		switch typedValue := value.(type) {
+		case nil:
+			continue
+		case string:
+			values[name] = typedValue
+		case bool:
+			values[name] = strconv.FormatBool(typedValue)
+		case float64:
+			values[name] = strconv.FormatFloat(typedValue, 'f', -1, 64)
+		default:
+			return nil, fmt.Errorf("The setting `%s` of the configuration file %s must be a single value", key, path)
+		}
+	}
+
+	return values, nil
+}
+
+// Converts the raw values to the fields of the configuration.
+func (c *ControllerConfiguration) parse() []string {
+	invalidSettings := []string{}
+	for _, setting := range configurationSettings {
+		value := c.values[setting.Name]
+		invalid := func(expected string) {
+			invalidSettings = append(invalidSettings, fmt.Sprintf("`%s` (%s) must be %s, got \"%s\"", setting.Name, c.describeSource(setting.Name), expected, value))
+		}
+
+		switch field := setting.field(c).(type) {
+		case *string:
+			*field = value
+		case *bool:
+			parsed, err := ParseBool(value)
+			if err != nil {
+				invalid("`yes` or `no`")
+			}
+
+			*field = parsed
+		case *int:
+			parsed, err := strconv.Atoi(value)
+			if err != nil || parsed < 0 {
+				invalid("a positive number")
+			}
+
+			*field = parsed
+		case *time.Duration:
+			parsed, err := time.ParseDuration(value)
+			if err != nil || parsed < 0 {
+				invalid("a positive duration (`30s`, `5m`, ...)")
+			}
+
+			*field = parsed
+		}
+	}
+
+	return invalidSettings
+}
+
+// Checks the settings that depend on each other, or whose values would only
+// fail once used.
+func (c *ControllerConfiguration) validate() []string {
+	invalidSettings := []string{}
+	invalid := func(name string, message string) {
+		invalidSettings = append(invalidSettings, fmt.Sprintf("`%s` (%s) %s", name, c.describeSource(name), message))
+	}
+
+	if c.KubeconfigContext != "" && c.Kubeconfig == "" {
+		invalid("KUBECONFIG_CONTEXT", "must be given together with `KUBECONFIG`")
+	}
+
+	if (c.ClusterApiCertFile == "") != (c.ClusterApiKeyFile == "") {
+		invalid("CLUSTER_API_KEY_FILE", "must be given together with `CLUSTER_API_CERT_FILE`")
+	}
+
+	if c.InsecureCluster && c.ClusterApiCaFile != "" {
+		invalid("INSECURE_CLUSTER", "must be `no` when `CLUSTER_API_CA_FILE` is given")
+	}
+
+	if c.RouterApiAddress == "" {
+		invalid("ROUTER_API_ADDRESS", "is required: the address of the Vamp Router API")
+	} else if address, err := url.Parse(c.RouterApiAddress); err != nil || (address.Scheme != "http" && address.Scheme != "https") || address.Host == "" {
+		invalid("ROUTER_API_ADDRESS", "must be an HTTP or HTTPS URL, got \""+c.RouterApiAddress+"\"")
+	}
+
+	if (c.RouterApiUsernameFile == "") != (c.RouterApiPasswordFile == "") {
+		invalid("ROUTER_API_PASSWORD_FILE", "must be given together with `ROUTER_API_USERNAME_FILE`")
+	}
+
+	if (c.RouterApiCertFile == "") != (c.RouterApiKeyFile == "") {
+		invalid("ROUTER_API_KEY_FILE", "must be given together with `ROUTER_API_CERT_FILE`")
+	}
+
+	if c.RouterCircuitBreakerFailures > 0 && c.RouterCircuitBreakerReset == 0 {
+		invalid("ROUTER_CIRCUIT_BREAKER_RESET", "must not be 0 when the circuit breaker is enabled")
+	}
+
+	if !c.WatchServices && !c.WatchIngresses {
+		invalid("WATCH_INGRESSES", "must be `yes` when `WATCH_SERVICES` is `no`, as nothing would be routed")
+	}
+
+	if c.WatchServices && c.RootDns == "" {
+		invalid("ROOT_DNS_DOMAIN", "is required to route the services")
+	}
+
+	if c.Workers == 0 {
+		invalid("WORKERS", "must be at least 1")
+	}
+
+	if c.OwnershipConfigMap != "" {
+		if _, _, err := SplitNamespacedName(c.OwnershipConfigMap); err != nil {
+			invalid("OWNERSHIP_CONFIG_MAP", err.Error())
+		}
+	}
+
+	if c.MonitoringAddress == "" {
+		invalid("MONITORING_ADDRESS", "is required")
+	}
+
+	if c.LeaderElectionConfigMap != "" {
+		if _, _, err := SplitNamespacedName(c.LeaderElectionConfigMap); err != nil {
+			invalid("LEADER_ELECTION_CONFIG_MAP", err.Error())
+		}
+
+		if c.OwnershipConfigMap == "" {
+			invalid("LEADER_ELECTION_CONFIG_MAP", "requires `OWNERSHIP_CONFIG_MAP`, for the new leader to know the routing created by the previous one")
+		}
+
+		if c.LeaderElectionIdentity == "" {
+			invalid("LEADER_ELECTION_IDENTITY", "is required when the hostname is unknown")
+		}
+
+		if c.LeaderElectionRenewDeadline >= c.LeaderElectionLeaseDuration {
+			invalid("LEADER_ELECTION_RENEW_DEADLINE", "must be shorter than `LEADER_ELECTION_LEASE_DURATION`")
+		}
+
+		if c.LeaderElectionRetryPeriod == 0 || c.LeaderElectionRetryPeriod >= c.LeaderElectionRenewDeadline {
+			invalid("LEADER_ELECTION_RETRY_PERIOD", "must be shorter than `LEADER_ELECTION_RENEW_DEADLINE`, and not 0")
+		}
+	}
+
+	if c.HttpsRedirectAddress != "" {
+		if _, _, err := net.SplitHostPort(c.HttpsRedirectAddress); err != nil {
+			invalid("HTTPS_REDIRECT_ADDRESS", "must be an address with a port: "+err.Error())
+		}
+	}
+
+	if c.HttpsRoutePort < 1 || c.HttpsRoutePort > 65535 {
+		invalid("HTTPS_ROUTE_PORT", "must be a port number")
+	}
+
+	if c.TlsPassthroughPort < 1 || c.TlsPassthroughPort > 65535 {
+		invalid("TLS_PASSTHROUGH_PORT", "must be a port number")
+	} else if c.WatchIngresses && c.TlsCertificatesDirectory != "" && c.TlsPassthroughPort == c.HttpsRoutePort {
+		invalid("TLS_PASSTHROUGH_PORT", "must not be the `HTTPS_ROUTE_PORT` of the HTTPS route when `TLS_CERTIFICATES_DIRECTORY` is given")
+	}
+
+	if c.TcpPortRange != "" {
+		if _, err := ParsePortRange(c.TcpPortRange); err != nil {
+			invalid("TCP_PORT_RANGE", "must be a port range: "+err.Error())
+		}
+	} else if c.RouteTcpPorts {
+		invalid("ROUTE_TCP_PORTS", "must be `no` when `TCP_PORT_RANGE` is not given")
+	}
+
+	return invalidSettings
+}
+
+func (c *ControllerConfiguration) describeSource(name string) string {
+	switch source := c.sources[name]; source {
+	case configurationSourceDefault:
+		return "default value"
+	case configurationSourceEnvironment:
+		return "from the environment"
+	case configurationSourceFlag:
+		return "from the flag `--" + configurationSettingKey(name) + "`"
+	default:
+		return "from the configuration file " + source
+	}
+}
+
+func configurationSettingKey(name string) string {
+	return configurationSetting{Name: name}.Key()
+}
+
+// Writes the effective configuration as a YAML configuration file, each
+// setting commented with where it comes from. The passwords of the URLs are
+// masked.
+func (c *ControllerConfiguration) Print(w io.Writer) {
+	for _, setting := range configurationSettings {
+		value := c.values[setting.Name]
+		if address, err := url.Parse(value); err == nil && address.User != nil {
+			if _, hasPassword := address.User.Password(); hasPassword {
+				address.User = url.UserPassword(address.User.Username(), "xxxxx")
+				value = address.String()
+			}
+		}
+
+		fmt.Fprintf(w, "%s: %s # %s\n", setting.Key(), strconv.Quote(value), c.describeSource(setting.Name))
+	}
+}
+
+// Splits the `namespace/name` of an object.
+func SplitNamespacedName(value string) (string, string, error) {
+	parts := strings.Split(value, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("must be `namespace/name`, got \"%s\"", value)
+	}
+
+	return parts[0], parts[1], nil
+}
+
+// Parses `yes` and `no`, as documented, as well as `true` and `false`.
+func ParseBool(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "yes", "true", "1":
+		return true, nil
+	case "no", "false", "0":
+		return false, nil
+	}
+
+	return false, fmt.Errorf("Invalid boolean \"%s\"", value)
+}
+
+// A flag of a setting, recording its value only when given.
+type configurationFlag struct {
+	setting configurationSetting
+	values  map[string]string
+}
+
+func (f *configurationFlag) String() string {
+	if f == nil || f.values == nil {
+		return ""
+	} else if value, found := f.values[f.setting.Name]; found {
+		return value
+	}
+
+	return f.setting.Default
+}
+
+func (f *configurationFlag) Set(value string) error {
+	f.values[f.setting.Name] = value
+
+	return nil
+}
+
+// The boolean settings can be given as `--watch-services`.
+func (f *configurationFlag) IsBoolFlag() bool {
+	_, isBool := f.setting.field(&ControllerConfiguration{}).(*bool)
+
+	return isBool
+}
@@ -0,0 +1,113 @@
+package k8svamprouter
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/DATA-DOG/godog/gherkin"
+)
+
+var configurationEnvironment map[string]string
+var configurationFile string
+var loadedConfiguration *ControllerConfiguration
+var configurationError error
+
+func ResetConfiguration() {
+	if configurationFile != "" {
+		os.Remove(configurationFile)
+	}
+
+	configurationEnvironment = make(map[string]string)
+	configurationFile = ""
+	loadedConfiguration = nil
+	configurationError = nil
+}
+
+func theEnvironmentVariableIs(name string, value string) error {
+	configurationEnvironment[name] = value
+
+	return nil
+}
+
+func theConfigurationFileContains(contents *gherkin.DocString) error {
+	file, err := ioutil.TempFile("", "k8svamprouter-config")
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	configurationFile = file.Name()
+	configurationEnvironment["CONFIG_FILE"] = configurationFile
+	_, err = file.WriteString(contents.Content)
+
+	return err
+}
+
+func theControllerIsConfiguredWithTheArguments(arguments string) error {
+	loadedConfiguration, configurationError = LoadControllerConfiguration(strings.Fields(arguments), func(name string) string {
+		return configurationEnvironment[name]
+	})
+
+	return nil
+}
+
+func theControllerIsConfiguredWithoutArguments() error {
+	return theControllerIsConfiguredWithTheArguments("")
+}
+
+func theSettingShouldBe(key string, expectedValue string, expectedSource string) error {
+	if configurationError != nil {
+		return configurationError
+	}
+
+	printed := &bytes.Buffer{}
+	loadedConfiguration.Print(printed)
+
+	expectedLine := fmt.Sprintf("%s: \"%s\" # %s", key, expectedValue, expectedSource)
+	for _, line := range strings.Split(printed.String(), "\n") {
+		if strings.HasPrefix(line, key+": ") {
+			if !strings.HasPrefix(line, expectedLine) {
+				return errors.New(fmt.Sprintf("Expected the setting %s, got %s", expectedLine, line))
+			}
+
+			return nil
+		}
+	}
+
+	return errors.New(fmt.Sprintf("The setting %s is not printed", key))
+}
+
+func theConfigurationShouldBeInvalidBecause(reason string) error {
+	if configurationError == nil {
+		return errors.New("The configuration is valid")
+	} else if !strings.Contains(configurationError.Error(), reason) {
+		return errors.New(fmt.Sprintf("Expected the configuration to be invalid because %s, got: %s", reason, configurationError))
+	}
+
+	return nil
+}
+
+func theControllerShouldWatchTheKsServicesButNotTheKsIngresses() error {
+	if configurationError != nil {
+		return configurationError
+	} else if !loadedConfiguration.WatchServices || loadedConfiguration.WatchIngresses {
+		return errors.New(fmt.Sprintf("Watching the services: %t, the ingresses: %t", loadedConfiguration.WatchServices, loadedConfiguration.WatchIngresses))
+	}
+
+	return nil
+}
+
+func theCertificateOfTheKsClusterShouldNotBeChecked() error {
+	if configurationError != nil {
+		return configurationError
+	} else if !loadedConfiguration.InsecureCluster {
+		return errors.New("The certificate of the cluster is checked")
+	}
+
+	return nil
+}
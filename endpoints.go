@@ -0,0 +1,93 @@
+package k8svamprouter
+
+import (
+	"fmt"
+	"log"
+
+	api "k8s.io/client-go/pkg/api/v1"
+)
+
+// Gives the endpoints of the services by namespace and name.
+type EndpointsRepository interface {
+	GetEndpoints(namespace string, name string) (*api.Endpoints, error)
+}
+
+// Gives the last seen version of the watched objects by key.
+type ObjectCache interface {
+	GetObject(key string) (KubernetesBackendObject, bool)
+}
+
+// Routes again the service of the endpoints that changed, so that its
+// backends follow the pods as they come and go. The services are read from
+// the cache of their watcher: the endpoints of the objects that are not
+// watched services, such as the leader election records of the cluster, are
+// ignored without requesting the API.
+type EndpointsEventHandler struct {
+	Services ObjectCache
+	Handler  ObjectEventHandler
+}
+
+func (h *EndpointsEventHandler) OnObjectUpdated(object KubernetesBackendObject) {
+	h.RouteService(object)
+}
+
+// The endpoints are deleted with their service, whose deletion is handled on
+// its own. When the service still exists, it is routed to no endpoint.
+func (h *EndpointsEventHandler) OnObjectDeleted(object KubernetesBackendObject) {
+	h.RouteService(object)
+}
+
+func (h *EndpointsEventHandler) RouteService(object KubernetesBackendObject) {
+	endpoints, ok := object.(*api.Endpoints)
+	if !ok {
+		log.Println("[error] Get get only from `Endpoints` objects")
+
+		return
+	}
+
+	key, err := GetObjectKey(endpoints)
+	if err != nil {
+		log.Println("[error] Unable to get the key of the endpoints", endpoints.ObjectMeta.Name, err)
+
+		return
+	}
+
+	service, found := h.Services.GetObject(key)
+	if !found {
+		return
+	}
+
+	h.Handler.OnObjectUpdated(service)
+}
+
+// Returns the ready addresses of the endpoints, with their port matching the
+// given service port.
+func GetEndpointsOfPort(endpoints *api.Endpoints, servicePort api.ServicePort) []Endpoint {
+	result := []Endpoint{}
+	for _, subset := range endpoints.Subsets {
+		port, err := GetEndpointPort(subset, servicePort)
+		if err != nil {
+			continue
+		}
+
+		for _, address := range subset.Addresses {
+			result = append(result, Endpoint{
+				Address: address.IP,
+				Port:    int(port.Port),
+			})
+		}
+	}
+
+	return result
+}
+
+// The ports of the endpoints are named after the ports of their service.
+func GetEndpointPort(subset api.EndpointSubset, servicePort api.ServicePort) (*api.EndpointPort, error) {
+	for index, port := range subset.Ports {
+		if port.Name == servicePort.Name {
+			return &subset.Ports[index], nil
+		}
+	}
+
+	return nil, fmt.Errorf("No endpoint port named %s", servicePort.Name)
+}
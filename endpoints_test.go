@@ -0,0 +1,182 @@
+package k8svamprouter
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/DATA-DOG/godog/gherkin"
+	api "k8s.io/client-go/pkg/api/v1"
+)
+
+type InMemoryEndpointsRepository struct {
+	Endpoints map[string]*api.Endpoints
+}
+
+func (endpointsRepository *InMemoryEndpointsRepository) GetEndpoints(namespace string, name string) (*api.Endpoints, error) {
+	endpoints, found := endpointsRepository.Endpoints[name]
+	if !found {
+		return nil, errors.New("Endpoints do not exist")
+	}
+
+	return endpoints, nil
+}
+
+var endpointsRepository *InMemoryEndpointsRepository
+
+// The watched services are the ones of the in-memory service repository.
+type InMemoryServiceCache struct{}
+
+func (cache *InMemoryServiceCache) GetObject(key string) (KubernetesBackendObject, bool) {
+	parts := strings.SplitN(key, "/", 2)
+	service, err := repository.Get(parts[len(parts)-1])
+	if err != nil {
+		return nil, false
+	}
+
+	return service, true
+}
+
+func GetOrCreateEndpoints(serviceName string) *api.Endpoints {
+	endpoints, found := endpointsRepository.Endpoints[serviceName]
+	if !found {
+		endpoints = &api.Endpoints{
+			ObjectMeta: api.ObjectMeta{
+				Name: serviceName,
+			},
+		}
+
+		endpointsRepository.Endpoints[serviceName] = endpoints
+	}
+
+	return endpoints
+}
+
+// FEATURES
+func routingToTheEndpointsIsEnabled() error {
+	endpointsRepository = &InMemoryEndpointsRepository{
+		Endpoints: make(map[string]*api.Endpoints),
+	}
+
+	serviceUpdater := routeManager.ObjectRoutingResolver.(*ServiceUpdater)
+	serviceUpdater.Configuration.RouteToEndpoints = true
+	serviceUpdater.EndpointsRepository = endpointsRepository
+
+	return nil
+}
+
+func theKsServiceIsHeadless(serviceName string) error {
+	service := GetOrCreateService(repository, serviceName)
+	service.Spec.Type = api.ServiceTypeClusterIP
+	service.Spec.ClusterIP = api.ClusterIPNone
+
+	_, err := repository.Update(service)
+
+	return err
+}
+
+func theKsServiceHasTheReadyEndpointOnThePortNamed(serviceName string, address string, port int, portName string) error {
+	endpoints := GetOrCreateEndpoints(serviceName)
+	endpoints.Subsets = append(endpoints.Subsets, api.EndpointSubset{
+		Addresses: []api.EndpointAddress{
+			api.EndpointAddress{IP: address},
+		},
+		Ports: []api.EndpointPort{
+			api.EndpointPort{Name: portName, Port: int32(port)},
+		},
+	})
+
+	return nil
+}
+
+func theKsServiceHasTheNotReadyEndpointOnThePortNamed(serviceName string, address string, port int, portName string) error {
+	endpoints := GetOrCreateEndpoints(serviceName)
+	endpoints.Subsets = append(endpoints.Subsets, api.EndpointSubset{
+		NotReadyAddresses: []api.EndpointAddress{
+			api.EndpointAddress{IP: address},
+		},
+		Ports: []api.EndpointPort{
+			api.EndpointPort{Name: portName, Port: int32(port)},
+		},
+	})
+
+	return nil
+}
+
+func theKsServiceLosesTheEndpoint(serviceName string, address string) error {
+	endpoints := GetOrCreateEndpoints(serviceName)
+
+	subsets := []api.EndpointSubset{}
+	for _, subset := range endpoints.Subsets {
+		if len(subset.Addresses) == 0 || subset.Addresses[0].IP != address {
+			subsets = append(subsets, subset)
+		}
+	}
+
+	endpoints.Subsets = subsets
+
+	return nil
+}
+
+func theEndpointsOfTheKsServiceAreUpdated(serviceName string) error {
+	handler := &EndpointsEventHandler{
+		Services: &InMemoryServiceCache{},
+		Handler:  routeManager,
+	}
+
+	handler.OnObjectUpdated(GetOrCreateEndpoints(serviceName))
+
+	return nil
+}
+
+func theEndpointsInTheNamespaceAreUpdated(name string, namespace string) error {
+	handler := &EndpointsEventHandler{
+		Services: &InMemoryServiceCache{},
+		Handler:  routeManager,
+	}
+
+	handler.OnObjectUpdated(&api.Endpoints{
+		ObjectMeta: api.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+	})
+
+	return nil
+}
+
+func theVampServiceShouldContainTheServers(serviceName string, serversTable *gherkin.DataTable) error {
+	route, err := routeManager.RouterClient.GetRoute("http")
+	if err != nil {
+		return err
+	}
+
+	service, err := GetCreatedServiceInRoute(route, serviceName)
+	if err != nil {
+		return err
+	}
+
+	if len(service.Servers) != len(serversTable.Rows)-1 {
+		return errors.New(fmt.Sprintf("Expected %d servers, found %d", len(serversTable.Rows)-1, len(service.Servers)))
+	}
+
+	for i, row := range serversTable.Rows {
+		if i == 0 {
+			// Skip the headers
+			continue
+		}
+
+		found := false
+		for _, server := range service.Servers {
+			if server.Host == row.Cells[0].Value && fmt.Sprintf("%d", server.Port) == row.Cells[1].Value {
+				found = true
+			}
+		}
+
+		if !found {
+			return errors.New(fmt.Sprintf("The server %s:%s was not found", row.Cells[0].Value, row.Cells[1].Value))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,60 @@
+Feature:
+  In order to route my applications that do not listen on the port 80
+  As a developer
+  I want the Vamp backends to use the ports of my k8s services and ingresses
+
+  Background:
+    Given a vamp route named "http" already exists
+    And the k8s service "app" is in the namespace "qwerty"
+    And the k8s service "app" IP is "1.2.3.4"
+
+  Scenario: Routes the service port 80
+    Given the k8s service "app" is a load-balancer exposing the port 80
+    When the k8s service named "app" is created
+    Then the vamp service "app-qwerty" should only contain the backend "1.2.3.4" on the port 80
+
+  Scenario: Routes the service port named "http"
+    Given the k8s service "app" is a load-balancer exposing the port 9000
+    And the k8s service "app" exposes the port 8080 named "http"
+    When the k8s service named "app" is created
+    Then the k8s service "app" should be handled
+    And the vamp service "app-qwerty" should only contain the backend "1.2.3.4" on the port 8080
+
+  Scenario: Routes the service port given by the annotation
+    Given the k8s service "app" is a load-balancer exposing the port 80
+    And the k8s service "app" exposes the port 3000 named "web"
+    And the k8s service "app" has the following annotations:
+      | name                  | value |
+      | vamp-router/http-port | web   |
+    When the k8s service named "app" is created
+    Then the k8s service "app" should be handled
+    And the vamp service "app-qwerty" should only contain the backend "1.2.3.4" on the port 3000
+
+  Scenario: Updates the backend when the port changes
+    Given the k8s service "app" is a load-balancer exposing the port 80
+    And the k8s service "app" exposes the port 3000 named "web"
+    And the k8s service named "app" is created
+    And the k8s service "app" has the following annotations:
+      | name                  | value |
+      | vamp-router/http-port | 3000  |
+    When the k8s service named "app" is updated
+    Then the vamp service "app-qwerty" should only contain the backend "1.2.3.4" on the port 3000
+
+  Scenario: Ignores the services without HTTP port
+    Given the k8s service "app" is a load-balancer exposing the port 5432
+    Then the k8s service "app" should not be handled
+
+  Scenario: Routes the port of the ingress backend
+    Given the k8s ingress "web" is in the namespace "qwerty"
+    And the k8s ingress "web" has the default backend "web" on the port "8080"
+    When the k8s ingress named "web" is created
+    Then the vamp service "web-qwerty" should only contain the backend "web.qwerty.svc.cluster.local" on the port 8080
+
+  Scenario: Resolves the named port of the ingress backend through the service
+    Given the k8s service "api" in the namespace "qwerty" exposes the port 3000 named "web"
+    And the k8s ingress "web" is in the namespace "qwerty"
+    And the k8s ingress "web" has the following rules:
+      | host        | path | service | port |
+      | example.com |      | api     | web  |
+    When the k8s ingress named "web" is created
+    Then the vamp service "web-qwerty-api-web" should only contain the backend "api.qwerty.svc.cluster.local" on the port 3000
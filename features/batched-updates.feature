@@ -0,0 +1,49 @@
+Feature:
+  In order to not reload the router for each of the many objects changed at once
+  As an operator
+  I want the changes of the Vamp route to be sent in a single update
+
+  Background:
+    Given a vamp route named "http" already exists
+    And the route changes are batched for 50 milliseconds
+    And the k8s service "app" is in the namespace "qwerty"
+    And the k8s service "app" IP is "1.2.3.4"
+    And the k8s service "app" is a load-balancer exposing the port 80
+    And the k8s service "other" is in the namespace "qwerty"
+    And the k8s service "other" IP is "2.3.4.5"
+    And the k8s service "other" is a load-balancer exposing the port 80
+
+  Scenario: Sends the changes of the objects synced at the same time at once
+    When the k8s service named "app" is created in the background
+    And the k8s service named "other" is created in the background
+    Then the vamp route should not be updated
+    When 200 milliseconds have passed
+    Then the background syncs should succeed
+    And the vamp route should be updated once
+    And the vamp service "app-qwerty" should only contain the backend "1.2.3.4"
+    And the vamp service "other-qwerty" should only contain the backend "2.3.4.5"
+
+  Scenario: Applies the changes in their order
+    When the k8s service named "app" is created in the background
+    And the k8s service named "other" is created in the background
+    And the k8s service named "app" is deleted in the background
+    And the batched changes of the vamp route "http" are sent
+    Then the background syncs should succeed
+    And the vamp route should be updated once
+    And the vamp service "app-qwerty" should not exist
+    And the vamp filter named "app-qwerty.example.com" should not exist
+    And the vamp filter named "other-qwerty.example.com" should be created
+
+  Scenario: Waits for the changes to be sent
+    When the k8s service named "app" is created
+    Then the vamp route should be updated once
+    And the vamp service "app-qwerty" should only contain the backend "1.2.3.4"
+
+  Scenario: Fails the syncs whose changes could not be sent, so that they are retried
+    Given the vamp router fails with the status 500
+    When the k8s service named "app" is created in the background
+    Then the batched changes of the vamp route "http" cannot be sent
+    And the background syncs should fail
+    When the vamp router recovers
+    And the k8s service named "app" is created
+    Then the vamp service "app-qwerty" should only contain the backend "1.2.3.4"
@@ -0,0 +1,13 @@
+Feature:
+  In order to not lose the routing of an object because of another one
+  As an operator
+  I want the concurrent updates of the shared Vamp route to all be applied
+
+  Background:
+    Given a vamp route named "http" already exists
+    And the vamp router takes 1 millisecond to answer
+
+  Scenario: Serializes the updates of the route
+    When 20 k8s services and 20 k8s ingresses are created concurrently
+    Then the vamp route "http" should have 40 filters and 40 services
+
@@ -9,5 +9,6 @@ Feature:
       | name                   | value                                              |
       | kubernetesReverseproxy | {"hosts": [{"host": "example.com", "port": "80"}]} |
     And the k8s service "app" IP is "1.2.3.4"
+    And the k8s service "app" is a load-balancer exposing the port 80
     When the k8s service named "app" is created
     And the vamp filter named "example.com" should be created
@@ -0,0 +1,60 @@
+Feature:
+  In order to route the traffic without going through kube-proxy
+  As an operator
+  I want the Vamp services to be routed to the ready pods of the k8s services
+
+  Background:
+    Given a vamp route named "http" already exists
+    And routing to the endpoints is enabled
+    And the k8s service "web" is in the namespace "qwerty"
+    And the k8s service "web" IP is "1.2.3.4"
+    And the k8s service "web" is a load-balancer exposing the port 5000
+    And the k8s service "web" exposes the port 80 named "http"
+    And the k8s service "web" has the ready endpoint "10.0.0.1" on the port 8080 named "http"
+    And the k8s service "web" has the ready endpoint "10.0.0.2" on the port 8080 named "http"
+
+  Scenario: Routes to each ready endpoint
+    Given the k8s service "web" has the not ready endpoint "10.0.0.3" on the port 8080 named "http"
+    And the k8s service "web" has the ready endpoint "10.0.0.4" on the port 5000 named ""
+    When the k8s service named "web" is created
+    Then the vamp service "web-qwerty" should contain the servers:
+      | host     | port |
+      | 10.0.0.1 | 8080 |
+      | 10.0.0.2 | 8080 |
+
+  Scenario: Follows the endpoints as the pods come and go
+    Given the k8s service named "web" is created
+    And the k8s service "web" loses the endpoint "10.0.0.1"
+    And the k8s service "web" has the ready endpoint "10.0.0.5" on the port 8080 named "http"
+    When the endpoints of the k8s service "web" are updated
+    Then the vamp service "web-qwerty" should contain the servers:
+      | host     | port |
+      | 10.0.0.2 | 8080 |
+      | 10.0.0.5 | 8080 |
+
+  Scenario: Does not update the route when the endpoints did not change
+    Given the k8s service named "web" is created
+    And the vamp route should be updated once
+    When the endpoints of the k8s service "web" are updated
+    Then the vamp route should not be updated
+
+  Scenario: Routes the exposed headless services
+    Given the k8s service "web" is headless
+    And the k8s service "web" has the following annotations:
+      | name               | value |
+      | vamp-router/expose | true  |
+    When the k8s service named "web" is created
+    Then the vamp service "web-qwerty" should contain the servers:
+      | host     | port |
+      | 10.0.0.1 | 8080 |
+      | 10.0.0.2 | 8080 |
+
+  Scenario: Ignores the headless services that are not exposed
+    Given the k8s service "web" is headless
+    Then the k8s service "web" should not be handled
+
+  Scenario: Ignores the endpoints that are not the ones of a watched service
+    Given the k8s service named "web" is created
+    And the vamp route should be updated once
+    When the endpoints "kube-scheduler" in the namespace "kube-system" are updated
+    Then the vamp route should not be updated
@@ -0,0 +1,51 @@
+Feature:
+  In order to not route a host to a backend it does not belong to anymore
+  As an operator
+  I want the filters of the Vamp route to follow the changes of the objects
+
+  Background:
+    Given a vamp route named "http" already exists
+    And the k8s service "app" is in the namespace "qwerty"
+    And the k8s service "app" IP is "1.2.3.4"
+    And the k8s service "app" is a load-balancer exposing the port 80
+
+  Scenario: Updates the filter routing to another backend
+    Given the vamp route "http" routes "app-qwerty.example.com" to the backend "old-app" for the k8s service "old-app"
+    When the k8s service named "app" is created
+    Then the vamp filter named "app-qwerty.example.com" of the vamp route "http" should route to the vamp service "app-qwerty"
+
+  Scenario: Updates the filter having an outdated condition
+    Given the vamp route "http" routes "app-qwerty.example.com" to the backend "app-qwerty"
+    And the vamp filter named "app-qwerty.example.com" of the vamp route "http" has the condition "hdr(Host) -i old.example.com"
+    When the k8s service named "app" is created
+    Then the vamp filter named "app-qwerty.example.com" of the vamp route "http" should have the condition "hdr_reg(host) -i ^app-qwerty\.example\.com(:[0-9]+)?$"
+
+  Scenario: Removes the filters of the domain names the object does not have anymore
+    Given the k8s service "app" has the following annotations:
+      | name                   | value                                                                                 |
+      | kubernetesReverseproxy | {"hosts": [{"host": "example.com", "port": "80"}, {"host": "other.com", "port": "80"}]} |
+    And the k8s service named "app" is created
+    When the k8s service "app" has the following annotations:
+      | name                   | value                                              |
+      | kubernetesReverseproxy | {"hosts": [{"host": "example.com", "port": "80"}]} |
+    And the k8s service named "app" is updated
+    Then the vamp filter named "example.com" of the vamp route "http" should route to the vamp service "app-qwerty"
+    And the vamp filter named "other.com" of the vamp route "http" should not exist
+
+  Scenario: Keeps the filters of the other backends
+    Given the vamp route "http" routes "ghost.example.com" to the backend "ghost-qwerty"
+    When the k8s service named "app" is created
+    Then the vamp filter named "ghost.example.com" of the vamp route "http" should route to the vamp service "ghost-qwerty"
+    And the vamp filter named "app-qwerty.example.com" of the vamp route "http" should route to the vamp service "app-qwerty"
+
+  Scenario: Converges the cached route
+    Given the vamp routes are cached for 60000 milliseconds
+    And the k8s service "app" has the following annotations:
+      | name                   | value                                                                                 |
+      | kubernetesReverseproxy | {"hosts": [{"host": "example.com", "port": "80"}, {"host": "other.com", "port": "80"}]} |
+    And the k8s service named "app" is created
+    When the k8s service "app" has the following annotations:
+      | name                   | value                                              |
+      | kubernetesReverseproxy | {"hosts": [{"host": "example.com", "port": "80"}]} |
+    And the k8s service named "app" is updated
+    Then the vamp filter named "other.com" of the vamp route "http" should not exist
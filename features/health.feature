@@ -0,0 +1,55 @@
+Feature:
+  In order to have Kubernetes restart or stop sending traffic to a broken controller
+  As an operator
+  I want the controller to tell whether it is alive and ready
+
+  Background:
+    Given a vamp route named "http" already exists
+    And the k8s service "app" is in the namespace "qwerty"
+    And the k8s service "app" IP is "1.2.3.4"
+    And the k8s service "app" is a load-balancer exposing the port 80
+    And the k8s services are listed at the resource version "10"
+    And the health of the controller is checked with a router silence of 10 milliseconds
+
+  Scenario: Is ready once the routes are reconciled and the objects listed
+    When the k8s services are watched
+    Then the controller should not be ready
+    When the routes are reconciled at startup
+    Then the controller should be ready
+    And the controller should be alive
+
+  Scenario: Is not ready before the objects are listed
+    When the routes are reconciled at startup
+    Then the controller should not be ready
+
+  Scenario: Is not ready while the router does not answer
+    Given the k8s services are watched
+    And the routes are reconciled at startup
+    When the vamp router fails with the status 503
+    And 20 milliseconds have passed
+    Then the controller should not be ready
+    When the vamp router recovers
+    Then the controller should be ready
+
+  Scenario: Is not ready when the routes cannot be reconciled
+    Given the k8s services are watched
+    And the vamp router fails with the status 503
+    When the routes are reconciled at startup
+    And the vamp router recovers
+    Then the controller should not be ready
+
+  Scenario: Is not alive when the watch keeps failing
+    Given the watcher of the k8s services is dead after 10 milliseconds
+    And the watch of the k8s services will fail because the resource version is too old
+    When the k8s services are watched
+    Then the controller should be alive
+    When 20 milliseconds have passed
+    Then the controller should not be alive
+    When the k8s services are watched
+    Then the controller should be alive
+
+  Scenario: Is not alive when the events are not handled anymore
+    Given the watcher of the k8s services is dead after 10 milliseconds
+    When the handling of the k8s services is stuck
+    And 20 milliseconds have passed
+    Then the controller should not be alive
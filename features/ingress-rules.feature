@@ -0,0 +1,87 @@
+Feature:
+  In order to expose my applications the way I describe them in my ingresses
+  As a developer
+  I want the hosts and paths of the ingress rules to be routed by the Vamp router
+
+  Background:
+    Given a vamp route named "http" already exists
+    And the k8s ingress "web" is in the namespace "qwerty"
+
+  Scenario: Routes the default backend with the generated domain name
+    Given the k8s ingress "web" has the default backend "web" on the port "80"
+    When the k8s ingress named "web" is created
+    Then the vamp filter named "web-qwerty.example.com" should route to the vamp service "web-qwerty"
+    And the vamp service "web-qwerty" should only contain the backend "web.qwerty.svc.cluster.local"
+
+  Scenario: Routes each host to its backend
+    Given the k8s ingress "web" has the following rules:
+      | host            | path | service | port |
+      | example.com     |      | web     | 80   |
+      | api.example.com |      | api     | 80   |
+    When the k8s ingress named "web" is created
+    Then the vamp filter named "example.com" should have the condition "hdr_reg(host) -i ^example\.com(:[0-9]+)?$"
+    And the vamp filter named "example.com" should route to the vamp service "web-qwerty-web-80"
+    And the vamp filter named "api.example.com" should route to the vamp service "web-qwerty-api-80"
+    And the vamp service "web-qwerty-web-80" should only contain the backend "web.qwerty.svc.cluster.local"
+    And the vamp service "web-qwerty-api-80" should only contain the backend "api.qwerty.svc.cluster.local"
+
+  Scenario: Routes the paths of a host before the host itself
+    Given the k8s ingress "web" has the following rules:
+      | host        | path | service | port |
+      | example.com | /    | web     | 80   |
+      | example.com | /api | api     | 80   |
+    When the k8s ingress named "web" is created
+    Then the vamp filter named "example.com-api" should have the condition "base_reg -i ^example\.com(:[0-9]+)?/api(/|$)"
+    And the vamp filter named "example.com-api" should route to the vamp service "web-qwerty-api-80"
+    And the vamp filter named "example.com" should route to the vamp service "web-qwerty-web-80"
+    And the vamp filter named "example.com-api" should be before the vamp filter named "example.com"
+
+  Scenario: Matches the Host header with a port
+    Given the k8s ingress "web" has the following rules:
+      | host        | path | service | port |
+      | example.com | /    | web     | 80   |
+      | example.com | /api | api     | 80   |
+    When the k8s ingress named "web" is created
+    Then the request to "example.com:8080/api/users" should be routed to the vamp service "web-qwerty-api-80"
+    And the request to "example.com:8080/" should be routed to the vamp service "web-qwerty-web-80"
+    And the request to "example.com.evil.com/" should not be routed
+
+  Scenario: Matches the paths on whole segments
+    Given the k8s ingress "web" has the following rules:
+      | host        | path | service | port |
+      | example.com | /    | web     | 80   |
+      | example.com | /api | api     | 80   |
+    When the k8s ingress named "web" is created
+    Then the request to "example.com/api" should be routed to the vamp service "web-qwerty-api-80"
+    And the request to "example.com/api/users" should be routed to the vamp service "web-qwerty-api-80"
+    And the request to "example.com/apiv2" should be routed to the vamp service "web-qwerty-web-80"
+
+  Scenario: Routes the rules without host with the generated domain name
+    Given the k8s ingress "web" has the following rules:
+      | host | path | service | port |
+      |      | /api | api     | 80   |
+    When the k8s ingress named "web" is created
+    Then the vamp filter named "web-qwerty.example.com-api" should have the condition "base_reg -i ^web-qwerty\.example\.com(:[0-9]+)?/api(/|$)"
+
+  Scenario: Shares the default backend with the rules
+    Given the k8s ingress "web" has the default backend "web" on the port "80"
+    And the k8s ingress "web" has the following rules:
+      | host        | path | service | port |
+      | example.com |      | web     | 80   |
+    When the k8s ingress named "web" is created
+    Then the vamp filter named "example.com" should route to the vamp service "web-qwerty"
+    And the vamp service "web-qwerty-web-80" should not exist
+
+  Scenario: Removes all the backends of a deleted ingress
+    Given the k8s ingress "web" has the default backend "web" on the port "80"
+    And the k8s ingress "web" has the following rules:
+      | host        | path | service | port |
+      | example.com | /    | web     | 80   |
+      | example.com | /api | api     | 80   |
+    When the k8s ingress named "web" is created
+    And the k8s ingress named "web" is deleted
+    Then the vamp service "web-qwerty" should not exist
+    And the vamp service "web-qwerty-api-80" should not exist
+    And the vamp filter named "example.com" should not exist
+    And the vamp filter named "example.com-api" should not exist
+    And the vamp filter named "web-qwerty.example.com" should not exist
@@ -0,0 +1,70 @@
+Feature:
+  In order to run several replicas of the controller without them racing on the shared routes
+  As an operator
+  I want only the elected leader to change the routes, the other replicas being ready to take over
+
+  Background:
+    Given a vamp route named "http" already exists
+    And the k8s service "app" is in the namespace "qwerty"
+    And the k8s service "app" IP is "1.2.3.4"
+    And the k8s service "app" is a load-balancer exposing the port 80
+    And the replicas "a" and "b" compete for a lease of 90 milliseconds
+
+  Scenario: Elects a single leader
+    When the replica "a" tries to acquire the lease
+    And the replica "b" tries to acquire the lease
+    Then the replica "a" should lead
+    And the replica "b" should not lead
+
+  Scenario: Keeps the lead while renewing the lease
+    Given the replica "a" tries to acquire the lease
+    And the replica "b" tries to acquire the lease
+    When 45 milliseconds have passed
+    And the replica "a" tries to acquire the lease
+    And 50 milliseconds have passed
+    And the replica "b" tries to acquire the lease
+    Then the replica "a" should lead
+    And the replica "b" should not lead
+
+  Scenario: Takes over once the lease expired
+    Given the replica "a" tries to acquire the lease
+    And the replica "b" tries to acquire the lease
+    When 100 milliseconds have passed
+    And the replica "b" tries to acquire the lease
+    And the replica "a" tries to acquire the lease
+    Then the replica "b" should lead
+    And the replica "a" should not lead
+
+  Scenario: Takes over right away once the lease is released
+    Given the replica "a" tries to acquire the lease
+    When the replica "a" releases the lease
+    And the replica "b" tries to acquire the lease
+    Then the replica "b" should lead
+    And the replica "a" should not lead
+
+  Scenario: Stores the lease in a config map
+    Given the replicas "a" and "b" compete for a lease of 90 milliseconds in the k8s config map "vamp-router-leader"
+    When the replica "a" tries to acquire the lease
+    And the replica "b" tries to acquire the lease
+    Then the replica "a" should lead
+    And the replica "b" should not lead
+
+  Scenario: Routes the objects queued by a standby once it leads
+    Given the replica "a" tries to acquire the lease
+    And the k8s services are routed by the replica "b"
+    And the work queue is run with 1 worker
+    When the k8s service "app" is queued 1 time
+    And 20 milliseconds have passed
+    Then the vamp service "app-qwerty" should not exist
+    When the replica "a" releases the lease
+    And the replica "b" tries to acquire the lease
+    Then the work queue should become idle
+    And the vamp service "app-qwerty" should only contain the backend "1.2.3.4"
+
+  Scenario: Does not reconcile the routes as a standby
+    Given the replica "a" tries to acquire the lease
+    And the replica "b" tries to acquire the lease
+    When the routes are reconciled by the replica "b"
+    Then the vamp route should not be updated
+    When the routes are reconciled by the replica "a"
+    Then the vamp route should be updated
@@ -0,0 +1,49 @@
+Feature:
+  In order to know what the controller is doing
+  As an operator
+  I want the controller to expose its metrics to Prometheus
+
+  Background:
+    Given the k8s service "app" is in the namespace "qwerty"
+    And the k8s service "app" IP is "1.2.3.4"
+    And the k8s service "app" is a load-balancer exposing the port 80
+
+  Scenario: Counts the processed events by kind and type
+    Given a vamp route named "http" already exists
+    When the k8s service named "app" is created
+    And the k8s service named "app" is updated
+    And the k8s service named "app" is deleted
+    Then the metric 'k8svamprouter_events_total{kind="service",type="updated"}' should be 2
+    And the metric 'k8svamprouter_events_total{kind="service",type="deleted"}' should be 1
+
+  Scenario: Reports the hosts and backends managed in the shared routes
+    Given a vamp route named "http" already exists
+    And the vamp route "http" routes "legacy.example.com" to the backend "legacy"
+    When the k8s service named "app" is created
+    Then the metric 'k8svamprouter_managed_hosts{route="http"}' should be 1
+    And the metric 'k8svamprouter_managed_backends{route="http"}' should be 1
+
+  Scenario: Measures the reconciliations and reports the drift of the routes
+    Given a vamp route named "http" already exists
+    And the vamp route "http" routes "ghost.example.com" to the backend "ghost-qwerty" for the k8s service "ghost"
+    When the routes are reconciled
+    Then the metric 'k8svamprouter_route_drift{route="http"}' should be 4
+    And the metric 'k8svamprouter_reconcile_duration_seconds_count{route="http"}' should be 1
+    When the routes are reconciled
+    Then the metric 'k8svamprouter_route_drift{route="http"}' should be 0
+    And the metric 'k8svamprouter_reconcile_duration_seconds_count{route="http"}' should be 2
+
+  Scenario: Counts the requests to the Vamp Router API by status
+    Given the vamp router API answers with the statuses "503,200"
+    When the vamp route "http" is requested from the API
+    Then the metric 'k8svamprouter_vamp_api_requests_total{method="GET",status="503"}' should be 1
+    And the metric 'k8svamprouter_vamp_api_requests_total{method="GET",status="200"}' should be 1
+    And the metric 'k8svamprouter_vamp_api_request_duration_seconds_count{method="GET",status="200"}' should be 1
+
+  Scenario: Counts the requests that could not reach the Vamp Router API
+    Given the vamp router API answers after 100 milliseconds
+    And the vamp router client times out after 10 milliseconds
+    And the vamp router client retries 0 times
+    When the vamp route "http" is requested from the API
+    Then the metric 'k8svamprouter_vamp_api_requests_total{method="GET",status="error"}' should be 1
+    And the metric 'k8svamprouter_vamp_api_requests_total{method="GET",status="200"}' should not be exposed
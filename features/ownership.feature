@@ -0,0 +1,37 @@
+Feature:
+  In order to add my own routing to the shared Vamp routes
+  As an operator
+  I want the controller to only update and remove the entries it created
+
+  Background:
+    Given a vamp route named "http" already exists
+    And the k8s service "app" is in the namespace "qwerty"
+    And the k8s service "app" IP is "1.2.3.4"
+    And the k8s service "app" is a load-balancer exposing the port 80
+
+  Scenario: Does not route a host already routed by the operators
+    Given the vamp route "http" routes "app-qwerty.example.com" to the backend "legacy"
+    When the k8s service named "app" is created
+    Then the vamp filter named "app-qwerty.example.com" of the vamp route "http" should route to the vamp service "legacy"
+    And the vamp service "app-qwerty" should be created
+
+  Scenario: Adopts the routing created before the ownership was recorded
+    Given the vamp route "http" routes "app-qwerty.example.com" to the backend "app-qwerty"
+    And the k8s service named "app" is created
+    When the k8s service named "app" is deleted
+    Then the vamp service "app-qwerty" should not exist
+    And the vamp filter named "app-qwerty.example.com" should not exist
+
+  Scenario: Keeps the routing of the operators when deleting an object
+    Given the vamp route "http" routes "legacy.example.com" to the backend "app-qwerty"
+    And the k8s service named "app" is created
+    When the k8s service named "app" is deleted
+    Then the vamp filter named "legacy.example.com" should route to the vamp service "app-qwerty"
+
+  Scenario: Remembers the routing it created once restarted
+    Given the ownership of the vamp routes is stored in the k8s config map "vamp-router-ownership"
+    And the k8s service named "app" is created
+    When the controller is restarted
+    And the k8s service named "app" is deleted
+    Then the vamp service "app-qwerty" should not exist
+    And the vamp filter named "app-qwerty.example.com" should not exist
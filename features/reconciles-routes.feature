@@ -0,0 +1,69 @@
+Feature:
+  In order to have an up-to-date HTTP front-end even if some events were missed
+  As an operator
+  I want this application to reconcile the Vamp route with all the k8s services
+
+  Background:
+    Given the k8s service "app" is in the namespace "qwerty"
+    And the k8s service "app" IP is "1.2.3.4"
+    And the k8s service "app" is a load-balancer exposing the port 80
+
+  Scenario: Routes the services created while not watching
+    Given a vamp route named "http" already exists
+    When the routes are reconciled
+    Then the vamp service "app-qwerty" should only contain the backend "1.2.3.4"
+    And the vamp filter named "app-qwerty.example.com" should be created
+    And the vamp route should be updated once
+
+  Scenario: Removes the routing of the services deleted while not watching
+    Given a vamp route named "http" already exists
+    And the vamp route "http" routes "ghost.example.com" to the backend "ghost-qwerty" for the k8s service "ghost"
+    When the routes are reconciled
+    Then the vamp service "ghost-qwerty" should not exist
+    And the vamp filter named "ghost.example.com" should not exist
+    And the vamp service "app-qwerty" should be created
+
+  Scenario: Removes the routing of the services deleted while the controller was down
+    Given a vamp route named "http" already exists
+    And the ownership of the vamp routes is stored in the k8s config map "vamp-router-ownership"
+    And the k8s service named "app" is created
+    When the controller is restarted
+    And the k8s service "app" is deleted while not watching
+    And the routes are reconciled
+    Then the vamp service "app-qwerty" should not exist
+    And the vamp filter named "app-qwerty.example.com" should not exist
+
+  Scenario: Keeps the routing not created by the controller
+    Given a vamp route named "http" already exists
+    And the vamp route "http" routes "legacy.example.com" to the backend "legacy"
+    When the routes are reconciled
+    Then the vamp service "legacy" should be created
+    And the vamp filter named "legacy.example.com" should be created
+    And the vamp service "app-qwerty" should be created
+
+  Scenario: Does not route a host already routed by the operators
+    Given a vamp route named "http" already exists
+    And the vamp route "http" routes "app-qwerty.example.com" to the backend "legacy"
+    When the routes are reconciled
+    Then the vamp filter named "app-qwerty.example.com" should route to the vamp service "legacy"
+    And the vamp service "app-qwerty" should be created
+
+  Scenario: Updates the backends changed while not watching
+    Given a vamp route named "http" already exists
+    And the k8s service named "app" is created
+    And the k8s service "app" IP is "2.3.4.5"
+    When the routes are reconciled
+    Then the vamp service "app-qwerty" should only contain the backend "2.3.4.5"
+
+  Scenario: Does not update an up-to-date route
+    Given a vamp route named "http" already exists
+    And the k8s service named "app" is created
+    And the vamp route should be updated
+    When the routes are reconciled
+    Then the vamp route should not be updated
+
+  Scenario: Ignores the services that should not be routed
+    Given a vamp route named "http" already exists
+    And the k8s service "internal" is in the namespace "qwerty"
+    When the routes are reconciled
+    Then the vamp service "internal-qwerty" should not exist
@@ -0,0 +1,49 @@
+Feature:
+  In order to not route traffic to services that do not exist anymore
+  As a developer
+  I want this application to remove the Vamp routing of deleted k8s services
+
+  Background:
+    Given the k8s service "app" is in the namespace "qwerty"
+    And the k8s service "app" IP is "1.2.3.4"
+    And the k8s service "app" is a load-balancer exposing the port 80
+
+  Scenario: Removes the service and its filters with a single update
+    Given a vamp route named "http" already exists
+    When the k8s service named "app" is created
+    Then the vamp route should be updated
+    When the k8s service named "app" is deleted
+    Then the vamp route should be updated once
+    And the vamp service "app-qwerty" should not exist
+    And the vamp filter named "app-qwerty.example.com" should not exist
+
+  Scenario: Removes the filters of the custom domain names
+    Given a vamp route named "http" already exists
+    And the k8s service "app" has the following annotations:
+      | name                   | value                                              |
+      | kubernetesReverseproxy | {"hosts": [{"host": "example.com", "port": "80"}]} |
+    When the k8s service named "app" is created
+    And the k8s service named "app" is deleted
+    Then the vamp filter named "example.com" should not exist
+    And the vamp filter named "app-qwerty.example.com" should not exist
+
+  Scenario: Keeps the routing of the other services
+    Given a vamp route named "http" already exists
+    And the k8s service "other" is in the namespace "qwerty"
+    And the k8s service "other" IP is "2.3.4.5"
+    And the k8s service "other" is a load-balancer exposing the port 80
+    When the k8s service named "app" is created
+    And the k8s service named "other" is created
+    And the k8s service named "app" is deleted
+    Then the vamp service "app-qwerty" should not exist
+    And the vamp service "other-qwerty" should only contain the backend "2.3.4.5"
+    And the vamp filter named "other-qwerty.example.com" should be created
+
+  Scenario: Does not update the route if the service is not routed
+    Given a vamp route named "http" already exists
+    When the k8s service named "app" is deleted
+    Then the vamp route should not be updated
+
+  Scenario: Does not fail if the route do not exist
+    When the k8s service named "app" is deleted
+    Then the vamp route "http" should not exist
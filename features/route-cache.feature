@@ -0,0 +1,46 @@
+Feature:
+  In order to route the objects without reading the whole route for each of them
+  As an operator
+  I want the controller to keep a copy of the Vamp routes
+
+  Background:
+    Given a vamp route named "http" already exists
+    And the k8s service "app" is in the namespace "qwerty"
+    And the k8s service "app" IP is "1.2.3.4"
+    And the k8s service "app" is a load-balancer exposing the port 80
+    And the k8s service "other" is in the namespace "qwerty"
+    And the k8s service "other" IP is "2.3.4.5"
+    And the k8s service "other" is a load-balancer exposing the port 80
+
+  Scenario: Reads the route once
+    Given the vamp routes are cached for 60000 milliseconds
+    When the k8s service named "app" is created
+    And the k8s service named "other" is created
+    Then the vamp routes should have been read 1 time
+    And the vamp route "http" should have 2 filters and 2 services
+
+  Scenario: Reads the route again when it cannot be updated
+    Given the vamp routes are cached for 60000 milliseconds
+    And the k8s service named "app" is created
+    And the vamp router fails with the status 503
+    And the k8s service named "other" cannot be created
+    And the vamp router recovers
+    When the k8s service named "other" is created
+    Then the vamp routes should have been read 2 times
+    And the vamp route "http" should have 2 filters and 2 services
+
+  Scenario: Reads the route again once its copy is too old
+    Given the vamp routes are cached for 50 milliseconds
+    When the k8s service named "app" is created
+    And 100 milliseconds have passed
+    And the k8s service named "other" is created
+    Then the vamp routes should have been read 2 times
+
+  Scenario: Claims the entries of the cached route without sending it
+    Given the vamp routes are cached for 60000 milliseconds
+    And the k8s service named "app" is created
+    And the controller is restarted
+    When the k8s service named "app" is updated
+    Then the vamp routes should have been read 1 time
+    When the k8s service named "app" is deleted
+    Then the vamp route "http" should have 0 filters and 0 services
@@ -0,0 +1,56 @@
+Feature:
+  In order to not expose the Vamp Router API to anyone
+  As an operator
+  I want this application to authenticate on the router API over TLS
+
+  Background:
+    Given the vamp router API answers with the statuses "200"
+    And the vamp router client retries 0 times
+
+  Scenario: Sends the bearer token
+    Given the vamp router API requires the bearer token "s3cr3t"
+    And the vamp router client reads its token from a file containing "s3cr3t"
+    When the vamp route "http" is requested from the API
+    Then the request should succeed
+
+  Scenario: Reads the token again once it is rotated
+    Given the vamp router API requires the bearer token "rotated-token"
+    And the vamp router client reads its token from a file containing "token"
+    When the vamp route "http" is requested from the API
+    Then the request should fail with the status 401
+    When the token file now contains "rotated-token"
+    And the vamp route "http" is requested from the API
+    Then the request should succeed
+
+  Scenario: Sends the username and password
+    Given the vamp router API requires the username "vamp" and the password "p4ssw0rd"
+    And the vamp router client reads its username and password from files containing "vamp" and "p4ssw0rd"
+    When the vamp route "http" is requested from the API
+    Then the request should succeed
+
+  Scenario: Trusts the given certificate authority
+    Given the vamp router API is served over TLS
+    And the vamp router client trusts the authority of the API
+    When the vamp route "http" is requested from the API
+    Then the request should succeed
+
+  Scenario: Does not trust an unknown certificate authority
+    Given the vamp router API is served over TLS
+    When the vamp route "http" is requested from the API
+    Then the request should fail
+    And the vamp router API should have received 0 requests
+
+  Scenario: Presents the client certificate
+    Given the vamp router API is served over TLS
+    And the vamp router API requires a client certificate
+    And the vamp router client presents a client certificate
+    When the vamp route "http" is requested from the API
+    Then the request should succeed
+
+  Scenario: Is rejected without client certificate
+    Given the vamp router API is served over TLS
+    And the vamp router API requires a client certificate
+    And the vamp router client trusts the authority of the API
+    When the vamp route "http" is requested from the API
+    Then the request should fail
+    And the vamp router API should have received 0 requests
@@ -0,0 +1,65 @@
+Feature:
+  In order to not lose the routing of an event because of a hiccup of the router
+  As an operator
+  I want the Vamp Router client to retry, time out and stop flooding a failing router
+
+  Scenario: Retries the idempotent requests when the router fails
+    Given the vamp router API answers with the statuses "503,502,200"
+    When the vamp route "http" is requested from the API
+    Then the request should succeed
+    And the vamp router API should have received 3 requests
+
+  Scenario: Gives up after the last retry
+    Given the vamp router API answers with the statuses "500"
+    And the vamp router client retries 2 times
+    When the vamp route "http" is requested from the API
+    Then the request should fail with the status 500
+    And the vamp router API should have received 3 requests
+
+  Scenario: Does not retry the requests that are answered
+    Given the vamp router API answers with the statuses "404,200"
+    When the vamp route "http" is requested from the API
+    Then the request should fail with the status 404
+    And the vamp router API should have received 1 request
+
+  Scenario: Does not retry the creations
+    Given the vamp router API answers with the statuses "503,200"
+    When the vamp route "http" is created through the API
+    Then the request should fail with the status 503
+    And the vamp router API should have received 1 request
+
+  Scenario: Times out the requests of a router that does not answer
+    Given the vamp router API answers with the statuses "200"
+    And the vamp router API answers after 100 milliseconds
+    And the vamp router client times out after 10 milliseconds
+    And the vamp router client retries 1 times
+    When the vamp route "http" is requested from the API
+    Then the request should time out
+    And the vamp router API should have received 2 requests
+
+  Scenario: Stops sending requests to a failing router
+    Given the vamp router API answers with the statuses "500"
+    And the vamp router client retries 0 times
+    And the circuit breaker opens after 2 failures
+    When the vamp route "http" is requested from the API
+    Then the circuit breaker should be "closed"
+    When the vamp route "http" is requested from the API
+    Then the circuit breaker should be "open"
+    And the circuit breaker should have gone from "closed" to "open"
+    When the vamp route "http" is requested from the API
+    Then the request should be rejected by the circuit breaker
+    And the vamp router API should have received 2 requests
+
+  Scenario: Closes the circuit once the router recovered
+    Given the vamp router API answers with the statuses "500,500,200"
+    And the vamp router client retries 0 times
+    And the circuit breaker opens after 2 failures
+    And the circuit breaker is reset after 10 milliseconds
+    When the vamp route "http" is requested from the API
+    And the vamp route "http" is requested from the API
+    Then the circuit breaker should be "open"
+    When 20 milliseconds have passed
+    And the vamp route "http" is requested from the API
+    Then the request should succeed
+    And the circuit breaker should have gone from "open" to "half-open"
+    And the circuit breaker should be "closed"
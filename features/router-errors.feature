@@ -0,0 +1,32 @@
+Feature:
+  In order to not overwrite the routing because of a failing router
+  As an operator
+  I want this application to only create the Vamp routes that do not exist
+
+  Background:
+    Given the k8s service "app" is in the namespace "qwerty"
+    And the k8s service "app" IP is "1.2.3.4"
+    And the k8s service "app" is a load-balancer exposing the port 80
+
+  Scenario: Creates the route when it is not found
+    When the k8s service named "app" is created
+    Then the vamp route "http" should be created
+    And the vamp service "app-qwerty" should be created
+
+  Scenario: Does not create the route when the router fails
+    Given the vamp router fails with the status 500
+    Then the k8s service named "app" cannot be created
+    When the vamp router recovers
+    Then the vamp route "http" should not exist
+
+  Scenario: Does not fail the removal when the route is not found
+    When the k8s service named "app" is deleted
+    Then the vamp route "http" should not exist
+
+  Scenario: Fails the removal when the router fails
+    Given a vamp route named "http" already exists
+    And the k8s service named "app" is created
+    And the vamp router fails with the status 503
+    Then the k8s service named "app" cannot be deleted
+    When the vamp router recovers
+    Then the vamp service "app-qwerty" should be created
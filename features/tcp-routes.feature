@@ -0,0 +1,105 @@
+Feature:
+  In order to expose my databases and brokers running in the cluster
+  As an operator
+  I want the non-HTTP ports of my load-balancer services to be routed with TCP routes
+
+  Background:
+    Given a vamp route named "http" already exists
+    And TCP routes are enabled with the port range "20000-20001"
+    And the k8s service "db" is in the namespace "qwerty"
+    And the k8s service "db" IP is "1.2.3.4"
+    And the k8s service "db" is a load-balancer exposing the port 5432
+
+  Scenario: Creates a TCP route for a service without HTTP port
+    Given the k8s service "db" should be handled
+    When the k8s service named "db" is created
+    Then the vamp route "db-qwerty-5432" should listen on the port 20000
+    And the vamp route "db-qwerty-5432" should only contain the backend "1.2.3.4" on the port 5432
+    And the router port of the port 5432 of the k8s service "db" should be 20000
+    And the vamp service "db-qwerty" should not exist
+
+  Scenario: Creates a TCP route for each port other than the HTTP port
+    Given the k8s service "db" exposes the port 80 named "http"
+    When the k8s service named "db" is created
+    Then the vamp service "db-qwerty" should only contain the backend "1.2.3.4" on the port 80
+    And the vamp route "db-qwerty-5432" should listen on the port 20000
+    And the vamp route "db-qwerty-80" should not exist
+
+  Scenario: Keeps the router port previously allocated
+    Given the k8s service "db" has the following annotations:
+      | name                  | value           |
+      | vamp-router/tcp-ports | {"5432": 20001} |
+    When the k8s service named "db" is created
+    Then the vamp route "db-qwerty-5432" should listen on the port 20001
+
+  Scenario: Keeps the router port when the service is updated
+    Given the k8s service named "db" is created
+    And the k8s service "db" IP is "2.3.4.5"
+    When the k8s service named "db" is updated
+    Then the vamp route "db-qwerty-5432" should listen on the port 20000
+    And the vamp route "db-qwerty-5432" should only contain the backend "2.3.4.5" on the port 5432
+
+  Scenario: Reserves the router ports already allocated before routing
+    Given the k8s service "db" has the following annotations:
+      | name                  | value           |
+      | vamp-router/tcp-ports | {"5432": 20000} |
+    And the k8s service "cache" is in the namespace "qwerty"
+    And the k8s service "cache" is a load-balancer exposing the port 6379
+    When the allocated TCP ports are reserved
+    And the k8s service named "cache" is created
+    Then the vamp route "cache-qwerty-6379" should listen on the port 20001
+
+  Scenario: Fails when there is no port left in the range
+    Given the k8s service "db" exposes the port 6379 named "redis"
+    And the k8s service "db" exposes the port 1883 named "mqtt"
+    Then the k8s service named "db" cannot be created
+
+  Scenario: Removes the TCP routes of a deleted service and releases their ports
+    Given the k8s service "cache" is in the namespace "qwerty"
+    And the k8s service "cache" is a load-balancer exposing the port 6379
+    When the k8s service named "db" is created
+    And the k8s service named "db" is deleted
+    And the k8s service named "cache" is created
+    Then the vamp route "db-qwerty-5432" should not exist
+    And the vamp route "cache-qwerty-6379" should listen on the port 20000
+
+  Scenario: Removes the TCP route of a port no longer exposed and releases its port
+    Given the k8s service "db" exposes the port 6379 named "redis"
+    And the k8s service named "db" is created
+    And the k8s service "db" no longer exposes the port 6379
+    And the k8s service "cache" is in the namespace "qwerty"
+    And the k8s service "cache" is a load-balancer exposing the port 6379
+    When the k8s service named "db" is updated
+    And the k8s service named "cache" is created
+    Then the vamp route "db-qwerty-6379" should not exist
+    And the vamp route "db-qwerty-5432" should listen on the port 20000
+    And the router port of the port 6379 of the k8s service "db" should be 0
+    And the vamp route "cache-qwerty-6379" should listen on the port 20001
+
+  Scenario: Releases the TCP route of a port no longer exposed already deleted from the router
+    Given the k8s service "db" exposes the port 6379 named "redis"
+    And the k8s service named "db" is created
+    And the vamp route "db-qwerty-6379" is deleted by the operators
+    And the k8s service "db" no longer exposes the port 6379
+    When the k8s service named "db" is updated
+    Then the vamp route "db-qwerty-6379" should not be owned by the controller
+    And the router port of the port 6379 of the k8s service "db" should be 0
+
+  Scenario: Removes the TCP routes of a service that is no longer a load-balancer
+    Given the k8s service named "db" is created
+    And the k8s service "db" is no longer a load-balancer
+    When the k8s service named "db" is synced
+    Then the vamp route "db-qwerty-5432" should not exist
+
+  Scenario: Creates the TCP routes of the services created while not watching
+    When the routes are reconciled
+    Then the vamp route "db-qwerty-5432" should listen on the port 20000
+    And the router port of the port 5432 of the k8s service "db" should be 20000
+
+  Scenario: Removes the TCP routes of the services deleted while not watching
+    Given the k8s service named "db" is created
+    And the k8s service "db" is deleted while not watching
+    And the vamp TCP route "legacy" already listens on the port 20001
+    When the routes are reconciled
+    Then the vamp route "db-qwerty-5432" should not exist
+    And the vamp route "legacy" should listen on the port 20001
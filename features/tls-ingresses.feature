@@ -0,0 +1,119 @@
+Feature:
+  In order to serve my applications over HTTPS
+  As a developer
+  I want the TLS hosts of my ingresses to be routed through the HTTPS route with their certificates provisioned
+
+  Background:
+    Given a vamp route named "http" already exists
+    And the k8s ingress "web" is in the namespace "qwerty"
+    And the k8s ingress "web" has the following rules:
+      | host            | path | service | port |
+      | example.com     |      | web     | 80   |
+      | api.example.com |      | api     | 80   |
+    And the k8s secret "tls" in the namespace "qwerty" contains the certificate "CERTIFICATE" and the key "KEY"
+
+  Scenario: Routes the TLS hosts through the HTTPS route
+    Given the k8s ingress "web" terminates TLS for the hosts "example.com" with the secret "tls"
+    When the k8s ingress named "web" is created
+    Then the vamp route "https" should listen on the port 4443
+    And the vamp filter named "example.com" of the vamp route "https" should route to the vamp service "web-qwerty-web-80"
+    And the vamp filter named "api.example.com" of the vamp route "https" should not exist
+    And the vamp filter named "example.com" of the vamp route "http" should route to the vamp service "web-qwerty-web-80"
+
+  Scenario: Provisions the certificate of the secret
+    Given the k8s ingress "web" terminates TLS for the hosts "example.com" with the secret "tls"
+    When the k8s ingress named "web" is created
+    Then the certificate "ingress_qwerty_web_tls" should contain the certificate "CERTIFICATE" and the key "KEY"
+
+  Scenario: Does not create the HTTPS route without TLS
+    When the k8s ingress named "web" is created
+    Then the vamp route "https" should not exist
+
+  Scenario: Ignores the TLS sections when the certificates cannot be provisioned
+    Given the certificates of the ingresses cannot be provisioned
+    And the k8s ingress "web" terminates TLS for the hosts "example.com" with the secret "tls"
+    And the k8s ingress "web" has the following annotations:
+      | name                       | value |
+      | vamp-router/https-redirect | true  |
+    When the k8s ingress named "web" is created
+    Then the vamp route "https" should not exist
+    And the vamp filter named "example.com" of the vamp route "http" should route to the vamp service "web-qwerty-web-80"
+
+  Scenario: Fails when the secret does not exist
+    Given the k8s ingress "web" terminates TLS for the hosts "example.com" with the secret "unknown"
+    Then the k8s ingress named "web" cannot be created
+
+  Scenario: Redirects the HTTP requests of the TLS hosts to HTTPS
+    Given the HTTPS redirects are served by "10.0.0.1:8080"
+    And the k8s ingress "web" terminates TLS for the hosts "example.com" with the secret "tls"
+    And the k8s ingress "web" has the following annotations:
+      | name                       | value |
+      | vamp-router/https-redirect | true  |
+    When the k8s ingress named "web" is created
+    Then the vamp filter named "example.com" of the vamp route "http" should route to the vamp service "web-qwerty-https-redirect"
+    And the vamp service "web-qwerty-https-redirect" should only contain the backend "10.0.0.1" on the port 8080
+    And the vamp filter named "api.example.com" of the vamp route "http" should route to the vamp service "web-qwerty-api-80"
+    And the vamp filter named "example.com" of the vamp route "https" should route to the vamp service "web-qwerty-web-80"
+
+  Scenario: Fails to redirect without redirect server
+    Given the k8s ingress "web" terminates TLS for the hosts "example.com" with the secret "tls"
+    And the k8s ingress "web" has the following annotations:
+      | name                       | value |
+      | vamp-router/https-redirect | true  |
+    Then the k8s ingress named "web" cannot be created
+
+  Scenario: Redirects to the same URL with the HTTPS scheme
+    Then the HTTPS redirect of "http://example.com:80/api?page=2" should be "https://example.com/api?page=2"
+
+  Scenario: Removes the HTTPS routing and the certificate of a deleted ingress
+    Given the k8s ingress "web" terminates TLS for the hosts "example.com" with the secret "tls"
+    When the k8s ingress named "web" is created
+    And the k8s ingress named "web" is deleted
+    Then the vamp filter named "example.com" of the vamp route "https" should not exist
+    And the vamp service "web-qwerty-web-80" should not exist
+    And the certificate "ingress_qwerty_web_tls" should not be stored
+
+  Scenario: Removes the HTTPS routing of an ingress no longer terminating TLS
+    Given the k8s ingress "web" terminates TLS for the hosts "example.com" with the secret "tls"
+    And the k8s ingress named "web" is created
+    And the k8s ingress "web" no longer terminates TLS
+    When the k8s ingress named "web" is updated
+    Then the vamp route "https" should have 0 filters and 0 services
+    And the vamp filter named "example.com" of the vamp route "http" should route to the vamp service "web-qwerty-web-80"
+
+  Scenario: Reconciles the HTTPS route
+    Given the k8s ingress "web" terminates TLS for the hosts "example.com" with the secret "tls"
+    And the k8s ingress named "web" is created
+    And the vamp route "https" routes "unknown.example.com" to the backend "unknown" for the k8s ingress "unknown"
+    When the ingress routes are reconciled
+    Then the vamp filter named "unknown.example.com" of the vamp route "https" should not exist
+    And the vamp filter named "example.com" of the vamp route "https" should route to the vamp service "web-qwerty-web-80"
+
+  Scenario: Moves the HTTPS route previously listening on the port 443
+    Given the vamp route "https" already listens on the port 443
+    And the k8s ingress "web" terminates TLS for the hosts "example.com" with the secret "tls"
+    When the k8s ingress named "web" is created
+    Then the vamp route "https" should listen on the port 4443
+
+  Scenario: Removes the certificate of a removed TLS section
+    Given the k8s ingress "web" terminates TLS for the hosts "example.com" with the secret "tls"
+    And the k8s ingress named "web" is created
+    And the k8s ingress "web" no longer terminates TLS
+    When the k8s ingress named "web" is updated
+    Then the certificate "ingress_qwerty_web_tls" should not be stored
+
+  Scenario: Writes the rotated certificates when reconciling
+    Given the k8s ingress "web" terminates TLS for the hosts "example.com" with the secret "tls"
+    And the k8s ingress named "web" is created
+    And the k8s secret "tls" in the namespace "qwerty" is rotated to the certificate "ROTATED-CERTIFICATE" and the key "ROTATED-KEY"
+    When the ingress routes are reconciled
+    Then the certificate "ingress_qwerty_web_tls" should contain the certificate "ROTATED-CERTIFICATE" and the key "ROTATED-KEY"
+
+  Scenario: Removes the certificates of the ingresses deleted while not watching
+    Given the certificate "operators" is stored by the operators
+    And the k8s ingress "web" terminates TLS for the hosts "example.com" with the secret "tls"
+    And the k8s ingress named "web" is created
+    And the k8s ingress "web" is deleted while not watching
+    When the ingress routes are reconciled
+    Then the certificate "ingress_qwerty_web_tls" should not be stored
+    And the certificate "operators" should contain the certificate "CERTIFICATE" and the key "KEY"
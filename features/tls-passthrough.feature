@@ -0,0 +1,77 @@
+Feature:
+  In order to expose the applications terminating TLS themselves
+  As a developer
+  I want their TLS connections to be passed through by the Vamp router based on their server name
+
+  Background:
+    Given a vamp route named "http" already exists
+    And the k8s service "secure" is in the namespace "qwerty"
+    And the k8s service "secure" IP is "1.2.3.4"
+    And the k8s service "secure" is a load-balancer exposing the port 443
+    And the k8s service "secure" has the following annotations:
+      | name                        | value |
+      | vamp-router/tls-passthrough | true  |
+
+  Scenario: Routes the TLS connections of a service by their server name
+    Given the k8s service "secure" should be handled
+    When the k8s service named "secure" is created
+    Then the vamp route "tls-passthrough" should listen on the port 443
+    And the vamp filter named "secure-qwerty.example.com" of the vamp route "tls-passthrough" should have the condition "req.ssl_sni -i secure-qwerty.example.com"
+    And the vamp filter named "secure-qwerty.example.com" of the vamp route "tls-passthrough" should route to the vamp service "secure-qwerty"
+    And the vamp route "tls-passthrough" should only contain the backend "1.2.3.4" on the port 443
+
+  Scenario: Listens on the given port
+    Given the TLS connections are passed through on the port 8443
+    When the k8s service named "secure" is created
+    Then the vamp route "tls-passthrough" should listen on the port 8443
+
+  Scenario: Moves the route to the given port
+    Given the k8s service named "secure" is created
+    And the TLS connections are passed through on the port 8443
+    When the k8s service named "secure" is updated
+    Then the vamp route "tls-passthrough" should listen on the port 8443
+
+  Scenario: Passes the TLS connections through to the port named https
+    Given the k8s service "secure" exposes the port 8443 named "https"
+    And the k8s service "secure" exposes the port 80 named "http"
+    When the k8s service named "secure" is created
+    Then the vamp route "tls-passthrough" should only contain the backend "1.2.3.4" on the port 8443
+    And the vamp service "secure-qwerty" should only contain the backend "1.2.3.4" on the port 80
+
+  Scenario: Does not give a TCP route to the TLS port
+    Given TCP routes are enabled with the port range "20000-20001"
+    When the k8s service named "secure" is created
+    Then the vamp route "secure-qwerty-443" should not exist
+
+  Scenario: Ignores the services exposing only a TLS port without the annotation
+    Given the k8s service "secure" has the following annotations:
+      | name                        | value |
+      | vamp-router/tls-passthrough | false |
+    Then the k8s service "secure" should not be handled
+
+  Scenario: Removes the TLS passthrough routing of a deleted service
+    Given the k8s service named "secure" is created
+    When the k8s service named "secure" is deleted
+    Then the vamp filter named "secure-qwerty.example.com" of the vamp route "tls-passthrough" should not exist
+
+  Scenario: Removes the TLS passthrough routing of a service losing the annotation
+    Given the k8s service "secure" exposes the port 80 named "http"
+    And the k8s service named "secure" is created
+    And the k8s service "secure" has the following annotations:
+      | name                        | value |
+      | vamp-router/tls-passthrough | false |
+    When the k8s service named "secure" is updated
+    Then the vamp route "tls-passthrough" should have 0 filters and 0 services
+    And the vamp service "secure-qwerty" should only contain the backend "1.2.3.4" on the port 80
+
+  Scenario: Routes the TLS connections of the ingress hosts
+    Given the k8s ingress "web" is in the namespace "qwerty"
+    And the k8s ingress "web" has the following rules:
+      | host        | path | service | port |
+      | example.com |      | web     | 443  |
+    And the k8s ingress "web" has the following annotations:
+      | name                        | value |
+      | vamp-router/tls-passthrough | true  |
+    When the k8s ingress named "web" is created
+    Then the vamp filter named "example.com" of the vamp route "tls-passthrough" should have the condition "req.ssl_sni -i example.com"
+    And the vamp filter named "example.com" of the vamp route "tls-passthrough" should route to the vamp service "web-qwerty-web-443"
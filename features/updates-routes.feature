@@ -6,6 +6,7 @@ Feature:
   Background:
     Given the k8s service "app" is in the namespace "qwerty"
     And the k8s service "app" IP is "1.2.3.4"
+    And the k8s service "app" is a load-balancer exposing the port 80
 
   Scenario: Route a created service
     When the k8s service named "app" is created
@@ -25,6 +26,22 @@ Feature:
     And the k8s service named "app" is updated
     Then the vamp service "app-qwerty" should only contain the backend "2.3.4.5"
 
+  Scenario: Sends only the changed service to the router
+    Given a vamp route named "http" already exists
+    And the k8s service named "app" is created
+    And the vamp route should be updated
+    When the k8s service "app" IP is "2.3.4.5"
+    And the k8s service named "app" is updated
+    Then only the vamp service "app-qwerty" should have been sent
+
+  Scenario: Deletes the service replaced by another one
+    Given a vamp route named "http" already exists
+    And the vamp route "http" has the vamp service "old" without filter
+    When the vamp service "old" of the vamp route "http" is replaced by the vamp service "new"
+    Then the vamp service "old" should not exist
+    And the vamp service "new" should be created
+    And the vamp route "http" should have 0 filters and 1 services
+
   Scenario: Should not update the service if nothing changed
     Given a vamp route named "http" already exists
     When a k8s service named "app" is created in the namespace "qwerty" with the IP "1.2.3.4"
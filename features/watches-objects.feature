@@ -0,0 +1,45 @@
+Feature:
+  In order to keep routing my k8s services when the API server closes the watch
+  As an operator
+  I want this application to watch the services again from where it stopped
+
+  Background:
+    Given a vamp route named "http" already exists
+    And the k8s service "app" is in the namespace "qwerty"
+    And the k8s service "app" IP is "1.2.3.4"
+    And the k8s service "app" is a load-balancer exposing the port 80
+    And the k8s services are listed at the resource version "10"
+
+  Scenario: Routes the listed services
+    When the k8s services are watched
+    Then the vamp service "app-qwerty" should be created
+    And the k8s services should have been watched from the resource version "10"
+
+  Scenario: Watches again from the last resource version
+    Given the k8s service "app" will be modified at the resource version "11"
+    When the k8s services are watched
+    And the k8s services are watched
+    Then the k8s services should have been listed 1 time
+    And the k8s services should have been watched from the resource version "11"
+
+  Scenario: Lists again when the resource version is too old
+    Given the watch of the k8s services will fail because the resource version is too old
+    When the k8s services are watched
+    Then the k8s services should be watched with an error
+    When the k8s services are watched
+    Then the k8s services should have been listed 2 times
+
+  Scenario: Removes the routing of the deleted services
+    When the k8s services are watched
+    And the k8s service "app" will be deleted at the resource version "11"
+    And the k8s services are watched
+    Then the vamp service "app-qwerty" should not exist
+
+  Scenario: Removes the routing of the services deleted between two listings
+    When the k8s services are watched
+    And the k8s service "app" is removed from the cluster
+    And the watch of the k8s services will fail because the resource version is too old
+    And the k8s services are watched
+    And the k8s services are watched
+    Then the vamp service "app-qwerty" should not exist
+    And the k8s services should have been listed 2 times
@@ -0,0 +1,120 @@
+Feature:
+  In order to release a canary version of my application
+  As a developer
+  I want several k8s services to share a host with a percentage of its traffic
+
+  Background:
+    Given a vamp route named "http" already exists
+    And the router ports are allocated from the range "20000-20001"
+    And the k8s service "web" is in the namespace "qwerty"
+    And the k8s service "web" IP is "1.2.3.4"
+    And the k8s service "web" is a load-balancer exposing the port 80
+    And the k8s service "web" has the following annotations:
+      | name                | value               |
+      | vamp-router/weights | {"example.com": 90} |
+    And the k8s service "web-canary" is in the namespace "qwerty"
+    And the k8s service "web-canary" IP is "5.6.7.8"
+    And the k8s service "web-canary" is a load-balancer exposing the port 80
+    And the k8s service "web-canary" has the following annotations:
+      | name                | value               |
+      | vamp-router/weights | {"example.com": 10} |
+
+  Scenario: Splits the traffic of the shared host with a weighted route
+    When the k8s service named "web" waits for the weights of its hosts
+    And the k8s service named "web-canary" is created
+    And the k8s service named "web" is updated
+    Then the vamp filter named "example.com" should route to the vamp service "weighted-example.com"
+    And the vamp service "weighted-example.com" should only contain the backend "127.0.0.1" on the port 20000
+    And the vamp route "weighted-example.com" should listen on the port 20000
+    And the vamp service "web-qwerty" of the vamp route "weighted-example.com" should have the weight 90
+    And the vamp service "web-canary-qwerty" of the vamp route "weighted-example.com" should have the weight 10
+    And the weights of the vamp route "weighted-example.com" should add up to 100
+
+  Scenario: Keeps routing the generated domain names to each service
+    When the k8s service named "web" waits for the weights of its hosts
+    And the k8s service named "web-canary" is created
+    And the k8s service named "web" is updated
+    Then the vamp filter named "web-qwerty.example.com" should route to the vamp service "web-qwerty"
+    And the vamp filter named "web-canary-qwerty.example.com" should route to the vamp service "web-canary-qwerty"
+
+  Scenario: Waits for the weights of the host to add up to 100
+    When the k8s service named "web" waits for the weights of its hosts
+    Then the weights of the vamp route "weighted-example.com" should not add up to 100
+    And the vamp filter named "example.com" should not exist
+    When the k8s service named "web-canary" is created
+    Then the vamp filter named "example.com" should route to the vamp service "weighted-example.com"
+    And the weights of the vamp route "weighted-example.com" should add up to 100
+    When the k8s service named "web" is updated
+    Then the vamp filter named "web-qwerty.example.com" should route to the vamp service "web-qwerty"
+
+  Scenario: Rejects the weights adding up to less than 100
+    Given the k8s service "web-canary" has the following annotations:
+      | name                | value              |
+      | vamp-router/weights | {"example.com": 5} |
+    And the k8s service named "web" waits for the weights of its hosts
+    Then the k8s service named "web-canary" cannot be created
+    And the weights of the vamp route "weighted-example.com" should not add up to 100
+    And the vamp filter named "example.com" should not exist
+
+  Scenario: Rejects the weights out of range
+    Given the k8s service "web" has the following annotations:
+      | name                | value                |
+      | vamp-router/weights | {"example.com": 150} |
+    Then the k8s service named "web" cannot be created
+
+  Scenario: Requires a port range for the weighted routes
+    Given no router port range is configured
+    Then the k8s service named "web" cannot be created
+
+  Scenario: Removes the weighted route with its last service
+    Given the k8s service named "web" waits for the weights of its hosts
+    And the k8s service named "web-canary" is created
+    When the k8s service named "web-canary" is deleted
+    Then the vamp service "web-qwerty" of the vamp route "weighted-example.com" should have the weight 90
+    And the weights of the vamp route "weighted-example.com" should not add up to 100
+    When the k8s service named "web" is deleted
+    Then the vamp route "weighted-example.com" should not exist
+    And the vamp filter named "example.com" should not exist
+    And the vamp service "weighted-example.com" should not exist
+
+  Scenario: Removes the backend of a host dropped from the weights
+    Given the k8s service named "web" waits for the weights of its hosts
+    And the k8s service named "web-canary" is created
+    And the k8s service "web-canary" has the following annotations:
+      | name                | value |
+      | vamp-router/weights | {}    |
+    When the k8s service named "web-canary" is updated
+    Then the vamp route "weighted-example.com" should have 0 filters and 1 services
+    And the vamp service "web-qwerty" of the vamp route "weighted-example.com" should have the weight 90
+
+  Scenario: Removes the weighted route when its last service stops weighting the host
+    Given the k8s service "web" has the following annotations:
+      | name                | value                |
+      | vamp-router/weights | {"example.com": 100} |
+    And the k8s service named "web" is created
+    And the k8s service "web" has the following annotations:
+      | name                | value |
+      | vamp-router/weights | {}    |
+    When the k8s service named "web" is updated
+    Then the vamp route "weighted-example.com" should not exist
+    And the vamp filter named "example.com" should not exist
+    And the vamp service "weighted-example.com" should not exist
+    And the vamp filter named "web-qwerty.example.com" should route to the vamp service "web-qwerty"
+
+  Scenario: Rejects the weights adding up to more than 100
+    Given the k8s service "web-canary" has the following annotations:
+      | name                | value               |
+      | vamp-router/weights | {"example.com": 20} |
+    And the k8s service named "web" waits for the weights of its hosts
+    Then the k8s service named "web-canary" cannot be created
+    And the vamp route "weighted-example.com" should have 0 filters and 1 services
+
+  Scenario: Reserves the ports of the existing weighted routes
+    Given TCP routes are enabled with the port range "20000-20001"
+    And the vamp route "weighted-example.com" already listens on the port 20000
+    And the k8s service "db" is in the namespace "qwerty"
+    And the k8s service "db" IP is "9.8.7.6"
+    And the k8s service "db" is a load-balancer exposing the port 5432
+    When the ports of the weighted routes are reserved
+    And the k8s service named "db" is created
+    Then the vamp route "db-qwerty-5432" should listen on the port 20001
@@ -0,0 +1,58 @@
+Feature:
+  In order to route my k8s services even when the router fails for a while
+  As an operator
+  I want the events of the services to be queued, coalesced and retried
+
+  Background:
+    Given a vamp route named "http" already exists
+    And the k8s service "app" is in the namespace "qwerty"
+    And the k8s service "app" IP is "1.2.3.4"
+    And the k8s service "app" is a load-balancer exposing the port 80
+
+  Scenario: Coalesces the events of an object
+    Given the k8s service "app" is queued 3 times
+    When the work queue is run with 1 worker
+    Then the work queue should become idle
+    And the k8s objects should have been synced 1 time
+    And the vamp route should be updated once
+    And the vamp service "app-qwerty" should only contain the backend "1.2.3.4"
+
+  Scenario: Processes the last event of an object
+    Given the k8s service "app" is queued 1 time
+    And the deletion of the k8s service "app" is queued
+    When the work queue is run with 1 worker
+    Then the work queue should become idle
+    And the k8s objects should have been synced 1 time
+    And the vamp service "app-qwerty" should not exist
+
+  Scenario: Retries the objects that failed to be synced
+    Given the vamp router fails with the status 500
+    And the k8s service "app" is queued 1 time
+    When the work queue is run with 1 worker
+    Then the k8s service "app" should be retried
+    When the vamp router recovers
+    Then the work queue should become idle
+    And the vamp service "app-qwerty" should be created
+
+  Scenario: Doubles the retry delay after each failure
+    Then the retry delay after 1 failure should be "5ms"
+    And the retry delay after 2 failures should be "10ms"
+    And the retry delay after 3 failures should be "20ms"
+    And the retry delay after 10 failures should be "50ms"
+
+  Scenario: Bounds the number of objects synced at once
+    Given the vamp router takes 1 millisecond to answer
+    And the k8s service "other" is in the namespace "qwerty"
+    And the k8s service "other" IP is "2.3.4.5"
+    And the k8s service "other" is a load-balancer exposing the port 80
+    And the k8s service "third" is in the namespace "qwerty"
+    And the k8s service "third" IP is "3.4.5.6"
+    And the k8s service "third" is a load-balancer exposing the port 80
+    And the k8s service "app" is queued 1 time
+    And the k8s service "other" is queued 1 time
+    And the k8s service "third" is queued 1 time
+    When the work queue is run with 2 workers
+    Then the work queue should become idle
+    And the k8s objects should have been synced 3 times
+    And at most 2 k8s objects should have been synced at once
+    And the vamp service "other-qwerty" should only contain the backend "2.3.4.5"
@@ -0,0 +1,139 @@
+package k8svamprouter
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sroze/kubernetes-vamp-router/vamprouter"
+)
+
+// The health checker tells Kubernetes whether the controller is alive, its
+// watchers still consuming the events, and whether it is ready, the routes
+// having been reconciled once and the router answering.
+type HealthChecker struct {
+	// Watchers that must all be alive
+	Watchers []*ObjectWatcher
+
+	// Client probing the router when it did not answer for `RouterMaxSilence`
+	RouterClient     vamprouter.Interface
+	RouterMaxSilence time.Duration
+
+	// Tells when the router last answered the requests of the controller,
+	// optional
+	RouterResponses RouterResponseTimer
+
+	mutex             sync.Mutex
+	synced            bool
+	routerRespondedAt time.Time
+
+	probeMutex sync.Mutex
+}
+
+// Implemented by the Vamp Router client.
+type RouterResponseTimer interface {
+	LastResponseTime() time.Time
+}
+
+// Records that the routes have been reconciled with the Kubernetes objects.
+func (h *HealthChecker) MarkSynced() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.synced = true
+}
+
+func (h *HealthChecker) CheckLiveness() error {
+	for _, watcher := range h.Watchers {
+		if err := watcher.CheckLiveness(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (h *HealthChecker) CheckReadiness() error {
+	h.mutex.Lock()
+	synced := h.synced
+	h.mutex.Unlock()
+
+	if !synced {
+		return fmt.Errorf("The routes have not been reconciled yet")
+	}
+
+	for _, watcher := range h.Watchers {
+		if !watcher.HasListed() {
+			return fmt.Errorf("The %s have not been listed yet", watcher.Name)
+		}
+	}
+
+	return h.CheckRouter()
+}
+
+// Probes the router when it did not answer recently.
+func (h *HealthChecker) CheckRouter() error {
+	h.probeMutex.Lock()
+	defer h.probeMutex.Unlock()
+
+	respondedAt := h.getRouterRespondedAt()
+	if time.Since(respondedAt) <= h.RouterMaxSilence {
+		return nil
+	}
+
+	_, err := h.RouterClient.ListRoutes()
+	if statusCode := vamprouter.StatusCode(err); err != nil && (statusCode == 0 || statusCode >= 500) {
+		if respondedAt.IsZero() {
+			return fmt.Errorf("The Vamp Router never answered: %s", err)
+		}
+
+		return fmt.Errorf("The Vamp Router has not answered since %s: %s", respondedAt.Format(time.RFC3339), err)
+	}
+
+	h.mutex.Lock()
+	h.routerRespondedAt = time.Now()
+	h.mutex.Unlock()
+
+	return nil
+}
+
+// The router answered either the probes or the requests of the controller.
+func (h *HealthChecker) getRouterRespondedAt() time.Time {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.RouterResponses == nil {
+		return h.routerRespondedAt
+	}
+
+	if lastResponse := h.RouterResponses.LastResponseTime(); lastResponse.After(h.routerRespondedAt) {
+		return lastResponse
+	}
+
+	return h.routerRespondedAt
+}
+
+// Serves `/healthz`.
+func (h *HealthChecker) ServeLiveness(w http.ResponseWriter, r *http.Request) {
+	serveCheck(w, "alive", h.CheckLiveness())
+}
+
+// Serves `/readyz`.
+func (h *HealthChecker) ServeReadiness(w http.ResponseWriter, r *http.Request) {
+	serveCheck(w, "ready", h.CheckReadiness())
+}
+
+func serveCheck(w http.ResponseWriter, status string, err error) {
+	w.Header().Set("Content-Type", "text/plain")
+	if err != nil {
+		log.Println("[error] The controller is not", status+":", err)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintln(w, err)
+
+		return
+	}
+
+	fmt.Fprintln(w, status)
+}
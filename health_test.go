@@ -0,0 +1,109 @@
+package k8svamprouter
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+var healthChecker *HealthChecker
+var stuckHandler *StuckEventHandler
+
+// Handles the events only once released.
+type StuckEventHandler struct {
+	release chan struct{}
+}
+
+func (h *StuckEventHandler) OnObjectUpdated(object KubernetesBackendObject) {
+	<-h.release
+}
+
+func (h *StuckEventHandler) OnObjectDeleted(object KubernetesBackendObject) {
+	<-h.release
+}
+
+func ReleaseStuckHandler() {
+	if stuckHandler != nil {
+		close(stuckHandler.release)
+		stuckHandler = nil
+	}
+}
+
+func theHealthOfTheControllerIsCheckedWithARouterSilenceOfMilliseconds(silence int) error {
+	healthChecker = &HealthChecker{
+		Watchers:         []*ObjectWatcher{serviceWatcher},
+		RouterClient:     routeManager.RouterClient,
+		RouterMaxSilence: time.Duration(silence) * time.Millisecond,
+	}
+
+	return nil
+}
+
+func theRoutesAreReconciledAtStartup() error {
+	reconciler := &Reconciler{
+		RouterClient: routeManager.RouterClient,
+		RouteLocks:   routeManager.RouteLocks,
+		Ownership:    routeManager.Ownership,
+		Health:       healthChecker,
+		Sources: []ReconciliationSource{
+			ReconciliationSource{
+				ObjectLister: repository,
+				RouteManager: routeManager,
+			},
+		},
+	}
+
+	reconciler.ReconcileAndLog()
+
+	return nil
+}
+
+func theWatcherOfTheKsServicesIsDeadAfterMilliseconds(timeout int) error {
+	serviceWatcher.LivenessTimeout = time.Duration(timeout) * time.Millisecond
+
+	return nil
+}
+
+func theHandlingOfTheKsServicesIsStuck() error {
+	stuckHandler = &StuckEventHandler{
+		release: make(chan struct{}),
+	}
+
+	serviceWatcher.Handler = stuckHandler
+	go serviceWatcher.ListAndWatch(make(chan struct{}))
+
+	return nil
+}
+
+func GetHealthStatus(handler http.HandlerFunc) int {
+	recorder := httptest.NewRecorder()
+	handler(recorder, nil)
+
+	return recorder.Code
+}
+
+func ExpectHealthStatus(name string, handler http.HandlerFunc, expectedStatus int) error {
+	if status := GetHealthStatus(handler); status != expectedStatus {
+		return errors.New(fmt.Sprintf("The %s check answered %d while expecting %d", name, status, expectedStatus))
+	}
+
+	return nil
+}
+
+func theControllerShouldBeAlive() error {
+	return ExpectHealthStatus("liveness", healthChecker.ServeLiveness, http.StatusOK)
+}
+
+func theControllerShouldNotBeAlive() error {
+	return ExpectHealthStatus("liveness", healthChecker.ServeLiveness, http.StatusServiceUnavailable)
+}
+
+func theControllerShouldBeReady() error {
+	return ExpectHealthStatus("readiness", healthChecker.ServeReadiness, http.StatusOK)
+}
+
+func theControllerShouldNotBeReady() error {
+	return ExpectHealthStatus("readiness", healthChecker.ServeReadiness, http.StatusServiceUnavailable)
+}
@@ -0,0 +1,20 @@
+package k8svamprouter
+
+import (
+	"net"
+	"net/http"
+)
+
+// Redirects permanently the HTTP requests it receives from the router to
+// the same URL with the HTTPS scheme.
+type HttpsRedirectHandler struct {
+}
+
+func (h *HttpsRedirectHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	host := r.Host
+	if hostWithoutPort, _, err := net.SplitHostPort(host); err == nil {
+		host = hostWithoutPort
+	}
+
+	http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+}
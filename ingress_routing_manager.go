@@ -3,14 +3,37 @@ package k8svamprouter
 import (
 	"log"
 	"fmt"
+	"net"
+	"strconv"
+	"strings"
 
 	v1beta1 "k8s.io/client-go/pkg/apis/extensions/v1beta1"
 	client "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/util/intstr"
+)
+
+// When "true", the HTTP requests for the TLS hosts of the ingress are
+// redirected to HTTPS.
+const HttpsRedirectAnnotation = "vamp-router/https-redirect"
+
+// Keys of the certificate and the private key in the TLS secrets
+const (
+	TlsCertificateKey = "tls.crt"
+	TlsPrivateKeyKey  = "tls.key"
 )
 
 type IngressRoutingManagerConfiguration struct {
 	RootDns string
 	IngressType string
+
+	// Address (`host:port`) of the server redirecting the HTTP requests to
+	// HTTPS, as reachable from the router
+	HttpsRedirectBackend string
+
+	// When set, the TLS sections of the ingresses are ignored, such as when
+	// their certificates can't be provisioned: their hosts are only routed
+	// through HTTP
+	IgnoreTls bool
 }
 
 type IngressRoutingManager struct {
@@ -35,15 +58,16 @@ func (irm *IngressRoutingManager) ShouldHandleObject(object KubernetesBackendObj
 }
 
 func (irm *IngressRoutingManager) GetDomainNames(object KubernetesBackendObject) ([]string, error) {
-	ingress, ok := object.(*v1beta1.Ingress)
-	if !ok {
-		return nil, fmt.Errorf("Get get only from `Ingresss` objects")
+	rules, err := irm.GetRoutingRules(object)
+	if err != nil {
+		return nil, err
 	}
 
-	domainSeparator := GetDomainSeparator()
-
-	domainNames := []string{
-		GetRouteNameFromObjectMetadata(ingress.ObjectMeta, domainSeparator)+irm.Configuration.RootDns,
+	domainNames := []string{}
+	for _, rule := range rules {
+		if !ContainsString(domainNames, rule.Host) {
+			domainNames = append(domainNames, rule.Host)
+		}
 	}
 
 	return domainNames, nil
@@ -59,13 +83,346 @@ func (irm *IngressRoutingManager) GetRouteName(object KubernetesBackendObject) (
 
 }
 
-func (irm *IngressRoutingManager) GetBackendAddress(object KubernetesBackendObject) (string, error) {
+func (irm *IngressRoutingManager) GetBackendAddress(object KubernetesBackendObject) (string, int, error) {
+	ingress, ok := object.(*v1beta1.Ingress)
+	if !ok {
+		return "", 0, fmt.Errorf("Get get only from `Ingress` objects")
+	}
+
+	if ingress.Spec.Backend == nil {
+		return "", 0, fmt.Errorf("The ingress %s has no default backend", ingress.ObjectMeta.Name)
+	}
+
+	backend, err := irm.GetIngressBackend(ingress, *ingress.Spec.Backend)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return backend.Address, backend.Port, nil
+}
+
+// The requests for the TLS hosts of the ingresses having the redirect
+// annotation are sent to the redirect server instead of their backends.
+func (irm *IngressRoutingManager) GetRoutingRules(object KubernetesBackendObject) ([]RoutingRule, error) {
+	ingress, ok := object.(*v1beta1.Ingress)
+	if !ok {
+		return nil, fmt.Errorf("Get get only from `Ingress` objects")
+	}
+
+	rules, err := irm.GetIngressRoutingRules(ingress)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsHosts := irm.GetTlsHosts(ingress)
+	if ingress.ObjectMeta.Annotations[HttpsRedirectAnnotation] != "true" || len(tlsHosts) == 0 {
+		return rules, nil
+	}
+
+	redirectBackend, err := irm.GetHttpsRedirectBackend(ingress)
+	if err != nil {
+		return nil, err
+	}
+
+	for index, rule := range rules {
+		if ContainsString(tlsHosts, rule.Host) {
+			rules[index].Backend = redirectBackend
+		}
+	}
+
+	return rules, nil
+}
+
+// Only the rules whose host is listed in the TLS section of the ingress are
+// routed through HTTPS.
+func (irm *IngressRoutingManager) GetHttpsRoutingRules(object KubernetesBackendObject) ([]RoutingRule, error) {
+	ingress, ok := object.(*v1beta1.Ingress)
+	if !ok {
+		return nil, fmt.Errorf("Get get only from `Ingress` objects")
+	}
+
+	tlsHosts := irm.GetTlsHosts(ingress)
+	if len(tlsHosts) == 0 {
+		return []RoutingRule{}, nil
+	}
+
+	rules, err := irm.GetIngressRoutingRules(ingress)
+	if err != nil {
+		return nil, err
+	}
+
+	httpsRules := []RoutingRule{}
+	for _, rule := range rules {
+		if ContainsString(tlsHosts, rule.Host) {
+			httpsRules = append(httpsRules, rule)
+		}
+	}
+
+	return httpsRules, nil
+}
+
+func (irm *IngressRoutingManager) GetTlsSections(ingress *v1beta1.Ingress) []v1beta1.IngressTLS {
+	if irm.Configuration.IgnoreTls {
+		return nil
+	}
+
+	return ingress.Spec.TLS
+}
+
+// The TLS entries without host apply to the generated domain name.
+func (irm *IngressRoutingManager) GetTlsHosts(ingress *v1beta1.Ingress) []string {
+	hosts := []string{}
+	for _, tls := range irm.GetTlsSections(ingress) {
+		tlsHosts := tls.Hosts
+		if len(tlsHosts) == 0 {
+			tlsHosts = []string{irm.GetDefaultDomainName(ingress)}
+		}
+
+		for _, host := range tlsHosts {
+			if !ContainsString(hosts, host) {
+				hosts = append(hosts, host)
+			}
+		}
+	}
+
+	return hosts
+}
+
+// Reads the certificates from the secrets referenced by the TLS section of
+// the ingress.
+func (irm *IngressRoutingManager) GetCertificates(object KubernetesBackendObject) ([]Certificate, error) {
+	ingress, ok := object.(*v1beta1.Ingress)
+	if !ok {
+		return nil, fmt.Errorf("Get get only from `Ingress` objects")
+	}
+
+	certificates := []Certificate{}
+	for _, tls := range irm.GetTlsSections(ingress) {
+		if tls.SecretName == "" {
+			continue
+		}
+
+		secret, err := irm.KubernetesClient.CoreV1().Secrets(ingress.ObjectMeta.Namespace).Get(tls.SecretName)
+		if err != nil {
+			return nil, err
+		}
+
+		certificate, foundCertificate := secret.Data[TlsCertificateKey]
+		key, foundKey := secret.Data[TlsPrivateKeyKey]
+		if !foundCertificate || !foundKey {
+			return nil, fmt.Errorf("The secret %s needs the `%s` and `%s` keys", tls.SecretName, TlsCertificateKey, TlsPrivateKeyKey)
+		}
+
+		certificates = append(certificates, Certificate{
+			Name:        GetIngressCertificateName(ingress, tls.SecretName),
+			Certificate: certificate,
+			Key:         key,
+		})
+	}
+
+	return certificates, nil
+}
+
+func (irm *IngressRoutingManager) GetCertificateNamePrefix(object KubernetesBackendObject) (string, error) {
 	ingress, ok := object.(*v1beta1.Ingress)
 	if !ok {
 		return "", fmt.Errorf("Get get only from `Ingress` objects")
 	}
 
-	return ingress.Spec.Backend.ServiceName+"."+ingress.ObjectMeta.Namespace+".svc.cluster.local", nil
+	return GetIngressCertificateNamePrefix(ingress), nil
+}
+
+func (irm *IngressRoutingManager) IsCertificateName(name string) bool {
+	return strings.HasPrefix(name, IngressCertificateNamePrefix)
+}
+
+// The TLS connections for a host are passed through to the backend of its
+// first rule.
+func (irm *IngressRoutingManager) GetTlsPassthroughBackend(object KubernetesBackendObject, domainName string) (Backend, error) {
+	ingress, ok := object.(*v1beta1.Ingress)
+	if !ok {
+		return Backend{}, fmt.Errorf("Get get only from `Ingress` objects")
+	}
+
+	rules, err := irm.GetIngressRoutingRules(ingress)
+	if err != nil {
+		return Backend{}, err
+	}
+
+	for _, rule := range rules {
+		if rule.Host == domainName {
+			return rule.Backend, nil
+		}
+	}
+
+	return Backend{}, fmt.Errorf("The ingress %s has no backend for the host %s", ingress.ObjectMeta.Name, domainName)
+}
+
+// The certificates are named after the namespace and the name of the ingress,
+// and their secret. The names of the Kubernetes objects can't contain `_`, so
+// the certificates of an ingress are the ones starting with its prefix, and the
+// certificates written by the controller the ones starting with
+// `IngressCertificateNamePrefix`.
+const IngressCertificateNamePrefix = "ingress_"
+
+func GetIngressCertificateName(ingress *v1beta1.Ingress, secretName string) string {
+	return GetIngressCertificateNamePrefix(ingress) + secretName
+}
+
+func GetIngressCertificateNamePrefix(ingress *v1beta1.Ingress) string {
+	return IngressCertificateNamePrefix + ingress.ObjectMeta.Namespace + "_" + ingress.ObjectMeta.Name + "_"
+}
+
+func GetIngressHttpsRedirectBackendName(ingress *v1beta1.Ingress) string {
+	return GetDNSIdentifier(GetRouteNameFromObjectMetadata(ingress.ObjectMeta, GetDomainSeparator()) + "-https-redirect")
+}
+
+func (irm *IngressRoutingManager) GetHttpsRedirectBackend(ingress *v1beta1.Ingress) (Backend, error) {
+	if irm.Configuration.HttpsRedirectBackend == "" {
+		return Backend{}, fmt.Errorf("No HTTPS redirect backend is configured")
+	}
+
+	host, port, err := net.SplitHostPort(irm.Configuration.HttpsRedirectBackend)
+	if err != nil {
+		return Backend{}, err
+	}
+
+	portNumber, err := strconv.Atoi(port)
+	if err != nil {
+		return Backend{}, err
+	}
+
+	return Backend{
+		Name:    GetIngressHttpsRedirectBackendName(ingress),
+		Address: host,
+		Port:    portNumber,
+	}, nil
+}
+
+func (irm *IngressRoutingManager) GetDefaultDomainName(ingress *v1beta1.Ingress) string {
+	return GetRouteNameFromObjectMetadata(ingress.ObjectMeta, GetDomainSeparator()) + irm.Configuration.RootDns
+}
+
+// The default backend is routed from the generated domain name, as are the
+// rules without host. Each path of the rules is routed to a backend named
+// after the ingress and the service it sends the traffic to.
+func (irm *IngressRoutingManager) GetIngressRoutingRules(ingress *v1beta1.Ingress) ([]RoutingRule, error) {
+	defaultDomainName := irm.GetDefaultDomainName(ingress)
+
+	rules := []RoutingRule{}
+	if ingress.Spec.Backend != nil {
+		backend, err := irm.GetIngressBackend(ingress, *ingress.Spec.Backend)
+		if err != nil {
+			return nil, err
+		}
+
+		rules = append(rules, RoutingRule{
+			Host:    defaultDomainName,
+			Backend: backend,
+		})
+	}
+
+	for _, rule := range ingress.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+
+		host := rule.Host
+		if host == "" {
+			host = defaultDomainName
+		}
+
+		for _, path := range rule.HTTP.Paths {
+			backend, err := irm.GetIngressBackend(ingress, path.Backend)
+			if err != nil {
+				return nil, err
+			}
+
+			rules = append(rules, RoutingRule{
+				Host:    host,
+				Path:    path.Path,
+				Backend: backend,
+			})
+		}
+	}
+
+	return rules, nil
+}
+
+func (irm *IngressRoutingManager) GetBackendNames(object KubernetesBackendObject) ([]string, error) {
+	ingress, ok := object.(*v1beta1.Ingress)
+	if !ok {
+		return nil, fmt.Errorf("Get get only from `Ingress` objects")
+	}
+
+	backendNames := []string{
+		GetRouteNameFromObjectMetadata(ingress.ObjectMeta, GetDomainSeparator()),
+		GetIngressHttpsRedirectBackendName(ingress),
+	}
+
+	for _, rule := range ingress.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+
+		for _, path := range rule.HTTP.Paths {
+			backendName := GetIngressBackendName(ingress, path.Backend)
+			if !ContainsString(backendNames, backendName) {
+				backendNames = append(backendNames, backendName)
+			}
+		}
+	}
+
+	return backendNames, nil
+}
+
+// The default backend of the ingress is named after the ingress, so are the
+// other backends suffixed by their service name and port.
+func GetIngressBackendName(ingress *v1beta1.Ingress, ingressBackend v1beta1.IngressBackend) string {
+	backendName := GetRouteNameFromObjectMetadata(ingress.ObjectMeta, GetDomainSeparator())
+
+	defaultBackend := ingress.Spec.Backend
+	if defaultBackend == nil || defaultBackend.ServiceName != ingressBackend.ServiceName || defaultBackend.ServicePort != ingressBackend.ServicePort {
+		backendName = GetDNSIdentifier(strings.Join([]string{
+			backendName,
+			ingressBackend.ServiceName,
+			ingressBackend.ServicePort.String(),
+		}, "-"))
+	}
+
+	return backendName
+}
+
+func (irm *IngressRoutingManager) GetIngressBackend(ingress *v1beta1.Ingress, ingressBackend v1beta1.IngressBackend) (Backend, error) {
+	port, err := irm.ResolveServicePort(ingress.ObjectMeta.Namespace, ingressBackend)
+	if err != nil {
+		return Backend{}, err
+	}
+
+	return Backend{
+		Name:    GetIngressBackendName(ingress, ingressBackend),
+		Address: GetServiceAddress(ingressBackend.ServiceName, ingress.ObjectMeta.Namespace),
+		Port:    port,
+	}, nil
+}
+
+// Named ports are resolved through the spec of the backend service.
+func (irm *IngressRoutingManager) ResolveServicePort(namespace string, ingressBackend v1beta1.IngressBackend) (int, error) {
+	if ingressBackend.ServicePort.Type == intstr.Int {
+		return ingressBackend.ServicePort.IntValue(), nil
+	}
+
+	service, err := irm.KubernetesClient.CoreV1().Services(namespace).Get(ingressBackend.ServiceName)
+	if err != nil {
+		return 0, err
+	}
+
+	servicePort, err := GetServicePort(service, ingressBackend.ServicePort)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(servicePort.Port), nil
 }
 
 func (irm *IngressRoutingManager) UpdateObjectWithDomainNames(object KubernetesBackendObject, domainNames []string) error {
@@ -0,0 +1,503 @@
+package k8svamprouter
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/DATA-DOG/godog/gherkin"
+	"github.com/sroze/kubernetes-vamp-router/vamprouter"
+	api "k8s.io/client-go/pkg/api/v1"
+	v1beta1 "k8s.io/client-go/pkg/apis/extensions/v1beta1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+var ingressRouteManager *VampRouteManager
+var ingresses map[string]*v1beta1.Ingress
+var certificateStore *InMemoryCertificateStore
+
+type InMemoryCertificateStore struct {
+	Certificates map[string]Certificate
+}
+
+func (store *InMemoryCertificateStore) StoreCertificate(certificate Certificate) error {
+	store.Certificates[certificate.Name] = certificate
+
+	return nil
+}
+
+func (store *InMemoryCertificateStore) RemoveCertificate(name string) error {
+	delete(store.Certificates, name)
+
+	return nil
+}
+
+func (store *InMemoryCertificateStore) ListCertificateNames() ([]string, error) {
+	names := []string{}
+	for name := range store.Certificates {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names, nil
+}
+
+func NewIngressRouteManager(routerClient *InMemoryVampRouterClient) {
+	ingresses = make(map[string]*v1beta1.Ingress)
+	certificateStore = &InMemoryCertificateStore{
+		Certificates: make(map[string]Certificate),
+	}
+
+	ingressRouteManager = &VampRouteManager{
+		RouterClient:     routerClient,
+		CertificateStore: certificateStore,
+		ObjectRoutingResolver: &IngressRoutingManager{
+			KubernetesClient: fake.NewSimpleClientset(),
+			Configuration: IngressRoutingManagerConfiguration{
+				RootDns:     ".example.com",
+				IngressType: "vamp-router",
+			},
+		},
+	}
+}
+
+func GetOrCreateIngress(name string) *v1beta1.Ingress {
+	ingress, found := ingresses[name]
+	if !found {
+		ingress = &v1beta1.Ingress{
+			ObjectMeta: api.ObjectMeta{
+				Name: name,
+				Annotations: map[string]string{
+					"kubernetes.io/ingress.class": "vamp-router",
+				},
+			},
+		}
+
+		ingresses[name] = ingress
+	}
+
+	return ingress
+}
+
+// FEATURES
+func theKsIngressIsInTheNamespace(ingressName string, namespace string) error {
+	ingress := GetOrCreateIngress(ingressName)
+	ingress.ObjectMeta.Namespace = namespace
+
+	return nil
+}
+
+func theKsIngressHasTheDefaultBackendOnThePort(ingressName string, serviceName string, port string) error {
+	ingress := GetOrCreateIngress(ingressName)
+	ingress.Spec.Backend = &v1beta1.IngressBackend{
+		ServiceName: serviceName,
+		ServicePort: ParseServicePort(port),
+	}
+
+	return nil
+}
+
+func theKsIngressHasTheFollowingRules(ingressName string, rulesTable *gherkin.DataTable) error {
+	ingress := GetOrCreateIngress(ingressName)
+
+	for i, row := range rulesTable.Rows {
+		if i == 0 {
+			// Skip the headers
+			continue
+		}
+
+		host := row.Cells[0].Value
+		path := v1beta1.HTTPIngressPath{
+			Path: row.Cells[1].Value,
+			Backend: v1beta1.IngressBackend{
+				ServiceName: row.Cells[2].Value,
+				ServicePort: ParseServicePort(row.Cells[3].Value),
+			},
+		}
+
+		var rule *v1beta1.IngressRule
+		for index := range ingress.Spec.Rules {
+			if ingress.Spec.Rules[index].Host == host {
+				rule = &ingress.Spec.Rules[index]
+			}
+		}
+
+		if rule == nil {
+			ingress.Spec.Rules = append(ingress.Spec.Rules, v1beta1.IngressRule{
+				Host: host,
+				IngressRuleValue: v1beta1.IngressRuleValue{
+					HTTP: &v1beta1.HTTPIngressRuleValue{},
+				},
+			})
+
+			rule = &ingress.Spec.Rules[len(ingress.Spec.Rules)-1]
+		}
+
+		rule.HTTP.Paths = append(rule.HTTP.Paths, path)
+	}
+
+	return nil
+}
+
+func theKsServiceInTheNamespaceExposesThePortNamed(serviceName string, namespace string, port int, portName string) error {
+	resolver := ingressRouteManager.ObjectRoutingResolver.(*IngressRoutingManager)
+
+	_, err := resolver.KubernetesClient.CoreV1().Services(namespace).Create(&api.Service{
+		ObjectMeta: api.ObjectMeta{
+			Name:      serviceName,
+			Namespace: namespace,
+		},
+		Spec: api.ServiceSpec{
+			Ports: []api.ServicePort{
+				api.ServicePort{
+					Name: portName,
+					Port: int32(port),
+				},
+			},
+		},
+	})
+
+	return err
+}
+
+func theKsIngressNamedIsCreated(ingressName string) error {
+	ingress := GetOrCreateIngress(ingressName)
+	resolver := ingressRouteManager.ObjectRoutingResolver.(*IngressRoutingManager)
+
+	_, err := resolver.KubernetesClient.ExtensionsV1beta1().Ingresses(ingress.ObjectMeta.Namespace).Create(ingress)
+	if err != nil {
+		return err
+	}
+
+	return ingressRouteManager.CreateObjectRoute(ingress)
+}
+
+func theKsIngressNoLongerTerminatesTls(ingressName string) error {
+	GetOrCreateIngress(ingressName).Spec.TLS = nil
+
+	return nil
+}
+
+func theKsIngressNamedIsUpdated(ingressName string) error {
+	return ingressRouteManager.UpdateObjectRouting(GetOrCreateIngress(ingressName))
+}
+
+func theKsIngressNamedIsDeleted(ingressName string) error {
+	return ingressRouteManager.RemoveObjectRouting(GetOrCreateIngress(ingressName))
+}
+
+func theKsIngressIsDeletedWhileNotWatching(ingressName string) error {
+	ingress := GetOrCreateIngress(ingressName)
+	resolver := ingressRouteManager.ObjectRoutingResolver.(*IngressRoutingManager)
+
+	return resolver.KubernetesClient.ExtensionsV1beta1().Ingresses(ingress.ObjectMeta.Namespace).Delete(ingressName, nil)
+}
+
+func theCertificatesOfTheIngressesCannotBeProvisioned() error {
+	ingressRouteManager.CertificateStore = nil
+	ingressRouteManager.ObjectRoutingResolver.(*IngressRoutingManager).Configuration.IgnoreTls = true
+
+	return nil
+}
+
+func theKsIngressNamedCannotBeCreated(ingressName string) error {
+	err := theKsIngressNamedIsCreated(ingressName)
+	if err == nil {
+		return errors.New(fmt.Sprintf("The ingress %s was created", ingressName))
+	}
+
+	return nil
+}
+
+func theIngressRoutesAreReconciled() error {
+	resolver := ingressRouteManager.ObjectRoutingResolver.(*IngressRoutingManager)
+	reconciler := &Reconciler{
+		RouterClient: ingressRouteManager.RouterClient,
+		RouteLocks:   ingressRouteManager.RouteLocks,
+		Ownership:    ingressRouteManager.Ownership,
+		Sources: []ReconciliationSource{
+			ReconciliationSource{
+				ObjectLister: &KubernetesIngressRepository{
+					Client: resolver.KubernetesClient,
+				},
+				RouteManager: ingressRouteManager,
+			},
+		},
+	}
+
+	return reconciler.Reconcile()
+}
+
+func theKsIngressHasTheFollowingAnnotations(ingressName string, annotationsTable *gherkin.DataTable) error {
+	ingress := GetOrCreateIngress(ingressName)
+
+	for i, row := range annotationsTable.Rows {
+		if i == 0 {
+			// Skip the headers
+			continue
+		}
+
+		ingress.ObjectMeta.Annotations[row.Cells[0].Value] = row.Cells[1].Value
+	}
+
+	return nil
+}
+
+func theKsIngressTerminatesTLSForTheHostsWithTheSecret(ingressName string, hosts string, secretName string) error {
+	ingress := GetOrCreateIngress(ingressName)
+
+	tls := v1beta1.IngressTLS{
+		SecretName: secretName,
+	}
+
+	if hosts != "" {
+		tls.Hosts = strings.Split(hosts, ",")
+	}
+
+	ingress.Spec.TLS = append(ingress.Spec.TLS, tls)
+
+	return nil
+}
+
+func theKsSecretInTheNamespaceContainsTheCertificateAndTheKey(secretName string, namespace string, certificate string, key string) error {
+	resolver := ingressRouteManager.ObjectRoutingResolver.(*IngressRoutingManager)
+
+	_, err := resolver.KubernetesClient.CoreV1().Secrets(namespace).Create(&api.Secret{
+		ObjectMeta: api.ObjectMeta{
+			Name:      secretName,
+			Namespace: namespace,
+		},
+		Data: map[string][]byte{
+			TlsCertificateKey: []byte(certificate),
+			TlsPrivateKeyKey:  []byte(key),
+		},
+	})
+
+	return err
+}
+
+func theKsSecretInTheNamespaceIsRotatedToTheCertificateAndTheKey(secretName string, namespace string, certificate string, key string) error {
+	resolver := ingressRouteManager.ObjectRoutingResolver.(*IngressRoutingManager)
+
+	secret, err := resolver.KubernetesClient.CoreV1().Secrets(namespace).Get(secretName)
+	if err != nil {
+		return err
+	}
+
+	secret.Data[TlsCertificateKey] = []byte(certificate)
+	secret.Data[TlsPrivateKeyKey] = []byte(key)
+
+	_, err = resolver.KubernetesClient.CoreV1().Secrets(namespace).Update(secret)
+
+	return err
+}
+
+func theCertificateIsStoredByTheOperators(certificateName string) error {
+	return certificateStore.StoreCertificate(Certificate{
+		Name:        certificateName,
+		Certificate: []byte("CERTIFICATE"),
+		Key:         []byte("KEY"),
+	})
+}
+
+func theHTTPSRedirectsAreServedBy(address string) error {
+	resolver := ingressRouteManager.ObjectRoutingResolver.(*IngressRoutingManager)
+	resolver.Configuration.HttpsRedirectBackend = address
+
+	return nil
+}
+
+func theCertificateShouldContainTheCertificateAndTheKey(certificateName string, certificate string, key string) error {
+	stored, found := certificateStore.Certificates[certificateName]
+	if !found {
+		return errors.New(fmt.Sprintf("The certificate %s is not stored", certificateName))
+	}
+
+	if string(stored.Certificate) != certificate || string(stored.Key) != key {
+		return errors.New(fmt.Sprintf("Expected the certificate %s and the key %s, found %s and %s", certificate, key, stored.Certificate, stored.Key))
+	}
+
+	return nil
+}
+
+func theCertificateShouldNotBeStored(certificateName string) error {
+	if _, found := certificateStore.Certificates[certificateName]; found {
+		return errors.New(fmt.Sprintf("The certificate %s is stored", certificateName))
+	}
+
+	return nil
+}
+
+func theHTTPSRedirectOfShouldBe(url string, expectedLocation string) error {
+	recorder := httptest.NewRecorder()
+	(&HttpsRedirectHandler{}).ServeHTTP(recorder, httptest.NewRequest("GET", url, nil))
+
+	if recorder.Code != http.StatusMovedPermanently {
+		return errors.New(fmt.Sprintf("Expected the status %d, found %d", http.StatusMovedPermanently, recorder.Code))
+	}
+
+	location := recorder.Header().Get("Location")
+	if location != expectedLocation {
+		return errors.New(fmt.Sprintf("Expected the location %s, found %s", expectedLocation, location))
+	}
+
+	return nil
+}
+
+func theVampFilterNamedOfTheVampRouteShouldRouteToTheVampService(filterName string, routeName string, serviceName string) error {
+	route, err := routeManager.RouterClient.GetRoute(routeName)
+	if err != nil {
+		return err
+	}
+
+	filter, err := GetCreatedFilterInRoute(route, filterName)
+	if err != nil {
+		return err
+	}
+
+	if filter.Destination != serviceName {
+		return errors.New(fmt.Sprintf("Expected the destination %s, found %s", serviceName, filter.Destination))
+	}
+
+	return nil
+}
+
+func theVampFilterNamedOfTheVampRouteShouldHaveTheCondition(filterName string, routeName string, condition string) error {
+	route, err := routeManager.RouterClient.GetRoute(routeName)
+	if err != nil {
+		return err
+	}
+
+	filter, err := GetCreatedFilterInRoute(route, filterName)
+	if err != nil {
+		return err
+	}
+
+	if filter.Condition != condition {
+		return errors.New(fmt.Sprintf("Expected the condition %s, found %s", condition, filter.Condition))
+	}
+
+	return nil
+}
+
+func theVampFilterNamedOfTheVampRouteShouldNotExist(filterName string, routeName string) error {
+	route, err := routeManager.RouterClient.GetRoute(routeName)
+	if err != nil {
+		return err
+	}
+
+	_, err = GetCreatedFilterInRoute(route, filterName)
+	if err == nil {
+		return errors.New(fmt.Sprintf("The filter %s still exists", filterName))
+	}
+
+	return nil
+}
+
+func theVampFilterNamedShouldHaveTheCondition(filterName string, condition string) error {
+	route, err := routeManager.RouterClient.GetRoute("http")
+	if err != nil {
+		return err
+	}
+
+	filter, err := GetCreatedFilterInRoute(route, filterName)
+	if err != nil {
+		return err
+	}
+
+	if filter.Condition != condition {
+		return errors.New(fmt.Sprintf("Expected the condition %s, found %s", condition, filter.Condition))
+	}
+
+	return nil
+}
+
+func theVampFilterNamedShouldRouteToTheVampService(filterName string, serviceName string) error {
+	return theVampFilterNamedOfTheVampRouteShouldRouteToTheVampService(filterName, "http", serviceName)
+}
+
+func theVampFilterNamedShouldBeBeforeTheVampFilterNamed(filterName string, otherFilterName string) error {
+	route, err := routeManager.RouterClient.GetRoute("http")
+	if err != nil {
+		return err
+	}
+
+	for _, filter := range route.Filters {
+		if filter.Name == filterName {
+			return nil
+		} else if filter.Name == otherFilterName {
+			return errors.New(fmt.Sprintf("The filter %s is before the filter %s", otherFilterName, filterName))
+		}
+	}
+
+	return errors.New(fmt.Sprintf("Filter %s not found", filterName))
+}
+
+// Evaluates the HAProxy conditions of the filters, in their order, like the
+// router would for a request to the given Host header and path.
+func GetFilterRoutingRequest(route *vamprouter.Route, request string) (*vamprouter.Filter, error) {
+	host := strings.SplitN(request, "/", 2)[0]
+	for _, filter := range route.Filters {
+		parts := strings.SplitN(filter.Condition, " -i ", 2)
+		if len(parts) != 2 {
+			return nil, errors.New(fmt.Sprintf("Unsupported condition %s", filter.Condition))
+		}
+
+		input := request
+		if parts[0] == "hdr_reg(host)" {
+			input = host
+		} else if parts[0] != "base_reg" {
+			return nil, errors.New(fmt.Sprintf("Unsupported fetch of the condition %s", filter.Condition))
+		}
+
+		pattern, err := regexp.Compile("(?i)" + parts[1])
+		if err != nil {
+			return nil, err
+		}
+
+		if pattern.MatchString(input) {
+			return &filter, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func theRequestToShouldBeRoutedToTheVampService(request string, serviceName string) error {
+	route, err := routeManager.RouterClient.GetRoute("http")
+	if err != nil {
+		return err
+	}
+
+	filter, err := GetFilterRoutingRequest(route, request)
+	if err != nil {
+		return err
+	} else if filter == nil {
+		return errors.New(fmt.Sprintf("The request to %s is not routed", request))
+	} else if filter.Destination != serviceName {
+		return errors.New(fmt.Sprintf("The request to %s is routed to %s by the filter %s", request, filter.Destination, filter.Name))
+	}
+
+	return nil
+}
+
+func theRequestToShouldNotBeRouted(request string) error {
+	route, err := routeManager.RouterClient.GetRoute("http")
+	if err != nil {
+		return err
+	}
+
+	filter, err := GetFilterRoutingRequest(route, request)
+	if err != nil {
+		return err
+	} else if filter != nil {
+		return errors.New(fmt.Sprintf("The request to %s is routed to %s by the filter %s", request, filter.Destination, filter.Name))
+	}
+
+	return nil
+}
@@ -2,8 +2,15 @@ package k8svamprouter
 
 import (
 	"encoding/json"
+	"fmt"
+	"strconv"
 	api "k8s.io/client-go/pkg/api/v1"
+	v1beta1 "k8s.io/client-go/pkg/apis/extensions/v1beta1"
 	client "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/fields"
+	"k8s.io/client-go/pkg/labels"
+	"k8s.io/client-go/pkg/util/intstr"
+	"k8s.io/client-go/pkg/watch"
 )
 
 type KubernetesServiceRepository struct {
@@ -14,6 +21,138 @@ func (repository *KubernetesServiceRepository) Update(service *api.Service) (*ap
 	return repository.Client.CoreV1().Services(service.ObjectMeta.Namespace).UpdateStatus(service)
 }
 
+// The API server ignores the metadata sent with the status, so the annotation
+// is written with a normal update of the latest version of the service.
+func (repository *KubernetesServiceRepository) UpdateAnnotation(service *api.Service, name string, value string) (*api.Service, error) {
+	services := repository.Client.CoreV1().Services(service.ObjectMeta.Namespace)
+
+	latest, err := services.Get(service.ObjectMeta.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	if latest.ObjectMeta.Annotations == nil {
+		latest.ObjectMeta.Annotations = make(map[string]string)
+	}
+
+	latest.ObjectMeta.Annotations[name] = value
+
+	return services.Update(latest)
+}
+
+func (repository *KubernetesServiceRepository) List() ([]KubernetesBackendObject, string, error) {
+	list, err := repository.Client.CoreV1().Services(api.NamespaceAll).List(api.ListOptions{
+		LabelSelector: labels.Everything().String(),
+		FieldSelector: fields.Everything().String(),
+	})
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	objects := []KubernetesBackendObject{}
+	for index := range list.Items {
+		objects = append(objects, &list.Items[index])
+	}
+
+	return objects, list.ResourceVersion, nil
+}
+
+func (repository *KubernetesServiceRepository) Watch(resourceVersion string) (watch.Interface, error) {
+	return repository.Client.CoreV1().Services(api.NamespaceAll).Watch(api.ListOptions{
+		LabelSelector:   labels.Everything().String(),
+		FieldSelector:   fields.Everything().String(),
+		ResourceVersion: resourceVersion,
+	})
+}
+
+type KubernetesIngressRepository struct {
+	Client client.Interface
+}
+
+func (repository *KubernetesIngressRepository) List() ([]KubernetesBackendObject, string, error) {
+	list, err := repository.Client.ExtensionsV1beta1().Ingresses(api.NamespaceAll).List(api.ListOptions{
+		LabelSelector: labels.Everything().String(),
+		FieldSelector: fields.Everything().String(),
+	})
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	objects := []KubernetesBackendObject{}
+	for index := range list.Items {
+		objects = append(objects, &list.Items[index])
+	}
+
+	return objects, list.ResourceVersion, nil
+}
+
+func (repository *KubernetesIngressRepository) Watch(resourceVersion string) (watch.Interface, error) {
+	return repository.Client.ExtensionsV1beta1().Ingresses(api.NamespaceAll).Watch(api.ListOptions{
+		LabelSelector:   labels.Everything().String(),
+		FieldSelector:   fields.Everything().String(),
+		ResourceVersion: resourceVersion,
+	})
+}
+
+type KubernetesEndpointsRepository struct {
+	Client client.Interface
+}
+
+func (repository *KubernetesEndpointsRepository) GetEndpoints(namespace string, name string) (*api.Endpoints, error) {
+	return repository.Client.CoreV1().Endpoints(namespace).Get(name)
+}
+
+func (repository *KubernetesEndpointsRepository) List() ([]KubernetesBackendObject, string, error) {
+	list, err := repository.Client.CoreV1().Endpoints(api.NamespaceAll).List(api.ListOptions{
+		LabelSelector: labels.Everything().String(),
+		FieldSelector: fields.Everything().String(),
+	})
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	objects := []KubernetesBackendObject{}
+	for index := range list.Items {
+		objects = append(objects, &list.Items[index])
+	}
+
+	return objects, list.ResourceVersion, nil
+}
+
+func (repository *KubernetesEndpointsRepository) Watch(resourceVersion string) (watch.Interface, error) {
+	return repository.Client.CoreV1().Endpoints(api.NamespaceAll).Watch(api.ListOptions{
+		LabelSelector:   labels.Everything().String(),
+		FieldSelector:   fields.Everything().String(),
+		ResourceVersion: resourceVersion,
+	})
+}
+
+func GetObjectMeta(object KubernetesBackendObject) (*api.ObjectMeta, error) {
+	switch typedObject := object.(type) {
+	case *api.Service:
+		return &typedObject.ObjectMeta, nil
+	case *v1beta1.Ingress:
+		return &typedObject.ObjectMeta, nil
+	case *api.Endpoints:
+		return &typedObject.ObjectMeta, nil
+	}
+
+	return nil, fmt.Errorf("Unsupported object of type %T", object)
+}
+
+// Returns the `namespace/name` key identifying the object.
+func GetObjectKey(object KubernetesBackendObject) (string, error) {
+	metadata, err := GetObjectMeta(object)
+	if err != nil {
+		return "", err
+	}
+
+	return metadata.Namespace + "/" + metadata.Name, nil
+}
+
 type KubernetesReverseProxyHostConfiguration struct {
 	Host string `json:"host"`
 }
@@ -43,7 +182,38 @@ func GetDomainNamesFromServiceAnnotations(service *api.Service) []string {
 	return domainNames
 }
 
+// Returns the port of the service matching the given port number or name.
+func GetServicePort(service *api.Service, port intstr.IntOrString) (*api.ServicePort, error) {
+	for index, servicePort := range service.Spec.Ports {
+		if port.Type == intstr.Int && servicePort.Port == port.IntVal {
+			return &service.Spec.Ports[index], nil
+		} else if port.Type == intstr.String && servicePort.Name == port.StrVal {
+			return &service.Spec.Ports[index], nil
+		}
+	}
+
+	return nil, fmt.Errorf("The service %s do not expose the port %s", service.ObjectMeta.Name, port.String())
+}
+
+// Parses a port given either by its number or its name.
+func ParseServicePort(value string) intstr.IntOrString {
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		return intstr.FromString(value)
+	}
+
+	return intstr.FromInt(number)
+}
+
+func GetServiceAddress(serviceName string, namespace string) string {
+	return serviceName + "." + namespace + ".svc.cluster.local"
+}
+
 func CreateLoadBalancerStatusFromDomainNames(domainNames []string) api.LoadBalancerStatus {
+	if len(domainNames) == 0 {
+		return api.LoadBalancerStatus{}
+	}
+
 	return api.LoadBalancerStatus{
 		Ingress: []api.LoadBalancerIngress{
 			api.LoadBalancerIngress{
@@ -2,15 +2,21 @@ package k8svamprouter
 
 import (
 	"errors"
+	"sync"
 	"github.com/DATA-DOG/godog/gherkin"
 	api "k8s.io/client-go/pkg/api/v1"
 )
 
 type InMemoryServiceRepository struct {
 	Services map[string]*api.Service
+
+	mutex sync.Mutex
 }
 
 func (repository *InMemoryServiceRepository) Get(name string) (*api.Service, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
 	service, found := repository.Services[name]
 	if found {
 		return service, nil
@@ -20,11 +26,48 @@ func (repository *InMemoryServiceRepository) Get(name string) (*api.Service, err
 }
 
 func (repository *InMemoryServiceRepository) Update(service *api.Service) (*api.Service, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
 	repository.Services[service.ObjectMeta.Name] = service
 
 	return service, nil
 }
 
+func (repository *InMemoryServiceRepository) UpdateAnnotation(service *api.Service, name string, value string) (*api.Service, error) {
+	latest, err := repository.Get(service.ObjectMeta.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	if latest.ObjectMeta.Annotations == nil {
+		latest.ObjectMeta.Annotations = make(map[string]string)
+	}
+
+	latest.ObjectMeta.Annotations[name] = value
+
+	return repository.Update(latest)
+}
+
+func (repository *InMemoryServiceRepository) List() ([]KubernetesBackendObject, string, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	objects := []KubernetesBackendObject{}
+	for _, service := range repository.Services {
+		objects = append(objects, service)
+	}
+
+	return objects, "", nil
+}
+
+func (repository *InMemoryServiceRepository) Remove(name string) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	delete(repository.Services, name)
+}
+
 func GetOrCreateService(repository *InMemoryServiceRepository, name string) *api.Service {
 	service, err := repository.Get(name)
 	if err != nil {
@@ -69,6 +112,61 @@ func theKsServiceIPIs(serviceName string, IP string) error {
 	return err
 }
 
+func theKsServiceIsALoadBalancerExposingThePort(serviceName string, port int) error {
+	service := GetOrCreateService(repository, serviceName)
+	service.Spec.Type = api.ServiceTypeLoadBalancer
+	service.Spec.Ports = append(service.Spec.Ports, api.ServicePort{
+		Port: int32(port),
+	})
+
+	_, err := repository.Update(service)
+
+	return err
+}
+
+func theKsServiceExposesThePortNamed(serviceName string, port int, portName string) error {
+	service := GetOrCreateService(repository, serviceName)
+	service.Spec.Ports = append(service.Spec.Ports, api.ServicePort{
+		Name: portName,
+		Port: int32(port),
+	})
+
+	_, err := repository.Update(service)
+
+	return err
+}
+
+func theKsServiceNoLongerExposesThePort(serviceName string, port int) error {
+	service := GetOrCreateService(repository, serviceName)
+	servicePorts := []api.ServicePort{}
+	for _, servicePort := range service.Spec.Ports {
+		if servicePort.Port != int32(port) {
+			servicePorts = append(servicePorts, servicePort)
+		}
+	}
+
+	service.Spec.Ports = servicePorts
+
+	_, err := repository.Update(service)
+
+	return err
+}
+
+func theKsServiceIsNoLongerALoadBalancer(serviceName string) error {
+	service := GetOrCreateService(repository, serviceName)
+	service.Spec.Type = api.ServiceTypeClusterIP
+
+	_, err := repository.Update(service)
+
+	return err
+}
+
+func theKsServiceIsDeletedWhileNotWatching(serviceName string) error {
+	repository.Remove(serviceName)
+
+	return nil
+}
+
 func theKsServicehasTheFollowingAnnotations(serviceName string, annotationsTable *gherkin.DataTable) error {
 	service := GetOrCreateService(repository, serviceName)
 	annotations := make(map[string]string)
@@ -0,0 +1,383 @@
+package k8svamprouter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	client "k8s.io/client-go/kubernetes"
+	apierrors "k8s.io/client-go/pkg/api/errors"
+	api "k8s.io/client-go/pkg/api/v1"
+)
+
+// Tells whether this instance of the controller may change the routes and the
+// Kubernetes objects.
+type Leadership interface {
+	IsLeader() bool
+}
+
+var ErrNotLeader = errors.New("This instance of the controller is not the leader")
+
+// The lease held by the leader, renewed until it stops leading.
+type LeaderElectionRecord struct {
+	HolderIdentity       string    `json:"holderIdentity"`
+	LeaseDurationSeconds int       `json:"leaseDurationSeconds"`
+	AcquireTime          time.Time `json:"acquireTime"`
+	RenewTime            time.Time `json:"renewTime"`
+	LeaderTransitions    int       `json:"leaderTransitions"`
+}
+
+// Stores the lease. The updates of a lease that changed since it was read, at
+// the given version, are rejected.
+type LeaseLock interface {
+	// Returns a nil record when there is no lease yet, and an empty version
+	// when the lock itself does not exist
+	Get() (*LeaderElectionRecord, string, error)
+	Create(record LeaderElectionRecord) error
+	Update(record LeaderElectionRecord, version string) error
+
+	// Where the lease is stored, used in the logs
+	Describe() string
+}
+
+// The leader elector acquires the lease when it is free or when its holder did
+// not renew it for `LeaseDuration`, and renews it every `RetryPeriod`. The
+// leader stops leading when it could not renew the lease for `RenewDeadline`,
+// which is shorter than the lease duration so that it stops before another
+// instance takes over.
+//
+// The expiration of a lease is measured from when its last change has been
+// observed, so that the clocks of the instances do not need to agree.
+type LeaderElector struct {
+	Lock     LeaseLock
+	Identity string
+
+	LeaseDuration time.Duration
+	RenewDeadline time.Duration
+	RetryPeriod   time.Duration
+
+	// Called when this instance starts or stops leading. They must return
+	// quickly, as the lease is not renewed meanwhile
+	OnStartedLeading func()
+	OnStoppedLeading func()
+
+	mutex          sync.Mutex
+	leading        bool
+	renewedAt      time.Time
+	observedRecord *LeaderElectionRecord
+	observedAt     time.Time
+}
+
+// Competes for the lease until the `stop` channel is closed, when the lease is
+// released.
+func (e *LeaderElector) Run(stop <-chan struct{}) {
+	log.Println("Competing for the lease", e.Lock.Describe(), "as", e.Identity)
+
+	for {
+		e.TryAcquireOrRenew()
+
+		select {
+		case <-stop:
+			e.Release()
+
+			return
+		case <-time.After(e.RetryPeriod):
+		}
+	}
+}
+
+// Acquires or renews the lease, starting or stopping to lead accordingly.
+// Returns whether this instance leads.
+func (e *LeaderElector) TryAcquireOrRenew() bool {
+	err := e.tryAcquireOrRenew()
+	if err == nil {
+		e.mutex.Lock()
+		e.renewedAt = time.Now()
+		e.mutex.Unlock()
+
+		e.setLeading(true)
+
+		return true
+	} else if err == errLeaseHeld {
+		e.setLeading(false)
+
+		return false
+	}
+
+	log.Println("[error] Unable to acquire or renew the lease", e.Lock.Describe(), err)
+
+	// The leader keeps leading until its renew deadline
+	e.setLeading(e.IsLeader())
+
+	return e.IsLeader()
+}
+
+var errLeaseHeld = errors.New("The lease is held by another instance")
+
+func (e *LeaderElector) tryAcquireOrRenew() error {
+	now := time.Now()
+	record := LeaderElectionRecord{
+		HolderIdentity:       e.Identity,
+		LeaseDurationSeconds: int(e.LeaseDuration / time.Second),
+		AcquireTime:          now,
+		RenewTime:            now,
+	}
+
+	current, version, err := e.Lock.Get()
+	if err != nil {
+		return err
+	} else if current == nil && version == "" {
+		err = e.Lock.Create(record)
+		if err != nil {
+			return err
+		}
+
+		e.observe(&record, now)
+
+		return nil
+	} else if current != nil {
+		e.observe(current, now)
+		if current.HolderIdentity != e.Identity && current.HolderIdentity != "" && !e.observedLeaseExpired(now) {
+			return errLeaseHeld
+		}
+
+		if current.HolderIdentity == e.Identity {
+			record.AcquireTime = current.AcquireTime
+			record.LeaderTransitions = current.LeaderTransitions
+		} else {
+			record.LeaderTransitions = current.LeaderTransitions + 1
+			log.Println("Acquiring the lease", e.Lock.Describe(), "previously held by", current.HolderIdentity)
+		}
+	}
+
+	err = e.Lock.Update(record, version)
+	if err != nil {
+		return err
+	}
+
+	e.observe(&record, now)
+
+	return nil
+}
+
+// Remembers when the lease changed for the last time.
+func (e *LeaderElector) observe(record *LeaderElectionRecord, now time.Time) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.observedRecord == nil || !reflect.DeepEqual(*e.observedRecord, *record) {
+		e.observedRecord = record
+		e.observedAt = now
+	}
+}
+
+func (e *LeaderElector) observedLeaseExpired(now time.Time) bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	return e.observedAt.Add(e.LeaseDuration).Before(now)
+}
+
+// Whether this instance leads and renewed the lease within its renew deadline.
+func (e *LeaderElector) IsLeader() bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	return e.leading && time.Since(e.renewedAt) < e.RenewDeadline
+}
+
+// Returns the identity of the last observed holder of the lease.
+func (e *LeaderElector) GetLeader() string {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.observedRecord == nil {
+		return ""
+	}
+
+	return e.observedRecord.HolderIdentity
+}
+
+// Gives the lease up, so that another instance takes over without waiting for
+// it to expire.
+func (e *LeaderElector) Release() {
+	if !e.IsLeader() {
+		return
+	}
+
+	e.setLeading(false)
+
+	current, version, err := e.Lock.Get()
+	if err != nil || current == nil || current.HolderIdentity != e.Identity {
+		return
+	}
+
+	current.HolderIdentity = ""
+	err = e.Lock.Update(*current, version)
+	if err != nil {
+		log.Println("[error] Unable to release the lease", e.Lock.Describe(), err)
+	}
+}
+
+func (e *LeaderElector) setLeading(leading bool) {
+	e.mutex.Lock()
+	changed := e.leading != leading
+	e.leading = leading
+	e.mutex.Unlock()
+
+	if !changed {
+		return
+	}
+
+	if leading {
+		log.Println("Started leading as", e.Identity)
+		if e.OnStartedLeading != nil {
+			e.OnStartedLeading()
+		}
+	} else {
+		log.Println("Stopped leading as", e.Identity)
+		if e.OnStoppedLeading != nil {
+			e.OnStoppedLeading()
+		}
+	}
+}
+
+// Annotation of the config map holding the lease, as the lock of the Kubernetes
+// components.
+const LeaderElectionRecordAnnotation = "control-plane.alpha.kubernetes.io/leader"
+
+// Stores the lease in an annotation of a config map, the Lease objects not
+// being available. The resource version of the config map rejects the
+// concurrent updates.
+type ConfigMapLeaseLock struct {
+	Client    client.Interface
+	Namespace string
+	Name      string
+}
+
+func (l *ConfigMapLeaseLock) Get() (*LeaderElectionRecord, string, error) {
+	configMap, err := l.Client.CoreV1().ConfigMaps(l.Namespace).Get(l.Name)
+	if apierrors.IsNotFound(err) {
+		return nil, "", nil
+	} else if err != nil {
+		return nil, "", err
+	}
+
+	value, found := configMap.Annotations[LeaderElectionRecordAnnotation]
+	if !found {
+		return nil, configMap.ResourceVersion, nil
+	}
+
+	record := &LeaderElectionRecord{}
+	err = json.Unmarshal([]byte(value), record)
+	if err != nil {
+		return nil, "", fmt.Errorf("Invalid lease in %s: %s", l.Describe(), err)
+	}
+
+	return record, configMap.ResourceVersion, nil
+}
+
+func (l *ConfigMapLeaseLock) Create(record LeaderElectionRecord) error {
+	value, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	_, err = l.Client.CoreV1().ConfigMaps(l.Namespace).Create(&api.ConfigMap{
+		ObjectMeta: api.ObjectMeta{
+			Name:      l.Name,
+			Namespace: l.Namespace,
+			Annotations: map[string]string{
+				LeaderElectionRecordAnnotation: string(value),
+			},
+		},
+	})
+
+	return err
+}
+
+func (l *ConfigMapLeaseLock) Update(record LeaderElectionRecord, version string) error {
+	value, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	configMaps := l.Client.CoreV1().ConfigMaps(l.Namespace)
+	configMap, err := configMaps.Get(l.Name)
+	if err != nil {
+		return err
+	} else if configMap.ResourceVersion != version {
+		return fmt.Errorf("The lease in %s changed since it was read", l.Describe())
+	}
+
+	if configMap.Annotations == nil {
+		configMap.Annotations = make(map[string]string)
+	}
+
+	configMap.Annotations[LeaderElectionRecordAnnotation] = string(value)
+	_, err = configMaps.Update(configMap)
+
+	return err
+}
+
+func (l *ConfigMapLeaseLock) Describe() string {
+	return "configmaps/" + l.Namespace + "/" + l.Name
+}
+
+// Keeps the lease in memory, so that the instances of a single process, such as
+// the tests or a local run, compete for it without a cluster.
+type InMemoryLeaseLock struct {
+	mutex   sync.Mutex
+	record  *LeaderElectionRecord
+	version int
+}
+
+func (l *InMemoryLeaseLock) Get() (*LeaderElectionRecord, string, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.record == nil {
+		return nil, "", nil
+	}
+
+	record := *l.record
+
+	return &record, strconv.Itoa(l.version), nil
+}
+
+func (l *InMemoryLeaseLock) Create(record LeaderElectionRecord) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.record != nil {
+		return errors.New("The lease already exists")
+	}
+
+	l.record = &record
+	l.version++
+
+	return nil
+}
+
+func (l *InMemoryLeaseLock) Update(record LeaderElectionRecord, version string) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.record == nil || strconv.Itoa(l.version) != version {
+		return errors.New("The lease changed since it was read")
+	}
+
+	l.record = &record
+	l.version++
+
+	return nil
+}
+
+func (l *InMemoryLeaseLock) Describe() string {
+	return "memory"
+}
@@ -0,0 +1,135 @@
+package k8svamprouter
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+var leaderElectors map[string]*LeaderElector
+
+func NewReplicaElectors(names []string, lock LeaseLock, leaseDuration time.Duration) {
+	leaderElectors = make(map[string]*LeaderElector)
+	for _, name := range names {
+		leaderElectors[name] = &LeaderElector{
+			Lock:          lock,
+			Identity:      name,
+			LeaseDuration: leaseDuration,
+			RenewDeadline: leaseDuration * 2 / 3,
+			RetryPeriod:   leaseDuration / 5,
+		}
+	}
+}
+
+func GetReplicaElector(name string) (*LeaderElector, error) {
+	elector, found := leaderElectors[name]
+	if !found {
+		return nil, errors.New(fmt.Sprintf("The replica %s does not exist", name))
+	}
+
+	return elector, nil
+}
+
+func theReplicasAndCompeteForALeaseOfMilliseconds(first string, second string, leaseDuration int) error {
+	NewReplicaElectors([]string{first, second}, &InMemoryLeaseLock{}, time.Duration(leaseDuration)*time.Millisecond)
+
+	return nil
+}
+
+func theReplicasAndCompeteForALeaseOfMillisecondsInTheKsConfigMap(first string, second string, leaseDuration int, name string) error {
+	NewReplicaElectors([]string{first, second}, &ConfigMapLeaseLock{
+		Client:    fake.NewSimpleClientset(),
+		Namespace: "default",
+		Name:      name,
+	}, time.Duration(leaseDuration)*time.Millisecond)
+
+	return nil
+}
+
+func theReplicaTriesToAcquireTheLease(name string) error {
+	elector, err := GetReplicaElector(name)
+	if err != nil {
+		return err
+	}
+
+	elector.TryAcquireOrRenew()
+
+	return nil
+}
+
+func theReplicaReleasesTheLease(name string) error {
+	elector, err := GetReplicaElector(name)
+	if err != nil {
+		return err
+	}
+
+	elector.Release()
+
+	return nil
+}
+
+func theReplicaShouldLead(name string) error {
+	elector, err := GetReplicaElector(name)
+	if err != nil {
+		return err
+	} else if !elector.IsLeader() {
+		return errors.New(fmt.Sprintf("The replica %s does not lead, %s does", name, elector.GetLeader()))
+	}
+
+	return nil
+}
+
+func theReplicaShouldNotLead(name string) error {
+	elector, err := GetReplicaElector(name)
+	if err != nil {
+		return err
+	} else if elector.IsLeader() {
+		return errors.New(fmt.Sprintf("The replica %s leads", name))
+	}
+
+	return nil
+}
+
+// The route manager and the work queue of the services are the ones of the
+// replica, the queue syncing the objects only while the replica leads.
+func theServicesAreRoutedByTheReplica(name string) error {
+	elector, err := GetReplicaElector(name)
+	if err != nil {
+		return err
+	}
+
+	routeManager.Leadership = elector
+	elector.OnStartedLeading = serviceWorkQueue.Resume
+	elector.OnStoppedLeading = serviceWorkQueue.Pause
+	if !elector.IsLeader() {
+		serviceWorkQueue.Pause()
+	}
+
+	return nil
+}
+
+func theRoutesAreReconciledByTheReplica(name string) error {
+	elector, err := GetReplicaElector(name)
+	if err != nil {
+		return err
+	}
+
+	reconciler := &Reconciler{
+		RouterClient: routeManager.RouterClient,
+		RouteLocks:   routeManager.RouteLocks,
+		Ownership:    routeManager.Ownership,
+		Leadership:   elector,
+		Sources: []ReconciliationSource{
+			ReconciliationSource{
+				ObjectLister: repository,
+				RouteManager: routeManager,
+			},
+		},
+	}
+
+	reconciler.ReconcileAndLog()
+
+	return nil
+}
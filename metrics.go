@@ -0,0 +1,78 @@
+package k8svamprouter
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	api "k8s.io/client-go/pkg/api/v1"
+	v1beta1 "k8s.io/client-go/pkg/apis/extensions/v1beta1"
+)
+
+var (
+	EventsCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "k8svamprouter_events_total",
+			Help: "Events of the Kubernetes objects processed by the route managers, by kind of object and type of event.",
+		},
+		[]string{"kind", "type"},
+	)
+
+	ReconcileDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name: "k8svamprouter_reconcile_duration_seconds",
+			Help: "Duration of the reconciliations of the shared Vamp routes, by route.",
+		},
+		[]string{"route"},
+	)
+
+	ManagedHosts = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "k8svamprouter_managed_hosts",
+			Help: "Filters of the shared Vamp routes created by the controller, by route.",
+		},
+		[]string{"route"},
+	)
+
+	ManagedBackends = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "k8svamprouter_managed_backends",
+			Help: "Services of the shared Vamp routes created by the controller, by route.",
+		},
+		[]string{"route"},
+	)
+
+	RouteDrift = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "k8svamprouter_route_drift",
+			Help: "Services and filters that differed from the Kubernetes objects at the last reconciliation, by route.",
+		},
+		[]string{"route"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(EventsCount, ReconcileDuration, ManagedHosts, ManagedBackends, RouteDrift)
+}
+
+const (
+	EventTypeUpdated = "updated"
+	EventTypeDeleted = "deleted"
+)
+
+// Returns the kind of the object, as used in the metrics.
+func GetObjectKind(object KubernetesBackendObject) string {
+	switch object.(type) {
+	case *api.Service:
+		return "service"
+	case *v1beta1.Ingress:
+		return "ingress"
+	case *api.Endpoints:
+		return "endpoints"
+	}
+
+	return "unknown"
+}
+
+func RecordOwnershipMetrics(routeName string, ownership *RouteOwnership) {
+	ManagedHosts.WithLabelValues(routeName).Set(float64(len(ownership.Filters)))
+	ManagedBackends.WithLabelValues(routeName).Set(float64(len(ownership.Services)))
+}
@@ -0,0 +1,66 @@
+package k8svamprouter
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sroze/kubernetes-vamp-router/vamprouter"
+)
+
+func init() {
+	vamprouter.Register(prometheus.DefaultRegisterer)
+}
+
+// Forgets the values of the metrics of the controller and of the Vamp Router
+// client.
+func ResetMetrics() {
+	EventsCount.Reset()
+	ReconcileDuration.Reset()
+	ManagedHosts.Reset()
+	ManagedBackends.Reset()
+	RouteDrift.Reset()
+	vamprouter.RequestsCount.Reset()
+	vamprouter.RequestsDuration.Reset()
+}
+
+// Reads the value of the series, as exposed to Prometheus.
+func GetExposedMetric(seriesName string) (string, error) {
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("GET", "/metrics", nil)
+	if err != nil {
+		return "", err
+	}
+
+	promhttp.Handler().ServeHTTP(recorder, request)
+	for _, line := range strings.Split(recorder.Body.String(), "\n") {
+		if strings.HasPrefix(line, seriesName+" ") {
+			return strings.TrimPrefix(line, seriesName+" "), nil
+		}
+	}
+
+	return "", errors.New(fmt.Sprintf("The metric %s is not exposed", seriesName))
+}
+
+func theMetricShouldBe(seriesName string, expectedValue string) error {
+	value, err := GetExposedMetric(seriesName)
+	if err != nil {
+		return err
+	} else if value != expectedValue {
+		return errors.New(fmt.Sprintf("The metric %s is %s while expecting %s", seriesName, value, expectedValue))
+	}
+
+	return nil
+}
+
+func theMetricShouldNotBeExposed(seriesName string) error {
+	if value, err := GetExposedMetric(seriesName); err == nil {
+		return errors.New(fmt.Sprintf("The metric %s is exposed with the value %s", seriesName, value))
+	}
+
+	return nil
+}
@@ -0,0 +1,401 @@
+package k8svamprouter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/sroze/kubernetes-vamp-router/vamprouter"
+
+	client "k8s.io/client-go/kubernetes"
+	apierrors "k8s.io/client-go/pkg/api/errors"
+	api "k8s.io/client-go/pkg/api/v1"
+)
+
+// The Kubernetes object an entry of a shared route has been created for.
+type Owner struct {
+	UID string `json:"uid,omitempty"`
+
+	// `namespace/name` of the object, or name of the weighted route
+	Object string `json:"object"`
+}
+
+func GetObjectOwner(object KubernetesBackendObject) (Owner, error) {
+	objectMeta, err := GetObjectMeta(object)
+	if err != nil {
+		return Owner{}, err
+	}
+
+	key, err := GetObjectKey(object)
+	if err != nil {
+		return Owner{}, err
+	}
+
+	return Owner{
+		UID:    string(objectMeta.UID),
+		Object: key,
+	}, nil
+}
+
+// The backends of the weighted routes are shared by the objects routing their
+// host, so they are owned by the weighted route itself.
+func GetWeightedRouteOwner(routeName string) Owner {
+	return Owner{
+		Object: routeName,
+	}
+}
+
+// The services and the filters of a route created by the controller, by name.
+// The other entries of the route, added by the operators, are never updated
+// nor removed.
+type RouteOwnership struct {
+	Services map[string]Owner `json:"services"`
+	Filters  map[string]Owner `json:"filters"`
+}
+
+func NewRouteOwnership() *RouteOwnership {
+	return &RouteOwnership{
+		Services: make(map[string]Owner),
+		Filters:  make(map[string]Owner),
+	}
+}
+
+func (ownership *RouteOwnership) Copy() *RouteOwnership {
+	copied := NewRouteOwnership()
+	for name, owner := range ownership.Services {
+		copied.Services[name] = owner
+	}
+
+	for name, owner := range ownership.Filters {
+		copied.Filters[name] = owner
+	}
+
+	return copied
+}
+
+func (ownership *RouteOwnership) GetServiceOwner(serviceName string) (Owner, bool) {
+	owner, found := ownership.Services[serviceName]
+
+	return owner, found
+}
+
+func (ownership *RouteOwnership) GetFilterOwner(filterName string) (Owner, bool) {
+	owner, found := ownership.Filters[filterName]
+
+	return owner, found
+}
+
+// Whether the owner has services or filters in the route.
+func (ownership *RouteOwnership) HasEntries(owner Owner) bool {
+	for _, serviceOwner := range ownership.Services {
+		if serviceOwner == owner {
+			return true
+		}
+	}
+
+	for _, filterOwner := range ownership.Filters {
+		if filterOwner == owner {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (ownership *RouteOwnership) ClaimService(serviceName string, owner Owner) {
+	ownership.Services[serviceName] = owner
+}
+
+func (ownership *RouteOwnership) ClaimFilter(filterName string, owner Owner) {
+	ownership.Filters[filterName] = owner
+}
+
+func (ownership *RouteOwnership) ReleaseService(serviceName string) {
+	delete(ownership.Services, serviceName)
+}
+
+func (ownership *RouteOwnership) ReleaseFilter(filterName string) {
+	delete(ownership.Filters, filterName)
+}
+
+// Forgets the entries that are not in the route anymore.
+func (ownership *RouteOwnership) Prune(route *vamprouter.Route) {
+	index := NewRouteIndex(route)
+	for name := range ownership.Services {
+		if _, found := index.GetService(route, name); !found {
+			delete(ownership.Services, name)
+		}
+	}
+
+	for name := range ownership.Filters {
+		if _, found := index.GetFilter(route, name); !found {
+			delete(ownership.Filters, name)
+		}
+	}
+}
+
+// Persists the ownership of the routes, so that the controller still knows
+// its entries once restarted.
+type OwnershipStore interface {
+	Load() (map[string]*RouteOwnership, error)
+	Save(routeName string, ownership *RouteOwnership) error
+}
+
+// The ownership registry gives the ownership of a route to its mutation, and
+// saves the changes made by the mutation only once it succeeded: the entries
+// of a route that could not be updated are neither claimed nor released.
+type OwnershipRegistry struct {
+	// Kept in memory only when nil
+	Store OwnershipStore
+
+	mutex   sync.Mutex
+	loaded  bool
+	routes  map[string]*RouteOwnership
+	pending map[string]*RouteOwnership
+	locks   *RouteLocks
+}
+
+func NewOwnershipRegistry(store OwnershipStore) *OwnershipRegistry {
+	return &OwnershipRegistry{
+		Store:   store,
+		routes:  make(map[string]*RouteOwnership),
+		pending: make(map[string]*RouteOwnership),
+		locks:   NewRouteLocks(),
+	}
+}
+
+var ErrNoOwnershipRegistry = errors.New("No ownership registry is configured")
+
+// Runs the mutation of the route, the changes of the ownership of the route
+// being saved when it succeeds and forgotten otherwise.
+func (registry *OwnershipRegistry) Transaction(routeName string, mutate func() error) error {
+	unlock := registry.locks.Lock(routeName)
+	defer unlock()
+
+	err := registry.begin(routeName)
+	if err != nil {
+		return err
+	}
+
+	err = mutate()
+	if err != nil {
+		registry.rollback(routeName)
+
+		return err
+	}
+
+	return registry.commit(routeName)
+}
+
+// Returns the ownership of the route. Its changes are kept only within a
+// transaction of the route.
+func (registry *OwnershipRegistry) Get(routeName string) *RouteOwnership {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	if ownership, found := registry.pending[routeName]; found {
+		return ownership
+	} else if ownership, found := registry.routes[routeName]; found {
+		return ownership.Copy()
+	}
+
+	return NewRouteOwnership()
+}
+
+// Returns the names of the routes in which the owner has services or filters.
+func (registry *OwnershipRegistry) GetOwnedRouteNames(owner Owner) ([]string, error) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	err := registry.load()
+	if err != nil {
+		return nil, err
+	}
+
+	routeNames := []string{}
+	for routeName, ownership := range registry.routes {
+		if ownership.HasEntries(owner) {
+			routeNames = append(routeNames, routeName)
+		}
+	}
+
+	sort.Strings(routeNames)
+
+	return routeNames, nil
+}
+
+// Loads the ownership of the routes again from the store, such as when another
+// instance of the controller changed it.
+func (registry *OwnershipRegistry) Reload() error {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	registry.loaded = false
+
+	return registry.load()
+}
+
+func (registry *OwnershipRegistry) begin(routeName string) error {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	err := registry.load()
+	if err != nil {
+		return err
+	}
+
+	if ownership, found := registry.routes[routeName]; found {
+		registry.pending[routeName] = ownership.Copy()
+	} else {
+		registry.pending[routeName] = NewRouteOwnership()
+	}
+
+	return nil
+}
+
+// Loads the ownership of the routes unless already loaded. The mutex of the
+// registry must be held.
+func (registry *OwnershipRegistry) load() error {
+	if registry.loaded || registry.Store == nil {
+		registry.loaded = true
+
+		return nil
+	}
+
+	routes, err := registry.Store.Load()
+	if err != nil {
+		return fmt.Errorf("Unable to load the ownership of the routes: %s", err)
+	}
+
+	registry.routes = make(map[string]*RouteOwnership)
+	for name, ownership := range routes {
+		registry.routes[name] = ownership
+		RecordOwnershipMetrics(name, ownership)
+	}
+
+	registry.loaded = true
+
+	return nil
+}
+
+func (registry *OwnershipRegistry) rollback(routeName string) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	delete(registry.pending, routeName)
+}
+
+func (registry *OwnershipRegistry) commit(routeName string) error {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	ownership := registry.pending[routeName]
+	delete(registry.pending, routeName)
+
+	previous, found := registry.routes[routeName]
+	if found && reflect.DeepEqual(previous, ownership) {
+		return nil
+	} else if !found && len(ownership.Services) == 0 && len(ownership.Filters) == 0 {
+		return nil
+	}
+
+	if registry.Store != nil {
+		err := registry.Store.Save(routeName, ownership)
+		if err != nil {
+			log.Println("[error] Unable to save the ownership of the route", routeName, err)
+
+			return err
+		}
+	}
+
+	registry.routes[routeName] = ownership
+	RecordOwnershipMetrics(routeName, ownership)
+
+	return nil
+}
+
+// Size above which the API rejects a config map.
+const MaxConfigMapSize = 1024 * 1024
+
+// Persists the ownership of the routes in a config map, each route in a key.
+// The config map is limited to `MaxConfigMapSize`, that is to around ten
+// thousand hosts and backends.
+type KubernetesOwnershipStore struct {
+	Client    client.Interface
+	Namespace string
+	Name      string
+}
+
+func (store *KubernetesOwnershipStore) Load() (map[string]*RouteOwnership, error) {
+	routes := make(map[string]*RouteOwnership)
+
+	configMap, err := store.Client.CoreV1().ConfigMaps(store.Namespace).Get(store.Name)
+	if apierrors.IsNotFound(err) {
+		return routes, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	for routeName, value := range configMap.Data {
+		ownership := NewRouteOwnership()
+		err = json.Unmarshal([]byte(value), ownership)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid ownership of the route %s: %s", routeName, err)
+		}
+
+		routes[routeName] = ownership
+	}
+
+	return routes, nil
+}
+
+func (store *KubernetesOwnershipStore) Save(routeName string, ownership *RouteOwnership) error {
+	value, err := json.Marshal(ownership)
+	if err != nil {
+		return err
+	}
+
+	configMaps := store.Client.CoreV1().ConfigMaps(store.Namespace)
+	configMap, err := configMaps.Get(store.Name)
+	if apierrors.IsNotFound(err) {
+		_, err = configMaps.Create(&api.ConfigMap{
+			ObjectMeta: api.ObjectMeta{
+				Name:      store.Name,
+				Namespace: store.Namespace,
+			},
+			Data: map[string]string{
+				routeName: string(value),
+			},
+		})
+
+		return err
+	} else if err != nil {
+		return err
+	}
+
+	if configMap.Data == nil {
+		configMap.Data = make(map[string]string)
+	}
+
+	configMap.Data[routeName] = string(value)
+	if size := GetConfigMapDataSize(configMap); size > MaxConfigMapSize {
+		return fmt.Errorf("The ownership of the routes would take %d bytes, more than the %d bytes of a config map", size, MaxConfigMapSize)
+	}
+
+	_, err = configMaps.Update(configMap)
+
+	return err
+}
+
+func GetConfigMapDataSize(configMap *api.ConfigMap) int {
+	size := 0
+	for key, value := range configMap.Data {
+		size += len(key) + len(value)
+	}
+
+	return size
+}
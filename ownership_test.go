@@ -0,0 +1,25 @@
+package k8svamprouter
+
+import (
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func theOwnershipOfTheVampRoutesIsStoredInTheKsConfigMap(name string) error {
+	routeManager.Ownership = NewOwnershipRegistry(&KubernetesOwnershipStore{
+		Client:    fake.NewSimpleClientset(),
+		Namespace: "default",
+		Name:      name,
+	})
+
+	ingressRouteManager.Ownership = routeManager.Ownership
+
+	return nil
+}
+
+// The ownership is loaded again from its store, if any.
+func theControllerIsRestarted() error {
+	routeManager.Ownership = NewOwnershipRegistry(routeManager.Ownership.Store)
+	ingressRouteManager.Ownership = routeManager.Ownership
+
+	return nil
+}
@@ -0,0 +1,116 @@
+package k8svamprouter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Allocates the ports of the router from a range. Each port is allocated to
+// an owner, which gets the same port as long as it does not release it.
+type PortAllocator struct {
+	Min int
+	Max int
+
+	mutex     sync.Mutex
+	allocated map[int]string
+}
+
+func NewPortAllocator(min int, max int) *PortAllocator {
+	return &PortAllocator{
+		Min:       min,
+		Max:       max,
+		allocated: make(map[int]string),
+	}
+}
+
+// Parses a range such as "20000-20999".
+func ParsePortRange(portRange string) (*PortAllocator, error) {
+	bounds := strings.Split(portRange, "-")
+	if len(bounds) != 2 {
+		return nil, fmt.Errorf("The port range %s should look like `min-max`", portRange)
+	}
+
+	min, err := strconv.Atoi(bounds[0])
+	if err != nil {
+		return nil, err
+	}
+
+	max, err := strconv.Atoi(bounds[1])
+	if err != nil {
+		return nil, err
+	}
+
+	if min < 1 || max > 65535 || min > max {
+		return nil, fmt.Errorf("The port range %s is not valid", portRange)
+	}
+
+	return NewPortAllocator(min, max), nil
+}
+
+// Allocates a port to the owner. The port it already owns is returned, else
+// the preferred port if it is free, else the first free port of the range.
+func (pa *PortAllocator) Allocate(owner string, preferredPort int) (int, error) {
+	pa.mutex.Lock()
+	defer pa.mutex.Unlock()
+
+	for port, portOwner := range pa.allocated {
+		if portOwner == owner {
+			return port, nil
+		}
+	}
+
+	if pa.isFree(preferredPort) {
+		pa.allocated[preferredPort] = owner
+
+		return preferredPort, nil
+	}
+
+	for port := pa.Min; port <= pa.Max; port++ {
+		if pa.isFree(port) {
+			pa.allocated[port] = owner
+
+			return port, nil
+		}
+	}
+
+	return 0, fmt.Errorf("No port left in the range %d-%d for %s", pa.Min, pa.Max, owner)
+}
+
+// Reserves the port to the owner if it is free. Returns false otherwise.
+func (pa *PortAllocator) Reserve(owner string, port int) bool {
+	pa.mutex.Lock()
+	defer pa.mutex.Unlock()
+
+	if pa.allocated[port] == owner {
+		return true
+	} else if !pa.isFree(port) {
+		return false
+	}
+
+	pa.allocated[port] = owner
+
+	return true
+}
+
+func (pa *PortAllocator) Release(owner string) {
+	pa.mutex.Lock()
+	defer pa.mutex.Unlock()
+
+	for port, portOwner := range pa.allocated {
+		if portOwner == owner {
+			delete(pa.allocated, port)
+		}
+	}
+}
+
+func (pa *PortAllocator) isFree(port int) bool {
+	if port < pa.Min || port > pa.Max {
+		return false
+	}
+
+	_, allocated := pa.allocated[port]
+
+	return !allocated
+}
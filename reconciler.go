@@ -0,0 +1,383 @@
+package k8svamprouter
+
+import (
+	"log"
+	"time"
+
+	"github.com/sroze/kubernetes-vamp-router/vamprouter"
+)
+
+// Lists the objects, with the resource version of the list.
+type ObjectLister interface {
+	List() ([]KubernetesBackendObject, string, error)
+}
+
+// A reconciliation source gives the objects to route and the route manager
+// that knows how to route them.
+type ReconciliationSource struct {
+	ObjectLister ObjectLister
+	RouteManager *VampRouteManager
+}
+
+// The reconciler computes the HTTP, HTTPS, TLS passthrough and TCP routes from
+// all the Kubernetes objects and converges the Vamp routes to them, so that the
+// events missed by the watchers (while the controller was down, for instance)
+// are eventually applied.
+//
+// Only the services and filters created by the controller are updated or
+// removed: the entries added by the operators are kept as they are.
+type Reconciler struct {
+	// Vamp Router client
+	RouterClient vamprouter.Interface
+
+	// Sources of objects to route
+	Sources []ReconciliationSource
+
+	// Shared with the route managers. Required
+	RouteLocks *RouteLocks
+
+	// Shared with the route managers. Required
+	Ownership *OwnershipRegistry
+
+	// Told when the routes have been reconciled, optional
+	Health *HealthChecker
+
+	// Whether this instance may change the routes, always when nil
+	Leadership Leadership
+}
+
+// Reconciles the route every `interval` until the `stop` channel is closed.
+func (r *Reconciler) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.ReconcileAndLog()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// The standby instances only keep their copy of the routes and of their
+// ownership warm, to take over quickly. Returns the logged error.
+func (r *Reconciler) ReconcileAndLog() error {
+	var err error
+	if r.IsLeader() {
+		err = r.Reconcile()
+	} else {
+		err = r.WarmUp()
+	}
+
+	if err != nil {
+		log.Println("Unable to reconcile the routes", err)
+	} else if r.Health != nil {
+		r.Health.MarkSynced()
+	}
+
+	return err
+}
+
+// Reads the routes and their ownership again, without changing them.
+func (r *Reconciler) WarmUp() error {
+	_, err := r.RouterClient.ListRoutes()
+	if err != nil {
+		return err
+	}
+
+	if r.Ownership == nil {
+		return ErrNoOwnershipRegistry
+	}
+
+	return r.Ownership.Reload()
+}
+
+func (r *Reconciler) IsLeader() bool {
+	return r.Leadership == nil || r.Leadership.IsLeader()
+}
+
+func (r *Reconciler) Reconcile() error {
+	err := r.MutateRoute(HttpRouteName, func() error {
+		route, err := GetOrCreateHttpRoute(r.RouterClient)
+		if err != nil {
+			return err
+		}
+
+		return r.ReconcileRoute(route)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	err = r.ReconcileOptionalRoute(&vamprouter.Route{
+		Name:     HttpsRouteName,
+		Port:     r.GetHttpsRoutePort(),
+		Protocol: vamprouter.ProtocolHttp,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	err = r.ReconcileOptionalRoute(&vamprouter.Route{
+		Name:     TlsPassthroughRouteName,
+		Port:     r.GetTlsPassthroughPort(),
+		Protocol: vamprouter.ProtocolTcp,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	err = r.ReconcileTcpRoutes()
+	if err != nil {
+		return err
+	}
+
+	return r.ReconcileCertificates()
+}
+
+// Writes the certificates of the objects again, so that the rotated secrets
+// are picked up, and removes the ones of the objects no longer routed.
+func (r *Reconciler) ReconcileCertificates() error {
+	for _, source := range r.Sources {
+		objects, _, err := source.ObjectLister.List()
+		if err != nil {
+			return err
+		}
+
+		err = source.RouteManager.ReconcileCertificates(objects)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Converges the TCP routes to the ports of the objects: the missing routes are
+// created, and the routes created by the controller for the objects or ports
+// that are no longer routed are deleted, their router port being released.
+func (r *Reconciler) ReconcileTcpRoutes() error {
+	tcpRouteNames := []string{}
+	for _, source := range r.Sources {
+		objects, _, err := source.ObjectLister.List()
+		if err != nil {
+			return err
+		}
+
+		for _, object := range objects {
+			if !source.RouteManager.ShouldHandleObject(object) {
+				continue
+			}
+
+			routeNames, err := source.RouteManager.UpdateTcpRoutesIfNeeded(object)
+			if err != nil {
+				return err
+			}
+
+			tcpRouteNames = append(tcpRouteNames, routeNames...)
+		}
+	}
+
+	routes, err := r.RouterClient.ListRoutes()
+	if err != nil {
+		return err
+	}
+
+	for _, route := range routes {
+		if !IsTcpRoute(&route) || ContainsString(tcpRouteNames, route.Name) {
+			continue
+		} else if len(r.Ownership.Get(route.Name).Services) == 0 {
+			// Not created by the controller
+			continue
+		}
+
+		// Each source releases the port from its own allocator
+		for _, source := range r.Sources {
+			err = source.RouteManager.RemoveTcpRouteIfNeeded(route.Name)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// The route managers share the port of the HTTPS route.
+func (r *Reconciler) GetHttpsRoutePort() int {
+	for _, source := range r.Sources {
+		if source.RouteManager != nil {
+			return source.RouteManager.GetHttpsRoutePort()
+		}
+	}
+
+	return DefaultHttpsRoutePort
+}
+
+// The route managers share the port of the TLS passthrough route.
+func (r *Reconciler) GetTlsPassthroughPort() int {
+	for _, source := range r.Sources {
+		if source.RouteManager != nil {
+			return source.RouteManager.GetTlsPassthroughPort()
+		}
+	}
+
+	return DefaultTlsPassthroughPort
+}
+
+// Reconciles a route that is created only once there is something to route.
+func (r *Reconciler) ReconcileOptionalRoute(defaultRoute *vamprouter.Route) error {
+	return r.MutateRoute(defaultRoute.Name, func() error {
+		route, err := r.RouterClient.GetRoute(defaultRoute.Name)
+		if err == nil {
+			return r.ReconcileRoute(route)
+		} else if !vamprouter.IsNotFound(err) {
+			return err
+		}
+
+		desiredRoute, err := r.GetDesiredRoute(defaultRoute)
+		if err != nil {
+			return err
+		} else if len(desiredRoute.Filters) == 0 {
+			RouteDrift.WithLabelValues(desiredRoute.Name).Set(0)
+
+			return nil
+		}
+
+		r.Ownership.Get(desiredRoute.Name).Prune(desiredRoute)
+		RouteDrift.WithLabelValues(desiredRoute.Name).Set(float64(CountRouteDifferences(defaultRoute, desiredRoute)))
+
+		log.Println("Creating the route", desiredRoute.Name, "with", len(desiredRoute.Services), "services and", len(desiredRoute.Filters), "filters")
+		_, err = r.RouterClient.CreateRoute(desiredRoute)
+
+		return err
+	})
+}
+
+func (r *Reconciler) ReconcileRoute(route *vamprouter.Route) error {
+	ownership := r.Ownership.Get(route.Name)
+	previousOwnership := ownership.Copy()
+
+	desiredRoute, err := r.GetDesiredRoute(route)
+	if err != nil {
+		return err
+	}
+
+	KeepUnownedEntries(route, desiredRoute, previousOwnership, ownership)
+	ownership.Prune(desiredRoute)
+	RouteDrift.WithLabelValues(route.Name).Set(float64(CountRouteDifferences(route, desiredRoute)))
+
+	if RoutesHaveSameRouting(route, desiredRoute) {
+		log.Println("The route", route.Name, "is up to date")
+
+		return nil
+	}
+
+	log.Println("Reconciling the route", route.Name, "with", len(desiredRoute.Services), "services and", len(desiredRoute.Filters), "filters")
+	_, err = r.RouterClient.UpdateRoute(desiredRoute)
+
+	return err
+}
+
+// Computes the route as it should be based on the objects of every source. The
+// settings of the route, such as its port or quotas, are kept from the current
+// route.
+func (r *Reconciler) GetDesiredRoute(route *vamprouter.Route) (*vamprouter.Route, error) {
+	desiredRoute := &vamprouter.Route{
+		Name:      route.Name,
+		Port:      route.Port,
+		Protocol:  route.Protocol,
+		HttpQuota: route.HttpQuota,
+		TcpQuota:  route.TcpQuota,
+		Filters:   []vamprouter.Filter{},
+		Services:  []vamprouter.Service{},
+	}
+
+	for _, source := range r.Sources {
+		objects, _, err := source.ObjectLister.List()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, object := range objects {
+			if !source.RouteManager.ShouldHandleObject(object) {
+				continue
+			}
+
+			switch route.Name {
+			case HttpsRouteName:
+				_, err = source.RouteManager.ApplyObjectHttpsRouting(desiredRoute, object)
+			case TlsPassthroughRouteName:
+				_, err = source.RouteManager.ApplyObjectTlsPassthroughRouting(desiredRoute, object)
+			default:
+				_, _, err = source.RouteManager.ApplyObjectRouting(desiredRoute, object)
+			}
+
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return desiredRoute, nil
+}
+
+// Keeps in the desired route the entries of the current route that were not
+// created by the controller. Such an entry having the name of a desired one is
+// adopted when it routes to the same backend, and kept as it is otherwise.
+func KeepUnownedEntries(route *vamprouter.Route, desiredRoute *vamprouter.Route, previousOwnership *RouteOwnership, ownership *RouteOwnership) {
+	index := NewRouteIndex(desiredRoute)
+	for _, service := range route.Services {
+		if _, owned := previousOwnership.GetServiceOwner(service.Name); owned {
+			continue
+		} else if _, found := index.GetService(desiredRoute, service.Name); !found {
+			index.AddService(desiredRoute, service)
+		}
+	}
+
+	filtersKept := false
+	for _, filter := range route.Filters {
+		if _, owned := previousOwnership.GetFilterOwner(filter.Name); owned {
+			continue
+		}
+
+		desiredFilter, found := index.GetFilter(desiredRoute, filter.Name)
+		if found && desiredFilter.Destination == filter.Destination {
+			continue
+		} else if found {
+			log.Println("[error] The filter", filter.Name, "to", filter.Destination, "was not created by the controller, not routing it to", desiredFilter.Destination)
+			ownership.ReleaseFilter(filter.Name)
+		}
+
+		index.PutFilter(desiredRoute, filter)
+		filtersKept = true
+	}
+
+	if filtersKept {
+		SortFiltersBySpecificity(desiredRoute)
+	}
+}
+
+func (r *Reconciler) MutateRoute(routeName string, mutate func() error) error {
+	if r.Ownership == nil {
+		return ErrNoOwnershipRegistry
+	}
+
+	return MutateRoute(r.RouteLocks, routeName, func() error {
+		if !r.IsLeader() {
+			return ErrNotLeader
+		}
+
+		start := time.Now()
+		defer func() {
+			ReconcileDuration.WithLabelValues(routeName).Observe(time.Since(start).Seconds())
+		}()
+
+		return r.Ownership.Transaction(routeName, mutate)
+	})
+}
@@ -0,0 +1,228 @@
+package k8svamprouter
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/sroze/kubernetes-vamp-router/vamprouter"
+)
+
+// The route cache keeps an indexed copy of the routes read from the router, so
+// that the events do not all start with a round trip to the router to get a
+// route having thousands of filters.
+//
+// The writes go through the cache, which therefore has to be shared by
+// everything writing the routes. A route is read again from the router once
+// its copy is older than `MaxAge` or when a write of the route failed, the
+// copy being probably out of date.
+type RouteCache struct {
+	vamprouter.Interface
+
+	// Age after which a route is read again from the router
+	MaxAge time.Duration
+
+	mutex  sync.Mutex
+	routes map[string]*cachedRoute
+}
+
+type cachedRoute struct {
+	route  *vamprouter.Route
+	index  *RouteIndex
+	readAt time.Time
+}
+
+func NewRouteCache(routerClient vamprouter.Interface, maxAge time.Duration) *RouteCache {
+	return &RouteCache{
+		Interface: routerClient,
+		MaxAge:    maxAge,
+		routes:    make(map[string]*cachedRoute),
+	}
+}
+
+// Returns a copy of the cached route, that can be modified by the caller.
+func (c *RouteCache) GetRoute(name string) (*vamprouter.Route, error) {
+	c.mutex.Lock()
+	cached, found := c.routes[name]
+	if found && time.Since(cached.readAt) < c.MaxAge {
+		route := CopyRoute(cached.route)
+		c.mutex.Unlock()
+
+		return route, nil
+	}
+	c.mutex.Unlock()
+
+	route, err := c.Interface.GetRoute(name)
+	if err != nil {
+		c.Invalidate(name)
+
+		return nil, err
+	}
+
+	c.store(route)
+
+	return CopyRoute(route), nil
+}
+
+func (c *RouteCache) ListRoutes() ([]vamprouter.Route, error) {
+	routes, err := c.Interface.ListRoutes()
+	if err != nil {
+		return nil, err
+	}
+
+	for index := range routes {
+		c.store(&routes[index])
+	}
+
+	return routes, nil
+}
+
+func (c *RouteCache) CreateRoute(route *vamprouter.Route) (*vamprouter.Route, error) {
+	created, err := c.Interface.CreateRoute(route)
+
+	return created, c.afterRouteWrite(route.Name, created, err)
+}
+
+func (c *RouteCache) UpdateRoute(route *vamprouter.Route) (*vamprouter.Route, error) {
+	updated, err := c.Interface.UpdateRoute(route)
+
+	return updated, c.afterRouteWrite(route.Name, updated, err)
+}
+
+func (c *RouteCache) DeleteRoute(name string) error {
+	defer c.Invalidate(name)
+
+	return c.Interface.DeleteRoute(name)
+}
+
+func (c *RouteCache) CreateService(routeName string, service *vamprouter.Service) (*vamprouter.Service, error) {
+	created, err := c.Interface.CreateService(routeName, service)
+
+	return created, c.afterPartialWrite(routeName, err, func(route *vamprouter.Route, index *RouteIndex) {
+		index.AddService(route, *service)
+	})
+}
+
+func (c *RouteCache) UpdateService(routeName string, service *vamprouter.Service) (*vamprouter.Service, error) {
+	updated, err := c.Interface.UpdateService(routeName, service)
+
+	return updated, c.afterPartialWrite(routeName, err, func(route *vamprouter.Route, index *RouteIndex) {
+		if routeService, found := index.GetService(route, service.Name); found {
+			*routeService = *service
+		} else {
+			index.AddService(route, *service)
+		}
+	})
+}
+
+func (c *RouteCache) DeleteService(routeName string, serviceName string) error {
+	return c.afterPartialWrite(routeName, c.Interface.DeleteService(routeName, serviceName), func(route *vamprouter.Route, index *RouteIndex) {
+		RemoveServiceFromRoute(route, serviceName)
+		*index = *NewRouteIndex(route)
+	})
+}
+
+func (c *RouteCache) CreateFilter(routeName string, filter *vamprouter.Filter) (*vamprouter.Filter, error) {
+	created, err := c.Interface.CreateFilter(routeName, filter)
+
+	return created, c.afterPartialWrite(routeName, err, func(route *vamprouter.Route, index *RouteIndex) {
+		index.PutFilter(route, *filter)
+	})
+}
+
+func (c *RouteCache) UpdateFilter(routeName string, filter *vamprouter.Filter) (*vamprouter.Filter, error) {
+	updated, err := c.Interface.UpdateFilter(routeName, filter)
+
+	return updated, c.afterPartialWrite(routeName, err, func(route *vamprouter.Route, index *RouteIndex) {
+		index.PutFilter(route, *filter)
+	})
+}
+
+func (c *RouteCache) DeleteFilter(routeName string, filterName string) error {
+	return c.afterPartialWrite(routeName, c.Interface.DeleteFilter(routeName, filterName), func(route *vamprouter.Route, index *RouteIndex) {
+		filters := []vamprouter.Filter{}
+		for _, filter := range route.Filters {
+			if filter.Name != filterName {
+				filters = append(filters, filter)
+			}
+		}
+
+		route.Filters = filters
+		index.IndexFilters(route)
+	})
+}
+
+// Gives the cached route, if any, to the function that must not modify it nor
+// keep it. Returns whether the route is cached.
+func (c *RouteCache) ViewRoute(routeName string, view func(route *vamprouter.Route, index *RouteIndex)) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	cached, found := c.routes[routeName]
+	if !found || time.Since(cached.readAt) >= c.MaxAge {
+		return false
+	}
+
+	view(cached.route, cached.index)
+
+	return true
+}
+
+// Forgets the route, read again from the router the next time.
+func (c *RouteCache) Invalidate(routeName string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	delete(c.routes, routeName)
+}
+
+func (c *RouteCache) store(route *vamprouter.Route) {
+	copied := CopyRoute(route)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.routes[route.Name] = &cachedRoute{
+		route:  copied,
+		index:  NewRouteIndex(copied),
+		readAt: time.Now(),
+	}
+}
+
+// The route answered by the router replaces the cached one, with its new
+// version when the router has one.
+func (c *RouteCache) afterRouteWrite(routeName string, route *vamprouter.Route, err error) error {
+	if err != nil {
+		log.Println("The route", routeName, "will be read again from the router:", err)
+		c.Invalidate(routeName)
+	} else if route != nil {
+		c.store(route)
+	} else {
+		c.Invalidate(routeName)
+	}
+
+	return err
+}
+
+// The services and filters written on their own are applied to the cached
+// route.
+func (c *RouteCache) afterPartialWrite(routeName string, err error, apply func(route *vamprouter.Route, index *RouteIndex)) error {
+	if err != nil {
+		log.Println("The route", routeName, "will be read again from the router:", err)
+		c.Invalidate(routeName)
+
+		return err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	cached, found := c.routes[routeName]
+	if !found {
+		return nil
+	}
+
+	apply(cached.route, cached.index)
+
+	return nil
+}
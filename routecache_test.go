@@ -0,0 +1,180 @@
+package k8svamprouter
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/sroze/kubernetes-vamp-router/vamprouter"
+
+	api "k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/types"
+)
+
+func theVampRoutesAreCachedForMilliseconds(maxAge int) error {
+	cache := NewRouteCache(GetInMemoryRouterClient(), time.Duration(maxAge)*time.Millisecond)
+	routeManager.RouterClient = cache
+	routeManager.RouteCache = cache
+	ingressRouteManager.RouterClient = cache
+	ingressRouteManager.RouteCache = cache
+
+	return nil
+}
+
+func theVampRoutesShouldHaveBeenReadTimes(count int) error {
+	reads := GetInMemoryRouterClient().ReadCount()
+	if reads != count {
+		return errors.New(fmt.Sprintf("The routes have been read %d times while expecting %d", reads, count))
+	}
+
+	return nil
+}
+
+// Routes the service named `n` to the host `host-<n>.example.com`.
+type benchmarkResolver struct{}
+
+func benchmarkObject(n int) KubernetesBackendObject {
+	return &api.Service{
+		ObjectMeta: api.ObjectMeta{
+			Name:      strconv.Itoa(n),
+			Namespace: "benchmark",
+			UID:       types.UID(fmt.Sprintf("uid-%d", n)),
+		},
+	}
+}
+
+func benchmarkObjectNumber(object KubernetesBackendObject) int {
+	n, _ := strconv.Atoi(object.(*api.Service).Name)
+
+	return n
+}
+
+func (resolver *benchmarkResolver) GetDomainNames(object KubernetesBackendObject) ([]string, error) {
+	return []string{fmt.Sprintf("host-%d.example.com", benchmarkObjectNumber(object))}, nil
+}
+
+func (resolver *benchmarkResolver) GetRouteName(object KubernetesBackendObject) (string, error) {
+	return fmt.Sprintf("backend-%d", benchmarkObjectNumber(object)), nil
+}
+
+func (resolver *benchmarkResolver) GetBackendAddress(object KubernetesBackendObject) (string, int, error) {
+	return fmt.Sprintf("10.0.%d.%d", benchmarkObjectNumber(object)/256, benchmarkObjectNumber(object)%256), 80, nil
+}
+
+func (resolver *benchmarkResolver) GetRoutingRules(object KubernetesBackendObject) ([]RoutingRule, error) {
+	name, _ := resolver.GetRouteName(object)
+	domainNames, _ := resolver.GetDomainNames(object)
+	address, port, _ := resolver.GetBackendAddress(object)
+
+	return []RoutingRule{
+		RoutingRule{
+			Host: domainNames[0],
+			Backend: Backend{
+				Name:    name,
+				Address: address,
+				Port:    port,
+			},
+		},
+	}, nil
+}
+
+func (resolver *benchmarkResolver) GetBackendNames(object KubernetesBackendObject) ([]string, error) {
+	name, err := resolver.GetRouteName(object)
+
+	return []string{name}, err
+}
+
+func (resolver *benchmarkResolver) UpdateObjectWithDomainNames(object KubernetesBackendObject, domainNames []string) error {
+	return nil
+}
+
+func (resolver *benchmarkResolver) ShouldHandleObject(object KubernetesBackendObject) bool {
+	return true
+}
+
+// A router answering in a millisecond, with an HTTP route of 5000 hosts.
+func NewBenchmarkRouteManager(b *testing.B, cached bool) *VampRouteManager {
+	client := NewInMemoryVampRouterClient()
+	client.Latency = time.Millisecond
+
+	rm := &VampRouteManager{
+		RouterClient:          client,
+		ObjectRoutingResolver: &benchmarkResolver{},
+		RouteLocks:            NewRouteLocks(),
+		Ownership:             NewOwnershipRegistry(nil),
+	}
+
+	if cached {
+		rm.RouteCache = NewRouteCache(client, time.Hour)
+		rm.RouterClient = rm.RouteCache
+	}
+
+	route := &vamprouter.Route{
+		Name:     HttpRouteName,
+		Port:     80,
+		Protocol: vamprouter.ProtocolHttp,
+	}
+
+	for n := 0; n < 5000; n++ {
+		rules, _ := rm.ObjectRoutingResolver.GetRoutingRules(benchmarkObject(n))
+		route.Services = append(route.Services, vamprouter.Service{
+			Name:    rules[0].Backend.Name,
+			Servers: GetBackendServers(rules[0].Backend),
+		})
+
+		route.Filters = append(route.Filters, vamprouter.Filter{
+			Name:        GetFilterName(rules[0].Host, ""),
+			Condition:   GetFilterCondition(rules[0].Host, ""),
+			Destination: rules[0].Backend.Name,
+		})
+	}
+
+	if _, err := client.CreateRoute(route); err != nil {
+		b.Fatal(err)
+	}
+
+	return rm
+}
+
+func BenchmarkUpdateRouteIfNeeded(b *testing.B) {
+	for _, cached := range []bool{false, true} {
+		b.Run(fmt.Sprintf("5000 filters, cached: %t", cached), func(b *testing.B) {
+			rm := NewBenchmarkRouteManager(b, cached)
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				// Each object is already routed, the router is not updated
+				if _, err := rm.UpdateRouteIfNeeded(benchmarkObject(i % 5000)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkRouteLookup(b *testing.B) {
+	rm := NewBenchmarkRouteManager(b, false)
+	route, err := rm.RouterClient.GetRoute(HttpRouteName)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("5000 filters, scanned", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := GetFilterInRoute(route, GetFilterName(fmt.Sprintf("host-%d.example.com", i%5000), "")); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("5000 filters, indexed", func(b *testing.B) {
+		index := NewRouteIndex(route)
+		for i := 0; i < b.N; i++ {
+			if _, found := index.GetFilter(route, GetFilterName(fmt.Sprintf("host-%d.example.com", i%5000), "")); !found {
+				b.Fatal("Filter not found")
+			}
+		}
+	})
+}
@@ -0,0 +1,66 @@
+package k8svamprouter
+
+import (
+	"errors"
+	"sync"
+)
+
+var ErrNoRouteLocks = errors.New("No route locks are configured")
+
+// Serializes the read-modify-write of each route, as the routes are shared by
+// the route managers and the reconciler of the controller.
+type RouteLocks struct {
+	mutex sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+func NewRouteLocks() *RouteLocks {
+	return &RouteLocks{
+		locks: make(map[string]*sync.Mutex),
+	}
+}
+
+// Locks the route, returning the function unlocking it.
+func (l *RouteLocks) Lock(routeName string) func() {
+	l.mutex.Lock()
+	lock, found := l.locks[routeName]
+	if !found {
+		lock = &sync.Mutex{}
+		l.locks[routeName] = lock
+	}
+	l.mutex.Unlock()
+
+	lock.Lock()
+
+	return lock.Unlock
+}
+
+// Runs the mutation of the route while holding its lock. The Vamp Router does
+// not version its routes, so the changes made outside of the controller while
+// the route is mutated are not detected.
+func MutateRoute(locks *RouteLocks, routeName string, mutate func() error) error {
+	if locks == nil {
+		return ErrNoRouteLocks
+	}
+
+	unlock := locks.Lock(routeName)
+	defer unlock()
+
+	return mutate()
+}
+
+// The changes of the ownership of the route are kept only when the mutation
+// succeeds. Only the leader mutates the routes.
+func (rm *VampRouteManager) MutateRoute(routeName string, mutate func() error) error {
+	if rm.Ownership == nil {
+		return ErrNoOwnershipRegistry
+	}
+
+	return MutateRoute(rm.RouteLocks, routeName, func() error {
+		if !rm.IsLeader() {
+			return ErrNotLeader
+		}
+
+		return rm.Ownership.Transaction(routeName, mutate)
+	})
+}
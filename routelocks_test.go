@@ -0,0 +1,85 @@
+package k8svamprouter
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+func theVampRouterTakesMillisecondsToAnswer(latency int) error {
+	GetInMemoryRouterClient().Latency = time.Duration(latency) * time.Millisecond
+
+	return nil
+}
+
+func ksServicesAndKsIngressesAreCreatedConcurrently(serviceCount int, ingressCount int) error {
+	objects := map[KubernetesBackendObject]*VampRouteManager{}
+	for i := 0; i < serviceCount; i++ {
+		name := fmt.Sprintf("app-%d", i)
+		if err := theKsServiceisInTheNamespace(name, "qwerty"); err != nil {
+			return err
+		} else if err := theKsServiceIPIs(name, fmt.Sprintf("10.0.0.%d", i+1)); err != nil {
+			return err
+		} else if err := theKsServiceIsALoadBalancerExposingThePort(name, 80); err != nil {
+			return err
+		}
+
+		service, err := repository.Get(name)
+		if err != nil {
+			return err
+		}
+
+		objects[service] = routeManager
+	}
+
+	resolver := ingressRouteManager.ObjectRoutingResolver.(*IngressRoutingManager)
+	for i := 0; i < ingressCount; i++ {
+		name := fmt.Sprintf("web-%d", i)
+		theKsIngressIsInTheNamespace(name, "qwerty")
+		theKsIngressHasTheDefaultBackendOnThePort(name, name, "80")
+
+		ingress := GetOrCreateIngress(name)
+		_, err := resolver.KubernetesClient.ExtensionsV1beta1().Ingresses(ingress.ObjectMeta.Namespace).Create(ingress)
+		if err != nil {
+			return err
+		}
+
+		objects[ingress] = ingressRouteManager
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(objects))
+	for object, manager := range objects {
+		wg.Add(1)
+		go func(object KubernetesBackendObject, manager *VampRouteManager) {
+			defer wg.Done()
+
+			errs <- manager.CreateObjectRoute(object)
+		}(object, manager)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func theVampRouteShouldHaveFiltersAndServices(routeName string, filterCount int, serviceCount int) error {
+	route, err := routeManager.RouterClient.GetRoute(routeName)
+	if err != nil {
+		return err
+	}
+
+	if len(route.Filters) != filterCount || len(route.Services) != serviceCount {
+		return errors.New(fmt.Sprintf("The route has %d filters and %d services while expecting %d and %d", len(route.Filters), len(route.Services), filterCount, serviceCount))
+	}
+
+	return nil
+}
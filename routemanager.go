@@ -1,8 +1,14 @@
 package k8svamprouter
 
 import (
+	"fmt"
 	"github.com/sroze/kubernetes-vamp-router/vamprouter"
 	"log"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 )
 
 type KubernetesBackendObject interface {
@@ -13,19 +19,156 @@ type VampRouteManager struct {
 	// Vamp Router client
 	RouterClient vamprouter.Interface
 
+	// Copy of the routes kept by the router client, if any: the objects whose
+	// rules are all in the copy are not routed again
+	RouteCache *RouteCache
+
 	// Object Routing Resolver
 	ObjectRoutingResolver ObjectRoutingResolver
+
+	// Allocates the router ports of the TCP and weighted routes
+	TcpPortAllocator *PortAllocator
+
+	// Provisions the certificates of the TLS termination in front of the HTTPS
+	// route
+	CertificateStore CertificateStore
+
+	// Serializes the mutations of the routes, shared with the other route
+	// manager and the reconciler. Required
+	RouteLocks *RouteLocks
+
+	// Records the entries of the shared routes created for the objects, shared
+	// like the route locks. Required
+	Ownership *OwnershipRegistry
+
+	// Whether this instance may change the routes and the objects, always when
+	// nil
+	Leadership Leadership
+
+	// Router port of the HTTPS route, behind the TLS termination,
+	// `DefaultHttpsRoutePort` when zero
+	HttpsRoutePort int
+
+	// Router port of the TLS passthrough route, `DefaultTlsPassthroughPort`
+	// when zero
+	TlsPassthroughPort int
+
+	// When set, the changes of the HTTP route are batched and sent once no
+	// change has been made for this duration, the syncs waiting for their
+	// batch to be sent
+	BatchWindow time.Duration
+
+	batchesOnce sync.Once
+	batches     *routeBatches
+}
+
+const (
+	HttpRouteName           string = "http"
+	HttpsRouteName          string = "https"
+	TlsPassthroughRouteName string = "tls-passthrough"
+)
+
+// The HTTPS route receives the requests decrypted by the TLS termination in
+// front of the router, which listens on the port 443 itself. The TLS
+// passthrough route receives the TLS connections as they are.
+const (
+	DefaultHttpsRoutePort     = 4443
+	DefaultTlsPassthroughPort = 443
+)
+
+// When "true", the TLS connections for the domain names of the object are
+// passed through to its backends, selected by their server name (SNI).
+const TlsPassthroughAnnotation = "vamp-router/tls-passthrough"
+
+// A backend is exposed as a Vamp service
+type Backend struct {
+	Name    string
+	Address string
+	Port    int
+
+	// When routed to its endpoints, the backend has no address and the
+	// traffic is balanced across them
+	Endpoints []Endpoint
+
+	// Percentage of the traffic of a host shared with other backends, if any
+	Weight int
+}
+
+// An endpoint is an address, such as a pod IP, receiving the traffic on a port
+type Endpoint struct {
+	Address string
+	Port    int
+}
+
+// A routing rule sends the requests for a host, and optionally a path prefix,
+// to a backend.
+type RoutingRule struct {
+	Host    string
+	Path    string
+	Backend Backend
 }
 
 type ObjectRoutingResolver interface {
 	GetDomainNames(object KubernetesBackendObject) ([]string, error)
 	GetRouteName(object KubernetesBackendObject) (string, error)
-	GetBackendAddress(object KubernetesBackendObject) (string, error)
+	GetBackendAddress(object KubernetesBackendObject) (string, int, error)
+	GetRoutingRules(object KubernetesBackendObject) ([]RoutingRule, error)
+	GetBackendNames(object KubernetesBackendObject) ([]string, error)
 	UpdateObjectWithDomainNames(object KubernetesBackendObject, domainNames []string) error
 	ShouldHandleObject(object KubernetesBackendObject) bool
 }
 
+// A TCP rule sends the traffic received by the router on a port to a backend,
+// through a dedicated route.
+type TcpRule struct {
+	RouteName string
+
+	// Router port previously allocated to the route, if any
+	Port int
+
+	Backend Backend
+}
+
+// Implemented by the resolvers whose objects can be routed through the HTTPS
+// route, their certificates being provisioned for the TLS termination in front
+// of it.
+type HttpsRoutingResolver interface {
+	GetHttpsRoutingRules(object KubernetesBackendObject) ([]RoutingRule, error)
+	GetCertificates(object KubernetesBackendObject) ([]Certificate, error)
+
+	// The certificates of the object are the ones whose name has this prefix
+	GetCertificateNamePrefix(object KubernetesBackendObject) (string, error)
+
+	// Whether the certificate has been written by the controller for an object
+	IsCertificateName(name string) bool
+}
+
+// Implemented by the resolvers whose objects can have their TLS connections
+// passed through to the backend serving the given domain name.
+type TlsPassthroughResolver interface {
+	GetTlsPassthroughBackend(object KubernetesBackendObject, domainName string) (Backend, error)
+}
+
+// Implemented by the resolvers whose objects can be routed with TCP routes.
+type TcpRoutingResolver interface {
+	GetTcpRules(object KubernetesBackendObject) ([]TcpRule, error)
+	UpdateObjectWithTcpPorts(object KubernetesBackendObject, routePorts map[string]int) error
+}
+
 func (rm *VampRouteManager) UpdateObjectRouting(object KubernetesBackendObject) error {
+	if !rm.IsLeader() {
+		return ErrNotLeader
+	}
+
+	EventsCount.WithLabelValues(GetObjectKind(object), EventTypeUpdated).Inc()
+
+	err := rm.UpdateWeightedRoutesIfNeeded(object)
+	if err != nil {
+		log.Println("Unable to update object weighted routes", err)
+
+		return err
+	}
+
 	domainNames, err := rm.UpdateRouteIfNeeded(object)
 	if err != nil {
 		log.Println("Unable to update object route", err)
@@ -33,6 +176,27 @@ func (rm *VampRouteManager) UpdateObjectRouting(object KubernetesBackendObject)
 		return err
 	}
 
+	err = rm.UpdateHttpsRouteIfNeeded(object)
+	if err != nil {
+		log.Println("Unable to update object HTTPS route", err)
+
+		return err
+	}
+
+	err = rm.UpdateTlsPassthroughRouteIfNeeded(object)
+	if err != nil {
+		log.Println("Unable to update object TLS passthrough route", err)
+
+		return err
+	}
+
+	_, err = rm.UpdateTcpRoutesIfNeeded(object)
+	if err != nil {
+		log.Println("Unable to update object TCP routes", err)
+
+		return err
+	}
+
 	err = rm.ObjectRoutingResolver.UpdateObjectWithDomainNames(object, domainNames)
 	if err != nil {
 		log.Println("Error while updating the object:", err)
@@ -45,8 +209,68 @@ func (rm *VampRouteManager) UpdateObjectRouting(object KubernetesBackendObject)
 	return nil
 }
 
-func (rm *VampRouteManager) RemoveObjectRouting(object KubernetesBackendObject) {
-	log.Println("[TODO] Should remove service routing")
+func (rm *VampRouteManager) RemoveObjectRouting(object KubernetesBackendObject) error {
+	if !rm.IsLeader() {
+		return ErrNotLeader
+	}
+
+	EventsCount.WithLabelValues(GetObjectKind(object), EventTypeDeleted).Inc()
+
+	err := rm.RemoveRouteIfNeeded(object)
+	if err != nil {
+		log.Println("Unable to remove object route", err)
+
+		return err
+	}
+
+	err = rm.RemoveWeightedRoutesIfNeeded(object)
+	if err != nil {
+		log.Println("Unable to remove object weighted routes", err)
+
+		return err
+	}
+
+	err = rm.RemoveCertificatesIfNeeded(object)
+	if err != nil {
+		log.Println("Unable to remove object certificates", err)
+
+		return err
+	}
+
+	err = rm.RemoveTcpRoutesIfNeeded(object)
+	if err != nil {
+		log.Println("Unable to remove object TCP routes", err)
+
+		return err
+	}
+
+	return nil
+}
+
+// Implementation of `ObjectEventHandler`
+func (rm *VampRouteManager) OnObjectUpdated(object KubernetesBackendObject) {
+	rm.SyncObject(object)
+}
+
+func (rm *VampRouteManager) OnObjectDeleted(object KubernetesBackendObject) {
+	rm.SyncDeletedObject(object)
+}
+
+// Implementation of `ObjectSyncer`
+func (rm *VampRouteManager) SyncObject(object KubernetesBackendObject) error {
+	if !rm.ShouldHandleObject(object) {
+		return rm.RemoveUnroutedObjectTcpRoutes(object)
+	}
+
+	return rm.UpdateObjectRouting(object)
+}
+
+func (rm *VampRouteManager) SyncDeletedObject(object KubernetesBackendObject) error {
+	if !rm.ShouldHandleObject(object) {
+		return nil
+	}
+
+	return rm.RemoveObjectRouting(object)
 }
 
 func (rm *VampRouteManager) CreateObjectRoute(object KubernetesBackendObject) error {
@@ -58,110 +282,979 @@ func (rm *VampRouteManager) ShouldHandleObject(object KubernetesBackendObject) b
 }
 
 func (rm *VampRouteManager) UpdateRouteIfNeeded(object KubernetesBackendObject) ([]string, error) {
-	route, err := rm.GetOrCreateHttpRoute()
+	rules, err := rm.ObjectRoutingResolver.GetRoutingRules(object)
 	if err != nil {
 		return nil, err
 	}
 
-	routeName, err := rm.ObjectRoutingResolver.GetRouteName(object)
+	backendNames, err := rm.ObjectRoutingResolver.GetBackendNames(object)
 	if err != nil {
 		return nil, err
 	}
 
-	backendAddress, err := rm.ObjectRoutingResolver.GetBackendAddress(object)
+	cached, err := rm.ClaimCachedRoutingRules(HttpRouteName, object, rules, backendNames)
 	if err != nil {
 		return nil, err
+	} else if cached {
+		return rm.ObjectRoutingResolver.GetDomainNames(object)
+	}
+
+	if rm.BatchWindow > 0 {
+		err = <-rm.BatchRouteChange(HttpRouteName, rm.GetOrCreateHttpRoute, func(route *vamprouter.Route) (bool, error) {
+			_, updated, err := rm.ApplyObjectRouting(route, object)
+
+			return updated, err
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		return rm.ObjectRoutingResolver.GetDomainNames(object)
 	}
 
-	backend, updated, err := rm.GetCreateOrUpdateBackend(
-		route,
-		routeName,
-		backendAddress,
-	)
+	var domainNames []string
+	err = rm.MutateRoute(HttpRouteName, func() error {
+		route, err := rm.GetOrCreateHttpRoute()
+		if err != nil {
+			return err
+		}
+
+		originalRoute := CopyRoute(route)
+		names, updated, err := rm.ApplyObjectRouting(route, object)
+		if err != nil {
+			return err
+		}
+
+		domainNames = names
+		if updated {
+			return rm.SendRouteChanges(originalRoute, route)
+		}
+
+		return nil
+	})
 
 	if err != nil {
 		return nil, err
 	}
 
-	// Create the filters
+	return domainNames, nil
+}
+
+// Whether the routing rules are all in the cached route, if any, so that the
+// route does not have to be copied to find out that nothing changed. Their
+// entries are claimed all the same, such as the ones created before the
+// ownership was recorded.
+func (rm *VampRouteManager) ClaimCachedRoutingRules(routeName string, object KubernetesBackendObject, rules []RoutingRule, backendNames []string) (bool, error) {
+	if rm.RouteCache == nil {
+		return false, nil
+	}
+
+	owner, err := GetObjectOwner(object)
+	if err != nil {
+		return false, err
+	}
+
+	applied := false
+	err = rm.MutateRoute(routeName, func() error {
+		rm.RouteCache.ViewRoute(routeName, func(route *vamprouter.Route, index *RouteIndex) {
+			applied = RoutingRulesAreApplied(route, index, rules, backendNames)
+			if !applied {
+				return
+			}
+
+			ownership := rm.Ownership.Get(routeName)
+			for _, rule := range rules {
+				ownership.ClaimService(rule.Backend.Name, owner)
+				ownership.ClaimFilter(GetRoutingRuleFilter(route, rule, rule.Backend.Name).Name, owner)
+			}
+		})
+
+		return nil
+	})
+
+	return applied, err
+}
+
+// Converges the backends and the filters of the given object in the route,
+// without sending it to the router. Returns the domain names of the object and
+// whether the route has been modified.
+func (rm *VampRouteManager) ApplyObjectRouting(route *vamprouter.Route, object KubernetesBackendObject) ([]string, bool, error) {
+	rules, err := rm.ObjectRoutingResolver.GetRoutingRules(object)
+	if err != nil {
+		return nil, false, err
+	}
+
+	domainNames, err := rm.ObjectRoutingResolver.GetDomainNames(object)
+	if err != nil {
+		return nil, false, err
+	}
+
+	updated, err := rm.ConvergeRoutingRules(route, object, rules)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return domainNames, updated, nil
+}
+
+// Converges the HTTPS routing rules of the given object in the route, without
+// sending it to the router. Returns whether the route has been modified.
+func (rm *VampRouteManager) ApplyObjectHttpsRouting(route *vamprouter.Route, object KubernetesBackendObject) (bool, error) {
+	httpsRoutingResolver, ok := rm.ObjectRoutingResolver.(HttpsRoutingResolver)
+	if !ok {
+		return false, nil
+	}
+
+	rules, err := httpsRoutingResolver.GetHttpsRoutingRules(object)
+	if err != nil {
+		return false, err
+	}
+
+	return rm.ConvergeRoutingRules(route, object, rules)
+}
+
+// Converges the TLS passthrough rules of the given object in the route,
+// without sending it to the router. Returns whether the route has been modified.
+func (rm *VampRouteManager) ApplyObjectTlsPassthroughRouting(route *vamprouter.Route, object KubernetesBackendObject) (bool, error) {
+	rules, err := rm.GetTlsPassthroughRules(object)
+	if err != nil {
+		return false, err
+	}
+
+	return rm.ConvergeRoutingRules(route, object, rules)
+}
+
+// The objects having the TLS passthrough annotation are routed from each of
+// their domain names.
+func (rm *VampRouteManager) GetTlsPassthroughRules(object KubernetesBackendObject) ([]RoutingRule, error) {
+	tlsPassthroughResolver, ok := rm.ObjectRoutingResolver.(TlsPassthroughResolver)
+	if !ok || !ObjectHasTlsPassthrough(object) {
+		return []RoutingRule{}, nil
+	}
+
 	domainNames, err := rm.ObjectRoutingResolver.GetDomainNames(object)
 	if err != nil {
 		return nil, err
 	}
 
+	rules := []RoutingRule{}
 	for _, domainName := range domainNames {
-		filterName := GetDNSIdentifier(domainName)
-		filter, err := GetFilterInRoute(route, filterName)
+		backend, err := tlsPassthroughResolver.GetTlsPassthroughBackend(object, domainName)
+		if err != nil {
+			return nil, err
+		}
+
+		rules = append(rules, RoutingRule{
+			Host:    domainName,
+			Backend: backend,
+		})
+	}
+
+	return rules, nil
+}
+
+func ObjectHasTlsPassthrough(object KubernetesBackendObject) bool {
+	objectMeta, err := GetObjectMeta(object)
+	if err != nil {
+		return false
+	}
+
+	return objectMeta.Annotations[TlsPassthroughAnnotation] == "true"
+}
+
+// The filters of the object are exactly the ones of its rules: the missing
+// filters are added, the ones whose condition or destination changed are
+// updated and the other filters it owns to its backends are removed.
+func (rm *VampRouteManager) ConvergeRoutingRules(route *vamprouter.Route, object KubernetesBackendObject, rules []RoutingRule) (bool, error) {
+	owner, err := GetObjectOwner(object)
+	if err != nil {
+		return false, err
+	}
+
+	backendNames, err := rm.ObjectRoutingResolver.GetBackendNames(object)
+	if err != nil {
+		return false, err
+	}
+
+	ownership := rm.Ownership.Get(route.Name)
+	updated, err := rm.ApplyRoutingRules(route, rules, owner, backendNames, ownership)
+	if err != nil {
+		return false, err
+	}
+
+	removed := RemoveObsoleteFilters(route, rules, owner, backendNames, ownership)
+
+	return updated || removed, nil
+}
+
+// Applies the rules of the owner to the route, claiming the entries it creates.
+// The entries added by the operators are not changed, unless they are exactly
+// where the owner routes its hosts: the services named after its backends and
+// the filters of its hosts already sending the traffic to one of them are the
+// ones created before the ownership was recorded.
+func (rm *VampRouteManager) ApplyRoutingRules(route *vamprouter.Route, rules []RoutingRule, owner Owner, backendNames []string, ownership *RouteOwnership) (bool, error) {
+	index := NewRouteIndex(route)
+	updated := false
+	filtersChanged := false
+	for _, rule := range rules {
+		ruleOwner := owner
+
+		// The weighted backends are in the weighted route of their host
+		if rule.Backend.Weight > 0 {
+			weightedRouteBackend, err := rm.GetWeightedRouteBackend(rule.Host)
+			if err != nil {
+				return false, err
+			}
+
+			rule.Backend = weightedRouteBackend
+			ruleOwner = GetWeightedRouteOwner(weightedRouteBackend.Name)
+		}
+
+		backend, backendUpdated := GetCreateOrUpdateIndexedBackend(route, index, rule.Backend)
+		ownership.ClaimService(backend.Name, ruleOwner)
+		updated = updated || backendUpdated
+
+		filter := GetRoutingRuleFilter(route, rule, backend.Name)
+		existingFilter, found := index.GetFilter(route, filter.Name)
+		_, owned := ownership.GetFilterOwner(filter.Name)
+		if found && !owned && existingFilter.Destination != filter.Destination && !ContainsString(backendNames, existingFilter.Destination) {
+			log.Println("[error] The filter", filter.Name, "to", existingFilter.Destination, "was not created by the controller, not routing it to", backend.Name)
 
-		if err == nil {
-			// Filter already exists, just pass
 			continue
 		}
 
-		log.Println("Added the filter", filterName, "for the hostname", domainName, "to the backend", backendAddress)
-		filter = &vamprouter.Filter{
-			Name:        filterName,
-			Condition:   "hdr(Host) -i " + domainName,
-			Destination: backend.Name,
+		ownership.ClaimFilter(filter.Name, ruleOwner)
+		if found && *existingFilter == filter {
+			continue
+		} else if found {
+			log.Println("Updated the filter", filter.Name, "routing to", existingFilter.Destination, "with the condition", existingFilter.Condition, "to the backend", rule.Backend.Address)
+		} else {
+			log.Println("Added the filter", filter.Name, "for the hostname", rule.Host, "and the path", rule.Path, "to the backend", rule.Backend.Address)
 		}
 
-		route.Filters = append(route.Filters, *filter)
+		index.PutFilter(route, filter)
+		filtersChanged = true
+	}
+
+	if filtersChanged {
+		SortFiltersBySpecificity(route)
 		updated = true
 	}
 
-	if updated {
-		_, err = rm.RouterClient.UpdateRoute(route)
+	return updated, nil
+}
+
+func (rm *VampRouteManager) IsLeader() bool {
+	return rm.Leadership == nil || rm.Leadership.IsLeader()
+}
 
-		return domainNames, err
+// Provisions the certificates of the object and adds its HTTPS routing rules
+// to the HTTPS route, which is created only once there is something to route.
+func (rm *VampRouteManager) UpdateHttpsRouteIfNeeded(object KubernetesBackendObject) error {
+	httpsRoutingResolver, ok := rm.ObjectRoutingResolver.(HttpsRoutingResolver)
+	if !ok {
+		return nil
 	}
 
-	return domainNames, nil
+	err := rm.UpdateCertificatesIfNeeded(object)
+	if err != nil {
+		return err
+	}
+
+	rules, err := httpsRoutingResolver.GetHttpsRoutingRules(object)
+	if err != nil {
+		return err
+	} else if len(rules) == 0 {
+		return rm.RemoveObsoleteRouting(HttpsRouteName, object)
+	}
+
+	return rm.MutateRoute(HttpsRouteName, func() error {
+		route, err := GetOrCreateHttpsRoute(rm.RouterClient, rm.GetHttpsRoutePort())
+		if err != nil {
+			return err
+		}
+
+		return rm.ConvergeAndSendRoutingRules(route, object, rules)
+	})
 }
 
-func (rm *VampRouteManager) GetCreateOrUpdateBackend(route *vamprouter.Route, routeName string, backendAddress string) (*vamprouter.Service, bool, error) {
-	updated := false
+func (rm *VampRouteManager) UpdateTlsPassthroughRouteIfNeeded(object KubernetesBackendObject) error {
+	rules, err := rm.GetTlsPassthroughRules(object)
+	if err != nil {
+		return err
+	} else if len(rules) == 0 {
+		return rm.RemoveObsoleteRouting(TlsPassthroughRouteName, object)
+	}
 
-	// Create the backend service if it do not exists
-	routeService, err := GetServiceInRoute(route, routeName)
+	return rm.MutateRoute(TlsPassthroughRouteName, func() error {
+		route, err := GetOrCreateTlsPassthroughRoute(rm.RouterClient, rm.GetTlsPassthroughPort())
+		if err != nil {
+			return err
+		}
+
+		return rm.ConvergeAndSendRoutingRules(route, object, rules)
+	})
+}
+
+// Removes the entries of the object from the route it has no rule for anymore,
+// such as when its TLS section has been removed. The route is not read when
+// the object owns no entry in it.
+func (rm *VampRouteManager) RemoveObsoleteRouting(routeName string, object KubernetesBackendObject) error {
+	owner, err := GetObjectOwner(object)
 	if err != nil {
-		route.Services = append(route.Services, vamprouter.Service{
-			Name:   routeName,
-			Weight: 0,
-		})
+		return err
+	} else if rm.Ownership == nil {
+		return ErrNoOwnershipRegistry
+	} else if !rm.Ownership.Get(routeName).HasEntries(owner) {
+		return nil
+	}
 
-		routeService = &route.Services[len(route.Services)-1]
-		updated = true
-		err = nil
+	backendNames, err := rm.ObjectRoutingResolver.GetBackendNames(object)
+	if err != nil {
+		return err
 	}
 
-	// Updates the backend if needed
-	if len(routeService.Servers) != 1 || routeService.Servers[0].Host != backendAddress {
-		routeService.Servers = []vamprouter.Server{
-			vamprouter.Server{
-				Name: routeName,
-				Host: backendAddress,
-				Port: 80,
-			},
+	return rm.RemoveBackendsFromRoute(routeName, owner, backendNames)
+}
+
+func (rm *VampRouteManager) ConvergeAndSendRoutingRules(route *vamprouter.Route, object KubernetesBackendObject, rules []RoutingRule) error {
+	originalRoute := CopyRoute(route)
+	updated, err := rm.ConvergeRoutingRules(route, object, rules)
+	if err != nil {
+		return err
+	}
+
+	if updated {
+		err = rm.SendRouteChanges(originalRoute, route)
+	}
+
+	return err
+}
+
+// When the filters are untouched, only the services that changed are sent to
+// the router instead of the whole route, shared by all the objects. The
+// services no longer in the route are deleted once the others are sent.
+func (rm *VampRouteManager) SendRouteChanges(originalRoute *vamprouter.Route, route *vamprouter.Route) error {
+	if !FiltersAreEqual(originalRoute.Filters, route.Filters) {
+		_, err := rm.RouterClient.UpdateRoute(route)
+
+		return err
+	}
+
+	originalIndex := NewRouteIndex(originalRoute)
+	for position := range route.Services {
+		service := &route.Services[position]
+
+		var err error
+		originalService, found := originalIndex.GetService(originalRoute, service.Name)
+		if !found {
+			_, err = rm.RouterClient.CreateService(route.Name, service)
+		} else if !reflect.DeepEqual(originalService, service) {
+			_, err = rm.RouterClient.UpdateService(route.Name, service)
 		}
 
-		err = ReplaceServiceInRoute(route, routeName, routeService)
-		updated = true
+		if err != nil {
+			return err
+		}
+	}
+
+	index := NewRouteIndex(route)
+	for _, originalService := range originalRoute.Services {
+		if _, found := index.GetService(route, originalService.Name); found {
+			continue
+		}
+
+		err := rm.RouterClient.DeleteService(route.Name, originalService.Name)
+		if err != nil && !vamprouter.IsNotFound(err) {
+			return err
+		}
 	}
 
-	return routeService, updated, err
+	return nil
 }
 
-func (rm *VampRouteManager) GetOrCreateHttpRoute() (*vamprouter.Route, error) {
-	route, err := rm.RouterClient.GetRoute("http")
+func (rm *VampRouteManager) RemoveRouteIfNeeded(object KubernetesBackendObject) error {
+	owner, err := GetObjectOwner(object)
+	if err != nil {
+		return err
+	}
+
+	backendNames, err := rm.ObjectRoutingResolver.GetBackendNames(object)
 	if err != nil {
-		route, err = rm.RouterClient.CreateRoute(&vamprouter.Route{
-			Name:     "http",
-			Port:     80,
-			Protocol: vamprouter.ProtocolHttp,
+		return err
+	}
+
+	if rm.BatchWindow > 0 {
+		err = <-rm.BatchRouteChange(HttpRouteName, rm.GetOrCreateHttpRoute, func(route *vamprouter.Route) (bool, error) {
+			return RemoveOwnedBackendsFromRoute(route, owner, backendNames, rm.Ownership.Get(HttpRouteName)), nil
+		})
+	} else {
+		err = rm.RemoveBackendsFromRoute(HttpRouteName, owner, backendNames)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if _, ok := rm.ObjectRoutingResolver.(HttpsRoutingResolver); ok {
+		err = rm.RemoveBackendsFromRoute(HttpsRouteName, owner, backendNames)
+		if err != nil {
+			return err
+		}
+	}
+
+	if _, ok := rm.ObjectRoutingResolver.(TlsPassthroughResolver); ok {
+		return rm.RemoveBackendsFromRoute(TlsPassthroughRouteName, owner, backendNames)
+	}
+
+	return nil
+}
+
+// Removes the services and the filters of the backends owned by the owner.
+func (rm *VampRouteManager) RemoveBackendsFromRoute(routeName string, owner Owner, backendNames []string) error {
+	return rm.MutateRoute(routeName, func() error {
+		route, err := rm.RouterClient.GetRoute(routeName)
+		if vamprouter.IsNotFound(err) {
+			log.Println("The route", routeName, "does not exist, nothing to remove for", backendNames)
+
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if !RemoveOwnedBackendsFromRoute(route, owner, backendNames, rm.Ownership.Get(routeName)) {
+			log.Println("Nothing to remove from the route for", backendNames)
+
+			return nil
+		}
+
+		log.Println("Removed the backends", backendNames, "and their filters from the route", route.Name)
+		_, err = rm.RouterClient.UpdateRoute(route)
+
+		return err
+	})
+}
+
+// Writes the certificates of the object, such as the ones of a rotated
+// secret, and removes the ones it no longer has, such as the one of a removed
+// TLS section.
+func (rm *VampRouteManager) UpdateCertificatesIfNeeded(object KubernetesBackendObject) error {
+	httpsRoutingResolver, ok := rm.ObjectRoutingResolver.(HttpsRoutingResolver)
+	if !ok {
+		return nil
+	}
+
+	certificates, err := httpsRoutingResolver.GetCertificates(object)
+	if err != nil {
+		return err
+	}
+
+	if rm.CertificateStore == nil {
+		if len(certificates) > 0 {
+			return fmt.Errorf("No certificate store is configured")
+		}
+
+		return nil
+	}
+
+	prefix, err := httpsRoutingResolver.GetCertificateNamePrefix(object)
+	if err != nil {
+		return err
+	}
+
+	return rm.ConvergeCertificates(certificates, func(name string) bool {
+		return strings.HasPrefix(name, prefix)
+	})
+}
+
+func (rm *VampRouteManager) RemoveCertificatesIfNeeded(object KubernetesBackendObject) error {
+	httpsRoutingResolver, ok := rm.ObjectRoutingResolver.(HttpsRoutingResolver)
+	if !ok || rm.CertificateStore == nil {
+		return nil
+	}
+
+	prefix, err := httpsRoutingResolver.GetCertificateNamePrefix(object)
+	if err != nil {
+		return err
+	}
+
+	return rm.ConvergeCertificates([]Certificate{}, func(name string) bool {
+		return strings.HasPrefix(name, prefix)
+	})
+}
+
+// Writes the certificates of all the objects and removes the ones written for
+// the objects that are not routed anymore. The certificates of the objects
+// whose secrets can't be read are kept as they are.
+func (rm *VampRouteManager) ReconcileCertificates(objects []KubernetesBackendObject) error {
+	httpsRoutingResolver, ok := rm.ObjectRoutingResolver.(HttpsRoutingResolver)
+	if !ok || rm.CertificateStore == nil {
+		return nil
+	}
+
+	certificates := []Certificate{}
+	keptPrefixes := []string{}
+	for _, object := range objects {
+		if !rm.ShouldHandleObject(object) {
+			continue
+		}
+
+		objectCertificates, err := httpsRoutingResolver.GetCertificates(object)
+		if err != nil {
+			log.Println("[error] Unable to get the certificates of the object", err)
+
+			prefix, err := httpsRoutingResolver.GetCertificateNamePrefix(object)
+			if err != nil {
+				return err
+			}
+
+			keptPrefixes = append(keptPrefixes, prefix)
+
+			continue
+		}
+
+		certificates = append(certificates, objectCertificates...)
+	}
+
+	return rm.ConvergeCertificates(certificates, func(name string) bool {
+		for _, prefix := range keptPrefixes {
+			if strings.HasPrefix(name, prefix) {
+				return false
+			}
+		}
+
+		return httpsRoutingResolver.IsCertificateName(name)
+	})
+}
+
+// Stores the certificates, and removes the stored certificates matching
+// `isObsolete` that are not among them.
+func (rm *VampRouteManager) ConvergeCertificates(certificates []Certificate, isObsolete func(name string) bool) error {
+	certificateNames := []string{}
+	for _, certificate := range certificates {
+		err := rm.CertificateStore.StoreCertificate(certificate)
+		if err != nil {
+			return err
+		}
+
+		certificateNames = append(certificateNames, certificate.Name)
+	}
+
+	storedNames, err := rm.CertificateStore.ListCertificateNames()
+	if err != nil {
+		return err
+	}
+
+	for _, name := range storedNames {
+		if ContainsString(certificateNames, name) || !isObsolete(name) {
+			continue
+		}
+
+		log.Println("Removing the certificate", name)
+
+		err = rm.CertificateStore.RemoveCertificate(name)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Converges the TCP routes of the object to its TCP ports: the routes of the
+// ports it no longer exposes are deleted and their router ports released.
+// Returns the names of its TCP routes.
+func (rm *VampRouteManager) UpdateTcpRoutesIfNeeded(object KubernetesBackendObject) ([]string, error) {
+	tcpRoutingResolver, ok := rm.ObjectRoutingResolver.(TcpRoutingResolver)
+	if !ok {
+		return []string{}, nil
+	}
+
+	rules, err := tcpRoutingResolver.GetTcpRules(object)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rules) > 0 && rm.TcpPortAllocator == nil {
+		return nil, fmt.Errorf("No port range is configured for the TCP routes")
+	}
+
+	owner, err := GetObjectOwner(object)
+	if err != nil {
+		return nil, err
+	}
+
+	routeNames := []string{}
+	routePorts := make(map[string]int)
+	for _, rule := range rules {
+		port, err := rm.TcpPortAllocator.Allocate(rule.RouteName, rule.Port)
+		if err != nil {
+			return nil, err
+		}
+
+		err = rm.UpdateTcpRouteIfNeeded(rule, port, owner)
+		if err != nil {
+			return nil, err
+		}
+
+		routeNames = append(routeNames, rule.RouteName)
+		routePorts[rule.RouteName] = port
+	}
+
+	err = rm.RemoveObsoleteTcpRoutes(owner, routeNames)
+	if err != nil {
+		return nil, err
+	}
+
+	return routeNames, tcpRoutingResolver.UpdateObjectWithTcpPorts(object, routePorts)
+}
+
+func (rm *VampRouteManager) UpdateTcpRouteIfNeeded(rule TcpRule, port int, owner Owner) error {
+	return rm.MutateRoute(rule.RouteName, func() error {
+		route, err := rm.RouterClient.GetRoute(rule.RouteName)
+		if vamprouter.IsNotFound(err) {
+			route = &vamprouter.Route{
+				Name:     rule.RouteName,
+				Port:     port,
+				Protocol: vamprouter.ProtocolTcp,
+				Filters:  []vamprouter.Filter{},
+				Services: []vamprouter.Service{},
+			}
+
+			_, _, err = rm.GetCreateOrUpdateBackend(route, rule.Backend)
+			if err != nil {
+				return err
+			}
+
+			rm.Ownership.Get(rule.RouteName).ClaimService(rule.Backend.Name, owner)
+
+			log.Println("Created the TCP route", rule.RouteName, "on the port", port, "to the backend", rule.Backend.Address)
+			_, err = rm.RouterClient.CreateRoute(route)
+
+			return err
+		} else if err != nil {
+			return err
+		}
+
+		_, updated, err := rm.GetCreateOrUpdateBackend(route, rule.Backend)
+		if err != nil {
+			return err
+		}
+
+		rm.Ownership.Get(rule.RouteName).ClaimService(rule.Backend.Name, owner)
+
+		if route.Port != port {
+			route.Port = port
+			updated = true
+		}
+
+		if updated {
+			log.Println("Updated the TCP route", rule.RouteName, "on the port", port, "to the backend", rule.Backend.Address)
+			_, err = rm.RouterClient.UpdateRoute(route)
+		}
+
+		return err
+	})
+}
+
+func (rm *VampRouteManager) RemoveTcpRoutesIfNeeded(object KubernetesBackendObject) error {
+	tcpRoutingResolver, ok := rm.ObjectRoutingResolver.(TcpRoutingResolver)
+	if !ok {
+		return nil
+	}
+
+	rules, err := tcpRoutingResolver.GetTcpRules(object)
+	if err != nil {
+		return err
+	}
+
+	for _, rule := range rules {
+		err := rm.RemoveTcpRouteIfNeeded(rule.RouteName)
+		if err != nil {
+			return err
+		}
+	}
+
+	owner, err := GetObjectOwner(object)
+	if err != nil {
+		return err
+	}
+
+	return rm.RemoveObsoleteTcpRoutes(owner, []string{})
+}
+
+// Removes the TCP routes owned by the object other than the given ones, such
+// as the routes of the ports it no longer exposes.
+func (rm *VampRouteManager) RemoveObsoleteTcpRoutes(owner Owner, routeNames []string) error {
+	if rm.Ownership == nil {
+		return ErrNoOwnershipRegistry
+	}
+
+	ownedRouteNames, err := rm.Ownership.GetOwnedRouteNames(owner)
+	if err != nil {
+		return err
+	}
+
+	for _, routeName := range ownedRouteNames {
+		if ContainsString(routeNames, routeName) || IsSharedRouteName(routeName) {
+			continue
+		}
+
+		err := rm.RemoveTcpRouteIfNeeded(routeName)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Deletes the route if it is a TCP route, and releases its router port. The
+// ownership of a route already deleted is released as well, so that it is not
+// removed again on every reconciliation.
+func (rm *VampRouteManager) RemoveTcpRouteIfNeeded(routeName string) error {
+	isTcpRoute := true
+	err := rm.MutateRoute(routeName, func() error {
+		route, err := rm.RouterClient.GetRoute(routeName)
+		if err == nil {
+			isTcpRoute = IsTcpRoute(route)
+			if !isTcpRoute {
+				return nil
+			}
+
+			log.Println("Removing the TCP route", routeName)
+			err = rm.RouterClient.DeleteRoute(routeName)
+		}
+
+		if err != nil && !vamprouter.IsNotFound(err) {
+			return err
+		}
+
+		rm.Ownership.Get(routeName).Prune(&vamprouter.Route{
+			Name: routeName,
+		})
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if isTcpRoute && rm.TcpPortAllocator != nil {
+		rm.TcpPortAllocator.Release(routeName)
+	}
+
+	return nil
+}
+
+// The TCP routes of the ports of the objects, as opposed to the TLS
+// passthrough route shared by the objects.
+func IsTcpRoute(route *vamprouter.Route) bool {
+	return route.Protocol == vamprouter.ProtocolTcp && route.Name != TlsPassthroughRouteName
+}
+
+// Whether the route is shared by the objects instead of belonging to one.
+func IsSharedRouteName(routeName string) bool {
+	return routeName == HttpRouteName || routeName == HttpsRouteName || routeName == TlsPassthroughRouteName || IsWeightedRouteName(routeName)
+}
+
+// Removes the TCP routes of an object that is no longer routed, such as a
+// service that is no longer a load-balancer.
+func (rm *VampRouteManager) RemoveUnroutedObjectTcpRoutes(object KubernetesBackendObject) error {
+	if _, ok := rm.ObjectRoutingResolver.(TcpRoutingResolver); !ok {
+		return nil
+	}
+
+	owner, err := GetObjectOwner(object)
+	if err != nil {
+		return err
+	}
+
+	return rm.RemoveObsoleteTcpRoutes(owner, []string{})
+}
+
+// Reserves the router ports already allocated to the TCP routes of the
+// objects, so that they keep them whatever the order they are routed in.
+func (rm *VampRouteManager) ReserveTcpPorts(objects []KubernetesBackendObject) {
+	tcpRoutingResolver, ok := rm.ObjectRoutingResolver.(TcpRoutingResolver)
+	if !ok || rm.TcpPortAllocator == nil {
+		return
+	}
+
+	for _, object := range objects {
+		if !rm.ShouldHandleObject(object) {
+			continue
+		}
+
+		rules, err := tcpRoutingResolver.GetTcpRules(object)
+		if err != nil {
+			log.Println("Unable to get the TCP rules", err)
+
+			continue
+		}
+
+		for _, rule := range rules {
+			if rule.Port != 0 && !rm.TcpPortAllocator.Reserve(rule.RouteName, rule.Port) {
+				log.Println("[error] The port", rule.Port, "of the TCP route", rule.RouteName, "is not available")
+			}
+		}
+	}
+}
+
+func (rm *VampRouteManager) GetCreateOrUpdateBackend(route *vamprouter.Route, backend Backend) (*vamprouter.Service, bool, error) {
+	routeService, updated := GetCreateOrUpdateIndexedBackend(route, NewRouteIndex(route), backend)
+
+	return routeService, updated, nil
+}
+
+// Creates the service of the backend in the route, or updates its servers and
+// weight. Returns the service and whether the route has been modified.
+func GetCreateOrUpdateIndexedBackend(route *vamprouter.Route, index *RouteIndex, backend Backend) (*vamprouter.Service, bool) {
+	updated := false
+
+	// Create the backend service if it do not exists
+	routeService, found := index.GetService(route, backend.Name)
+	if !found {
+		routeService = index.AddService(route, vamprouter.Service{
+			Name:   backend.Name,
+			Weight: backend.Weight,
+		})
+
+		updated = true
+	}
+
+	// Updates the backend if needed
+	servers := GetBackendServers(backend)
+	if !ServersAreEqual(routeService.Servers, servers) || routeService.Weight != backend.Weight {
+		routeService.Servers = servers
+		routeService.Weight = backend.Weight
+		updated = true
+	}
+
+	return routeService, updated
+}
+
+// A backend routed to its endpoints has one server per endpoint, sorted so
+// that the same endpoints always give the same servers.
+func GetBackendServers(backend Backend) []vamprouter.Server {
+	if backend.Address != "" {
+		return []vamprouter.Server{
+			vamprouter.Server{
+				Name: backend.Name,
+				Host: backend.Address,
+				Port: backend.Port,
+			},
+		}
+	}
+
+	servers := []vamprouter.Server{}
+	for _, endpoint := range backend.Endpoints {
+		servers = append(servers, vamprouter.Server{
+			Name: GetDNSIdentifier(fmt.Sprintf("%s-%s-%d", backend.Name, endpoint.Address, endpoint.Port)),
+			Host: endpoint.Address,
+			Port: endpoint.Port,
+		})
+	}
+
+	sort.Sort(serversByName(servers))
+
+	return servers
+}
+
+type serversByName []vamprouter.Server
+
+func (servers serversByName) Len() int {
+	return len(servers)
+}
+
+func (servers serversByName) Swap(i, j int) {
+	servers[i], servers[j] = servers[j], servers[i]
+}
+
+func (servers serversByName) Less(i, j int) bool {
+	return servers[i].Name < servers[j].Name
+}
+
+func ServersAreEqual(servers []vamprouter.Server, otherServers []vamprouter.Server) bool {
+	if len(servers) != len(otherServers) {
+		return false
+	}
+
+	for index := range servers {
+		if servers[index] != otherServers[index] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (rm *VampRouteManager) GetOrCreateHttpRoute() (*vamprouter.Route, error) {
+	return GetOrCreateHttpRoute(rm.RouterClient)
+}
+
+func GetOrCreateHttpRoute(routerClient vamprouter.Interface) (*vamprouter.Route, error) {
+	return GetOrCreateRoute(routerClient, HttpRouteName, 80, vamprouter.ProtocolHttp)
+}
+
+// The HTTPS route is a plain HTTP route: the Vamp Router API can't give
+// certificates to a route, so the TLS connections are terminated in front of
+// it, on the port 443 with the provisioned certificates, by the deployment of
+// the router, which forwards the decrypted requests to the given port. The
+// route is moved there when it listened on another one.
+func GetOrCreateHttpsRoute(routerClient vamprouter.Interface, port int) (*vamprouter.Route, error) {
+	return GetOrCreateRouteOnPort(routerClient, HttpsRouteName, port, vamprouter.ProtocolHttp)
+}
+
+// The TLS connections passed through are routed by a TCP route on the given
+// port, moved there when it listened on another one.
+func GetOrCreateTlsPassthroughRoute(routerClient vamprouter.Interface, port int) (*vamprouter.Route, error) {
+	return GetOrCreateRouteOnPort(routerClient, TlsPassthroughRouteName, port, vamprouter.ProtocolTcp)
+}
+
+func GetOrCreateRouteOnPort(routerClient vamprouter.Interface, name string, port int, protocol string) (*vamprouter.Route, error) {
+	route, err := GetOrCreateRoute(routerClient, name, port, protocol)
+	if err != nil || route.Port == port {
+		return route, err
+	}
+
+	log.Println("Moving the route", route.Name, "from the port", route.Port, "to the port", port)
+	route.Port = port
+
+	return routerClient.UpdateRoute(route)
+}
+
+func (rm *VampRouteManager) GetHttpsRoutePort() int {
+	if rm.HttpsRoutePort == 0 {
+		return DefaultHttpsRoutePort
+	}
+
+	return rm.HttpsRoutePort
+}
+
+func (rm *VampRouteManager) GetTlsPassthroughPort() int {
+	if rm.TlsPassthroughPort == 0 {
+		return DefaultTlsPassthroughPort
+	}
+
+	return rm.TlsPassthroughPort
+}
+
+// The route is created only when the router answers that it does not exist,
+// any other error is returned as is.
+func GetOrCreateRoute(routerClient vamprouter.Interface, name string, port int, protocol string) (*vamprouter.Route, error) {
+	route, err := routerClient.GetRoute(name)
+	if vamprouter.IsNotFound(err) {
+		route, err = routerClient.CreateRoute(&vamprouter.Route{
+			Name:     name,
+			Port:     port,
+			Protocol: protocol,
 		})
 
 		if err != nil {
-			log.Println("Unable to create the HTTP route", err)
+			log.Println("Unable to create the route", name, err)
 
 			return nil, err
 		}
@@ -0,0 +1,259 @@
+package k8svamprouter
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sroze/kubernetes-vamp-router/vamprouter"
+)
+
+// A certificate authority signing the certificates of the fake router API and
+// of the controller.
+type TestAuthority struct {
+	Certificate *x509.Certificate
+	Key         *ecdsa.PrivateKey
+	PEM         []byte
+}
+
+func NewTestAuthority() (*TestAuthority, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test authority"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TestAuthority{
+		Certificate: certificate,
+		Key:         key,
+		PEM:         pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}, nil
+}
+
+// Signs a certificate, returning it and its key as PEM.
+func (authority *TestAuthority) Sign(commonName string, usage x509.ExtKeyUsage) ([]byte, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, authority.Certificate, &key.PublicKey, authority.Key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}),
+		nil
+}
+
+// Directory of the files read by the client
+var routerApiFiles string
+var routerApiAuthority *TestAuthority
+
+func WriteRouterApiFile(name string, content []byte) (string, error) {
+	if routerApiFiles == "" {
+		directory, err := ioutil.TempDir("", "vamp-router-api")
+		if err != nil {
+			return "", err
+		}
+
+		routerApiFiles = directory
+	}
+
+	path := filepath.Join(routerApiFiles, name)
+	err := ioutil.WriteFile(path, content, 0600)
+	if err != nil {
+		return "", err
+	}
+
+	// Makes the change visible even within the resolution of the modification time
+	future := time.Now().Add(time.Duration(len(content)) * time.Second)
+
+	return path, os.Chtimes(path, future, future)
+}
+
+func GetRouterApiAuthority() (*TestAuthority, error) {
+	if routerApiAuthority != nil {
+		return routerApiAuthority, nil
+	}
+
+	authority, err := NewTestAuthority()
+	routerApiAuthority = authority
+
+	return authority, err
+}
+
+func theVampRouterApiRequiresTheBearerToken(token string) error {
+	routerApi.Authorization = "Bearer " + token
+
+	return nil
+}
+
+func theVampRouterApiRequiresTheUsernameAndThePassword(username string, password string) error {
+	request, _ := http.NewRequest("GET", "/", nil)
+	request.SetBasicAuth(username, password)
+	routerApi.Authorization = request.Header.Get("Authorization")
+
+	return nil
+}
+
+func theVampRouterClientReadsItsTokenFromAFileContaining(token string) error {
+	path, err := WriteRouterApiFile("token", []byte(token+"\n"))
+	if err != nil {
+		return err
+	}
+
+	routerApiClient.Authenticator = vamprouter.NewBearerTokenFile(path)
+
+	return nil
+}
+
+func theTokenFileNowContains(token string) error {
+	_, err := WriteRouterApiFile("token", []byte(token+"\n"))
+
+	return err
+}
+
+func theVampRouterClientReadsItsUsernameAndPasswordFromFilesContainingAnd(username string, password string) error {
+	usernamePath, err := WriteRouterApiFile("username", []byte(username))
+	if err != nil {
+		return err
+	}
+
+	passwordPath, err := WriteRouterApiFile("password", []byte(password))
+	if err != nil {
+		return err
+	}
+
+	routerApiClient.Authenticator = vamprouter.NewBasicAuthFiles(usernamePath, passwordPath)
+
+	return nil
+}
+
+func theVampRouterApiIsServedOverTLS() error {
+	authority, err := GetRouterApiAuthority()
+	if err != nil {
+		return err
+	}
+
+	certificate, key, err := authority.Sign("router", x509.ExtKeyUsageServerAuth)
+	if err != nil {
+		return err
+	}
+
+	keyPair, err := tls.X509KeyPair(certificate, key)
+	if err != nil {
+		return err
+	}
+
+	routerApi.TLSConfig = &tls.Config{
+		Certificates: []tls.Certificate{keyPair},
+	}
+
+	return nil
+}
+
+func theVampRouterApiRequiresAClientCertificate() error {
+	routerApi.TLSConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	routerApi.TLSConfig.ClientCAs = x509.NewCertPool()
+	routerApi.TLSConfig.ClientCAs.AddCert(routerApiAuthority.Certificate)
+
+	return nil
+}
+
+func theVampRouterClientTrustsTheAuthorityOfTheApi() error {
+	return UseRouterApiTLSTransport(false)
+}
+
+func theVampRouterClientPresentsAClientCertificate() error {
+	return UseRouterApiTLSTransport(true)
+}
+
+func UseRouterApiTLSTransport(withClientCertificate bool) error {
+	caPath, err := WriteRouterApiFile("ca.pem", routerApiAuthority.PEM)
+	if err != nil {
+		return err
+	}
+
+	certificatePath, keyPath := "", ""
+	if withClientCertificate {
+		certificate, key, err := routerApiAuthority.Sign("controller", x509.ExtKeyUsageClientAuth)
+		if err != nil {
+			return err
+		}
+
+		if certificatePath, err = WriteRouterApiFile("client.pem", certificate); err != nil {
+			return err
+		}
+
+		if keyPath, err = WriteRouterApiFile("client-key.pem", key); err != nil {
+			return err
+		}
+	}
+
+	transport, err := vamprouter.NewTLSTransport(caPath, certificatePath, keyPath)
+	if err != nil {
+		return err
+	}
+
+	routerApiClient.HTTP = &http.Client{
+		Transport: transport,
+	}
+
+	return nil
+}
+
+func theRequestShouldFail() error {
+	if routerApiError == nil {
+		return errors.New("The request succeeded")
+	}
+
+	return nil
+}
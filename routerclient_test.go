@@ -0,0 +1,239 @@
+package k8svamprouter
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sroze/kubernetes-vamp-router/vamprouter"
+)
+
+// A fake Vamp Router API answering the requests with the given statuses, the
+// last one being repeated.
+type FakeRouterApi struct {
+	Server   *httptest.Server
+	Statuses []int
+	Delay    time.Duration
+
+	// Value of the `Authorization` header of the accepted requests
+	Authorization string
+
+	// TLS configuration of the server, served over plain HTTP when nil
+	TLSConfig *tls.Config
+
+	mutex    sync.Mutex
+	Requests int
+}
+
+func (api *FakeRouterApi) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if api.Authorization != "" && r.Header.Get("Authorization") != api.Authorization {
+		w.WriteHeader(http.StatusUnauthorized)
+
+		return
+	}
+
+	api.mutex.Lock()
+	status := api.Statuses[len(api.Statuses)-1]
+	if api.Requests < len(api.Statuses) {
+		status = api.Statuses[api.Requests]
+	}
+
+	api.Requests++
+	api.mutex.Unlock()
+
+	time.Sleep(api.Delay)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if status/100 == 2 {
+		fmt.Fprint(w, `{"name": "http", "port": 80, "protocol": "http"}`)
+	} else {
+		fmt.Fprintf(w, `{"status": "%s"}`, http.StatusText(status))
+	}
+}
+
+func (api *FakeRouterApi) RequestCount() int {
+	api.mutex.Lock()
+	defer api.mutex.Unlock()
+
+	return api.Requests
+}
+
+var routerApi *FakeRouterApi
+var routerApiClient *vamprouter.Client
+var routerApiError error
+var circuitStateChanges []string
+
+func theVampRouterApiAnswersWithTheStatuses(statuses string) error {
+	routerApi = &FakeRouterApi{}
+	for _, status := range strings.Split(statuses, ",") {
+		code, err := strconv.Atoi(strings.TrimSpace(status))
+		if err != nil {
+			return err
+		}
+
+		routerApi.Statuses = append(routerApi.Statuses, code)
+	}
+
+	routerApiClient = &vamprouter.Client{
+		Backoff: &vamprouter.Backoff{
+			InitialInterval: time.Millisecond,
+			MaxInterval:     5 * time.Millisecond,
+			MaxRetries:      vamprouter.DefaultMaxRetries,
+		},
+	}
+
+	return nil
+}
+
+func theVampRouterApiAnswersAfterMilliseconds(delay int) error {
+	routerApi.Delay = time.Duration(delay) * time.Millisecond
+
+	return nil
+}
+
+func theVampRouterClientTimesOutAfterMilliseconds(timeout int) error {
+	routerApiClient.Timeout = time.Duration(timeout) * time.Millisecond
+
+	return nil
+}
+
+func theVampRouterClientRetriesTimes(retries int) error {
+	routerApiClient.Backoff.MaxRetries = retries
+
+	return nil
+}
+
+func theCircuitBreakerOpensAfterFailures(failures int) error {
+	routerApiClient.CircuitBreaker = vamprouter.NewCircuitBreaker(failures, time.Hour)
+	routerApiClient.CircuitBreaker.OnStateChange = func(from vamprouter.CircuitState, to vamprouter.CircuitState) {
+		circuitStateChanges = append(circuitStateChanges, from.String()+" -> "+to.String())
+	}
+
+	return nil
+}
+
+func theCircuitBreakerIsResetAfterMilliseconds(timeout int) error {
+	routerApiClient.CircuitBreaker.ResetTimeout = time.Duration(timeout) * time.Millisecond
+
+	return nil
+}
+
+// The server is started by the first request, once configured.
+func StartRouterApi() {
+	if routerApi.Server != nil {
+		return
+	}
+
+	routerApi.Server = httptest.NewUnstartedServer(routerApi)
+	if routerApi.TLSConfig != nil {
+		routerApi.Server.TLS = routerApi.TLSConfig
+		routerApi.Server.StartTLS()
+	} else {
+		routerApi.Server.Start()
+	}
+
+	routerApiClient.URL = routerApi.Server.URL
+}
+
+func theVampRouteIsRequestedFromTheApi(routeName string) error {
+	StartRouterApi()
+	_, routerApiError = routerApiClient.GetRoute(routeName)
+
+	return nil
+}
+
+func theVampRouteIsCreatedThroughTheApi(routeName string) error {
+	StartRouterApi()
+	_, routerApiError = routerApiClient.CreateRoute(&vamprouter.Route{
+		Name:     routeName,
+		Port:     80,
+		Protocol: vamprouter.ProtocolHttp,
+	})
+
+	return nil
+}
+
+func theVampRouterApiShouldHaveReceivedRequests(count int) error {
+	if routerApi.RequestCount() != count {
+		return errors.New(fmt.Sprintf("The API received %d requests while expecting %d", routerApi.RequestCount(), count))
+	}
+
+	return nil
+}
+
+func theRequestShouldSucceed() error {
+	return routerApiError
+}
+
+func theRequestShouldFailWithTheStatus(statusCode int) error {
+	if vamprouter.StatusCode(routerApiError) != statusCode {
+		return errors.New(fmt.Sprintf("Expected the status %d, got the error %v", statusCode, routerApiError))
+	}
+
+	return nil
+}
+
+func theRequestShouldTimeOut() error {
+	if routerApiError == nil || vamprouter.StatusCode(routerApiError) != 0 {
+		return errors.New(fmt.Sprintf("Expected a time out, got the error %v", routerApiError))
+	}
+
+	return nil
+}
+
+func theRequestShouldBeRejectedByTheCircuitBreaker() error {
+	if routerApiError != vamprouter.ErrCircuitOpen {
+		return errors.New(fmt.Sprintf("Expected the circuit to be open, got the error %v", routerApiError))
+	}
+
+	return nil
+}
+
+func theCircuitBreakerShouldBe(state string) error {
+	if routerApiClient.CircuitBreaker.State().String() != state {
+		return errors.New(fmt.Sprintf("The circuit is %s while expecting %s", routerApiClient.CircuitBreaker.State(), state))
+	}
+
+	return nil
+}
+
+func theCircuitBreakerShouldHaveGoneFrom(from string, to string) error {
+	for _, change := range circuitStateChanges {
+		if change == from+" -> "+to {
+			return nil
+		}
+	}
+
+	return errors.New(fmt.Sprintf("The circuit did not go from %s to %s, changes: %v", from, to, circuitStateChanges))
+}
+
+func waitingForMilliseconds(delay int) error {
+	time.Sleep(time.Duration(delay) * time.Millisecond)
+
+	return nil
+}
+
+func CloseRouterApi() {
+	if routerApi != nil && routerApi.Server != nil {
+		routerApi.Server.Close()
+	}
+
+	if routerApiFiles != "" {
+		os.RemoveAll(routerApiFiles)
+	}
+
+	routerApi = nil
+	routerApiClient = nil
+	routerApiError = nil
+	circuitStateChanges = nil
+	routerApiFiles = ""
+	routerApiAuthority = nil
+}
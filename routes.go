@@ -6,7 +6,10 @@ import (
 	"strings"
 	"errors"
 	"fmt"
-	"os"
+	"log"
+	"reflect"
+	"regexp"
+	"sort"
 	"github.com/sroze/kubernetes-vamp-router/vamprouter"
 
 	api "k8s.io/client-go/pkg/api/v1"
@@ -19,13 +22,12 @@ func GetRouteNameFromObjectMetadata(metadata api.ObjectMeta, separator string) s
 	}, separator))
 }
 
-func GetDomainSeparator() string {
-	domainSeparator := os.Getenv("DOMAIN_NAME_SEPARATOR")
-	if "" == domainSeparator {
-		domainSeparator = "-"
-	}
+// Separator of the name and the namespace of the objects in their route names
+// and domain names, set from the configuration of the controller.
+var DomainNameSeparator = "-"
 
-	return domainSeparator
+func GetDomainSeparator() string {
+	return DomainNameSeparator
 }
 
 func GetDNSIdentifier(name string) string {
@@ -43,6 +45,77 @@ func GetMD5Hash(text string) string {
 }
 
 
+// Returns the name of the filter matching the given host and path. The name
+// is the host name when the path is empty.
+func GetFilterName(host string, path string) string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return GetDNSIdentifier(host)
+	}
+
+	return GetDNSIdentifier(host + "-" + strings.Replace(path, "/", "-", -1))
+}
+
+// Returns the HAProxy condition matching the given host and path prefix.
+//
+// Vamp Router generates a single ACL per filter so the host and the path are
+// matched together with the `base` fetch, that is the concatenation of the
+// Host header and the path of the request. The Host header may carry a port,
+// and the path prefix only matches whole segments: `/api` matches `/api` and
+// `/api/users` but not `/apiv2`.
+func GetFilterCondition(host string, path string) string {
+	hostPattern := "^" + regexp.QuoteMeta(host) + "(:[0-9]+)?"
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return "hdr_reg(host) -i " + hostPattern + "$"
+	}
+
+	return "base_reg -i " + hostPattern + "/" + regexp.QuoteMeta(path) + "(/|$)"
+}
+
+// Matches the server name sent in the TLS handshake.
+func GetSniFilterCondition(host string) string {
+	return "req.ssl_sni -i " + host
+}
+
+// HAProxy uses the first matching filter, so the filters matching a path have
+// to come before the ones matching only the host, the longest paths first.
+func SortFiltersBySpecificity(route *vamprouter.Route) {
+	sort.Stable(filtersBySpecificity(route.Filters))
+}
+
+type filtersBySpecificity []vamprouter.Filter
+
+func (filters filtersBySpecificity) Len() int {
+	return len(filters)
+}
+
+func (filters filtersBySpecificity) Swap(i, j int) {
+	filters[i], filters[j] = filters[j], filters[i]
+}
+
+func (filters filtersBySpecificity) Less(i, j int) bool {
+	return GetFilterSpecificity(filters[i]) > GetFilterSpecificity(filters[j])
+}
+
+func GetFilterSpecificity(filter vamprouter.Filter) int {
+	if !strings.HasPrefix(filter.Condition, "base_reg ") {
+		return 0
+	}
+
+	return len(filter.Condition)
+}
+
+func ContainsString(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+
+	return false
+}
+
 func GetFilterInRoute(route *vamprouter.Route, filterName string) (*vamprouter.Filter, error) {
 	for _, filter := range route.Filters {
 		if filter.Name == filterName {
@@ -79,3 +152,318 @@ func GetServiceInRoute(route *vamprouter.Route, serviceName string) (*vamprouter
 
 	return nil, errors.New(fmt.Sprintf("Unable to find service named %s", serviceName))
 }
+
+// Removes the service with the given name from the route. Returns true if the
+// route has been modified.
+func RemoveServiceFromRoute(route *vamprouter.Route, serviceName string) bool {
+	services := []vamprouter.Service{}
+	for _, service := range route.Services {
+		if service.Name != serviceName {
+			services = append(services, service)
+		}
+	}
+
+	removed := len(services) != len(route.Services)
+	route.Services = services
+
+	return removed
+}
+
+// Removes all the filters of the route that are sending the traffic to the
+// given destination. Returns true if the route has been modified.
+func RemoveFiltersWithDestinationFromRoute(route *vamprouter.Route, destination string) bool {
+	filters := []vamprouter.Filter{}
+	for _, filter := range route.Filters {
+		if filter.Destination != destination {
+			filters = append(filters, filter)
+		}
+	}
+
+	removed := len(filters) != len(route.Filters)
+	route.Filters = filters
+
+	return removed
+}
+
+// Returns true if both routes have the same services and filters, whatever
+// their order.
+func RoutesHaveSameRouting(route *vamprouter.Route, otherRoute *vamprouter.Route) bool {
+	if len(route.Services) != len(otherRoute.Services) || len(route.Filters) != len(otherRoute.Filters) {
+		return false
+	}
+
+	otherIndex := NewRouteIndex(otherRoute)
+	for _, filter := range route.Filters {
+		otherFilter, found := otherIndex.GetFilter(otherRoute, filter.Name)
+		if !found || *otherFilter != filter {
+			return false
+		}
+	}
+
+	for _, service := range route.Services {
+		otherService, found := otherIndex.GetService(otherRoute, service.Name)
+		if !found || !reflect.DeepEqual(*otherService, service) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Returns the number of services and filters to add, update or remove for the
+// route to have the routing of the other route.
+func CountRouteDifferences(route *vamprouter.Route, otherRoute *vamprouter.Route) int {
+	differences := 0
+	index := NewRouteIndex(route)
+	otherIndex := NewRouteIndex(otherRoute)
+	for _, filter := range route.Filters {
+		if _, found := otherIndex.GetFilter(otherRoute, filter.Name); !found {
+			differences++
+		}
+	}
+
+	for _, otherFilter := range otherRoute.Filters {
+		filter, found := index.GetFilter(route, otherFilter.Name)
+		if !found || *filter != otherFilter {
+			differences++
+		}
+	}
+
+	for _, service := range route.Services {
+		if _, found := otherIndex.GetService(otherRoute, service.Name); !found {
+			differences++
+		}
+	}
+
+	for _, otherService := range otherRoute.Services {
+		service, found := index.GetService(route, otherService.Name)
+		if !found || !reflect.DeepEqual(*service, otherService) {
+			differences++
+		}
+	}
+
+	return differences
+}
+
+// Copies the route, so that the copy is not modified with the route.
+func CopyRoute(route *vamprouter.Route) *vamprouter.Route {
+	copied := *route
+	copied.Filters = append([]vamprouter.Filter{}, route.Filters...)
+	copied.Services = make([]vamprouter.Service, len(route.Services))
+	for index, service := range route.Services {
+		copied.Services[index] = service
+		copied.Services[index].Servers = append([]vamprouter.Server{}, service.Servers...)
+	}
+
+	return &copied
+}
+
+func FiltersAreEqual(filters []vamprouter.Filter, otherFilters []vamprouter.Filter) bool {
+	if len(filters) != len(otherFilters) {
+		return false
+	}
+
+	for index := range filters {
+		if filters[index] != otherFilters[index] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Removes the services and the filters of the backends owned by the owner from
+// the route, releasing them. Returns whether the route has been modified.
+func RemoveOwnedBackendsFromRoute(route *vamprouter.Route, owner Owner, backendNames []string, ownership *RouteOwnership) bool {
+	services := []vamprouter.Service{}
+	for _, service := range route.Services {
+		serviceOwner, owned := ownership.GetServiceOwner(service.Name)
+		if owned && serviceOwner == owner && ContainsString(backendNames, service.Name) {
+			ownership.ReleaseService(service.Name)
+
+			continue
+		}
+
+		services = append(services, service)
+	}
+
+	filters := []vamprouter.Filter{}
+	for _, filter := range route.Filters {
+		filterOwner, owned := ownership.GetFilterOwner(filter.Name)
+		if owned && filterOwner == owner && ContainsString(backendNames, filter.Destination) {
+			ownership.ReleaseFilter(filter.Name)
+
+			continue
+		}
+
+		filters = append(filters, filter)
+	}
+
+	removed := len(services) != len(route.Services) || len(filters) != len(route.Filters)
+	route.Services = services
+	route.Filters = filters
+
+	return removed
+}
+
+// Returns the filter sending the requests matching the rule to the given
+// service of the route.
+func GetRoutingRuleFilter(route *vamprouter.Route, rule RoutingRule, destination string) vamprouter.Filter {
+	filter := vamprouter.Filter{
+		Name:        GetFilterName(rule.Host, rule.Path),
+		Condition:   GetFilterCondition(rule.Host, rule.Path),
+		Destination: destination,
+	}
+
+	// The TCP routes only know the server name of the TLS connections
+	if route.Protocol == vamprouter.ProtocolTcp {
+		filter.Condition = GetSniFilterCondition(rule.Host)
+	}
+
+	return filter
+}
+
+// Removes the filters of the owner sending the traffic to the given backends
+// that are not the filters of the rules, such as the ones of a domain name the
+// object does not have anymore. Returns whether the route has been modified.
+func RemoveObsoleteFilters(route *vamprouter.Route, rules []RoutingRule, owner Owner, backendNames []string, ownership *RouteOwnership) bool {
+	filterNames := []string{}
+	for _, rule := range rules {
+		filterNames = append(filterNames, GetFilterName(rule.Host, rule.Path))
+	}
+
+	filters := []vamprouter.Filter{}
+	for _, filter := range route.Filters {
+		filterOwner, owned := ownership.GetFilterOwner(filter.Name)
+		if owned && filterOwner == owner && ContainsString(backendNames, filter.Destination) && !ContainsString(filterNames, filter.Name) {
+			log.Println("Removed the obsolete filter", filter.Name, "to the backend", filter.Destination, "from the route", route.Name)
+			ownership.ReleaseFilter(filter.Name)
+
+			continue
+		}
+
+		filters = append(filters, filter)
+	}
+
+	removed := len(filters) != len(route.Filters)
+	route.Filters = filters
+
+	return removed
+}
+
+// Whether the backends and the filters of the rules are in the route as they
+// are, without any other filter to the given backends. The weighted backends,
+// in their own routes, are never considered as applied.
+func RoutingRulesAreApplied(route *vamprouter.Route, index *RouteIndex, rules []RoutingRule, backendNames []string) bool {
+	filterNames := make(map[string]bool)
+	for _, rule := range rules {
+		if rule.Backend.Weight > 0 || !ContainsString(backendNames, rule.Backend.Name) {
+			return false
+		}
+
+		service, found := index.GetService(route, rule.Backend.Name)
+		if !found || service.Weight != rule.Backend.Weight || !ServersAreEqual(service.Servers, GetBackendServers(rule.Backend)) {
+			return false
+		}
+
+		expectedFilter := GetRoutingRuleFilter(route, rule, rule.Backend.Name)
+		filter, found := index.GetFilter(route, expectedFilter.Name)
+		if !found || *filter != expectedFilter {
+			return false
+		}
+
+		filterNames[expectedFilter.Name] = true
+	}
+
+	backendFilters := 0
+	for _, backendName := range UniqueStrings(backendNames) {
+		backendFilters += index.Destinations[backendName]
+	}
+
+	return backendFilters == len(filterNames)
+}
+
+func UniqueStrings(values []string) []string {
+	unique := []string{}
+	for _, value := range values {
+		if !ContainsString(unique, value) {
+			unique = append(unique, value)
+		}
+	}
+
+	return unique
+}
+
+// Positions of the filters and the services of a route, by name, so that the
+// routes having thousands of hosts are not scanned for each of them.
+type RouteIndex struct {
+	Filters  map[string]int
+	Services map[string]int
+
+	// Number of filters sending the traffic to each service
+	Destinations map[string]int
+}
+
+func NewRouteIndex(route *vamprouter.Route) *RouteIndex {
+	index := &RouteIndex{
+		Services: make(map[string]int, len(route.Services)),
+	}
+
+	for position, service := range route.Services {
+		index.Services[service.Name] = position
+	}
+
+	index.IndexFilters(route)
+
+	return index
+}
+
+// Indexes the filters again, once they have been sorted for instance.
+func (index *RouteIndex) IndexFilters(route *vamprouter.Route) {
+	index.Filters = make(map[string]int, len(route.Filters))
+	index.Destinations = make(map[string]int)
+	for position, filter := range route.Filters {
+		index.Filters[filter.Name] = position
+		index.Destinations[filter.Destination]++
+	}
+}
+
+// Returns the filter of the route, that must be changed with `PutFilter`.
+func (index *RouteIndex) GetFilter(route *vamprouter.Route, filterName string) (*vamprouter.Filter, bool) {
+	position, found := index.Filters[filterName]
+	if !found {
+		return nil, false
+	}
+
+	return &route.Filters[position], true
+}
+
+// Returns the service of the route, that can be modified in place.
+func (index *RouteIndex) GetService(route *vamprouter.Route, serviceName string) (*vamprouter.Service, bool) {
+	position, found := index.Services[serviceName]
+	if !found {
+		return nil, false
+	}
+
+	return &route.Services[position], true
+}
+
+// Adds the filter to the route, or replaces the filter having the same name.
+func (index *RouteIndex) PutFilter(route *vamprouter.Route, filter vamprouter.Filter) {
+	if existingFilter, found := index.GetFilter(route, filter.Name); found {
+		index.Destinations[existingFilter.Destination]--
+		*existingFilter = filter
+	} else {
+		route.Filters = append(route.Filters, filter)
+		index.Filters[filter.Name] = len(route.Filters) - 1
+	}
+
+	index.Destinations[filter.Destination]++
+}
+
+func (index *RouteIndex) AddService(route *vamprouter.Route, service vamprouter.Service) *vamprouter.Service {
+	route.Services = append(route.Services, service)
+	index.Services[service.Name] = len(route.Services) - 1
+
+	return &route.Services[len(route.Services)-1]
+}
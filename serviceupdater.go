@@ -1,23 +1,57 @@
 package k8svamprouter
 
 import (
+	"encoding/json"
 	api "k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/util/intstr"
 	"log"
 	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
 )
 
+const HttpPortAnnotation = "vamp-router/http-port"
+
+// The router ports allocated to the TCP ports of the service, as a JSON
+// object such as `{"5432": 20000}`. The load-balancer status has no port, so
+// they are written next to it in the metadata.
+const TcpPortsAnnotation = "vamp-router/tcp-ports"
+
+// The percentages of the traffic of hosts shared with other services that the
+// service receives, as a JSON object such as `{"example.com": 10}`.
+const WeightsAnnotation = "vamp-router/weights"
+
+// Headless services can't be load-balancers, so they are routed to their
+// endpoints when they have this annotation set to "true".
+const ExposeAnnotation = "vamp-router/expose"
+
 type ServiceRepository interface {
+	// Updates the status of the service
 	Update(service *api.Service) (*api.Service, error)
+
+	// Sets the annotation of the service, leaving its specification as it is
+	UpdateAnnotation(service *api.Service, name string, value string) (*api.Service, error)
 }
 
 type Configuration struct {
 	RootDns string
+
+	// Routes the TCP ports other than the HTTP port with TCP routes
+	EnableTcpRoutes bool
+
+	// Routes to the ready endpoints of the services instead of their cluster IP
+	RouteToEndpoints bool
 }
 
 type ServiceUpdater struct {
 	// Kubernetes client
 	ServiceRepository ServiceRepository
 
+	// Gives the endpoints of the services, when routing to them
+	EndpointsRepository EndpointsRepository
+
 	// Updater configuration
 	Configuration Configuration
 }
@@ -36,6 +70,110 @@ func ServiceHasLoadBalancerAddress(service *api.Service) bool {
 	return false
 }
 
+// Returns the port receiving the HTTP traffic: the one given by the
+// `vamp-router/http-port` annotation (name or number), else the one named
+// "http", else the port 80.
+func GetServiceHttpPort(service *api.Service) (*api.ServicePort, error) {
+	if value, found := service.ObjectMeta.Annotations[HttpPortAnnotation]; found {
+		return GetServicePort(service, ParseServicePort(value))
+	}
+
+	servicePort, err := GetServicePort(service, intstr.FromString("http"))
+	if err == nil {
+		return servicePort, nil
+	}
+
+	return GetServicePort(service, intstr.FromInt(80))
+}
+
+// Returns the port receiving the TLS connections passed through: the one
+// named "https", else the port 443.
+func GetServiceTlsPort(service *api.Service) (*api.ServicePort, error) {
+	servicePort, err := GetServicePort(service, intstr.FromString("https"))
+	if err == nil {
+		return servicePort, nil
+	}
+
+	return GetServicePort(service, intstr.FromInt(443))
+}
+
+// Returns the TCP ports of the service other than the HTTP port and, when
+// its TLS connections are passed through, the TLS port.
+func GetServiceTcpPorts(service *api.Service) []api.ServicePort {
+	httpPort, _ := GetServiceHttpPort(service)
+
+	var tlsPort *api.ServicePort
+	if ObjectHasTlsPassthrough(service) {
+		tlsPort, _ = GetServiceTlsPort(service)
+	}
+
+	tcpPorts := []api.ServicePort{}
+	for _, servicePort := range service.Spec.Ports {
+		if servicePort.Protocol != "" && servicePort.Protocol != api.ProtocolTCP {
+			continue
+		} else if httpPort != nil && httpPort.Port == servicePort.Port {
+			continue
+		} else if tlsPort != nil && tlsPort.Port == servicePort.Port {
+			continue
+		}
+
+		tcpPorts = append(tcpPorts, servicePort)
+	}
+
+	return tcpPorts
+}
+
+func GetTcpRouteName(service *api.Service, servicePort api.ServicePort) string {
+	return GetDNSIdentifier(strings.Join([]string{
+		GetRouteNameFromObjectMetadata(service.ObjectMeta, GetDomainSeparator()),
+		strconv.Itoa(int(servicePort.Port)),
+	}, "-"))
+}
+
+// Returns the router ports allocated to the service, indexed by service port.
+func GetServiceAllocatedTcpPorts(service *api.Service) map[string]int {
+	allocatedPorts := make(map[string]int)
+
+	value, found := service.ObjectMeta.Annotations[TcpPortsAnnotation]
+	if !found {
+		return allocatedPorts
+	}
+
+	err := json.Unmarshal([]byte(value), &allocatedPorts)
+	if err != nil {
+		log.Println("Unable to read the allocated TCP ports of the service", service.ObjectMeta.Name, err)
+	}
+
+	return allocatedPorts
+}
+
+// Returns the weights of the service, indexed by host.
+func GetServiceWeights(service *api.Service) (map[string]int, error) {
+	weights := make(map[string]int)
+
+	value, found := service.ObjectMeta.Annotations[WeightsAnnotation]
+	if !found {
+		return weights, nil
+	}
+
+	err := json.Unmarshal([]byte(value), &weights)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read the weights of the service %s: %s", service.ObjectMeta.Name, err)
+	}
+
+	for host, weight := range weights {
+		if weight < 1 || weight > 100 {
+			return nil, fmt.Errorf("The weight of the service %s for the host %s must be between 1 and 100", service.ObjectMeta.Name, host)
+		}
+	}
+
+	return weights, nil
+}
+
+func ServiceIsHeadless(service *api.Service) bool {
+	return service.Spec.ClusterIP == api.ClusterIPNone
+}
+
 func ServiceExposesPort(service *api.Service, port int32) bool {
 	for _, exposedPort := range service.Spec.Ports {
 		if exposedPort.Port == port {
@@ -57,14 +195,30 @@ func (su *ServiceUpdater) ShouldHandleObject(object KubernetesBackendObject) boo
 		return false
 	}
 
-	if service.Spec.Type != api.ServiceTypeLoadBalancer {
+	if ServiceIsHeadless(service) {
+		if !su.Configuration.RouteToEndpoints || service.ObjectMeta.Annotations[ExposeAnnotation] != "true" {
+			log.Println("Skipping headless service", service.ObjectMeta.Name, "as it is not exposed to its endpoints")
+
+			return false
+		}
+	} else if service.Spec.Type != api.ServiceTypeLoadBalancer {
 		log.Println("Skipping service", service.ObjectMeta.Name, "as it is not a LoadBalancer")
 
 		return false
-	} else if !ServiceExposesPort(service, 80) {
-		log.Println("Skipping service", service.ObjectMeta.Name, "because HTTP port is not exposed, other ports are NOT SUPPORTED")
+	}
 
-		return false
+	if _, err := GetServiceHttpPort(service); err != nil {
+		if _, err := GetServiceTlsPort(service); err == nil && ObjectHasTlsPassthrough(service) {
+			return true
+		} else if !su.Configuration.EnableTcpRoutes {
+			log.Println("Skipping service", service.ObjectMeta.Name, "because HTTP port is not exposed and TCP routes are disabled")
+
+			return false
+		} else if len(GetServiceTcpPorts(service)) == 0 {
+			log.Println("Skipping service", service.ObjectMeta.Name, "because it exposes neither an HTTP port nor a TCP port")
+
+			return false
+		}
 	}
 
 	return true
@@ -104,6 +258,21 @@ func (su *ServiceUpdater) GetDomainNames(object KubernetesBackendObject) ([]stri
 	domainSeparator := GetDomainSeparator()
 	domainNames = append(domainNames, GetRouteNameFromObjectMetadata(service.ObjectMeta, domainSeparator)+su.Configuration.RootDns)
 
+	weights, err := GetServiceWeights(service)
+	if err != nil {
+		return nil, err
+	}
+
+	weightedHosts := []string{}
+	for host := range weights {
+		if !ContainsString(domainNames, host) {
+			weightedHosts = append(weightedHosts, host)
+		}
+	}
+
+	sort.Strings(weightedHosts)
+	domainNames = append(domainNames, weightedHosts...)
+
 	return domainNames, nil
 }
 
@@ -116,14 +285,184 @@ func (su *ServiceUpdater) GetRouteName(object KubernetesBackendObject) (string,
 	return GetRouteNameFromObjectMetadata(service.ObjectMeta, GetDomainSeparator()), nil
 }
 
-func (su *ServiceUpdater) GetBackendAddress(object KubernetesBackendObject) (string, error) {
+func (su *ServiceUpdater) GetBackendAddress(object KubernetesBackendObject) (string, int, error) {
 	service, ok := object.(*api.Service)
 	if !ok {
-		return "", fmt.Errorf("Get get only from `Service` objects")
+		return "", 0, fmt.Errorf("Get get only from `Service` objects")
+	}
+
+	servicePort, err := GetServiceHttpPort(service)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return service.Spec.ClusterIP, int(servicePort.Port), nil
+}
+
+func (su *ServiceUpdater) GetBackendNames(object KubernetesBackendObject) ([]string, error) {
+	routeName, err := su.GetRouteName(object)
+	if err != nil {
+		return nil, err
+	}
+
+	return []string{routeName}, nil
+}
+
+func (su *ServiceUpdater) GetRoutingRules(object KubernetesBackendObject) ([]RoutingRule, error) {
+	routeName, err := su.GetRouteName(object)
+	if err != nil {
+		return nil, err
+	}
+
+	// Services without HTTP port are only routed with TCP routes
+	service := object.(*api.Service)
+	servicePort, err := GetServiceHttpPort(service)
+	if err != nil {
+		return []RoutingRule{}, nil
+	}
+
+	backend, err := su.GetServiceBackend(service, routeName, *servicePort)
+	if err != nil {
+		return nil, err
+	}
+
+	domainNames, err := su.GetDomainNames(object)
+	if err != nil {
+		return nil, err
 	}
 
-	return service.Spec.ClusterIP, nil
+	weights, err := GetServiceWeights(service)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := []RoutingRule{}
+	for _, domainName := range domainNames {
+		rule := RoutingRule{
+			Host:    domainName,
+			Backend: backend,
+		}
+
+		rule.Backend.Weight = weights[domainName]
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
 }
 
 // Implementation of `ObjectRoutingResolver`
 // END
+
+// The backend receiving the traffic of the given port of the service: its
+// cluster IP or, when routing to the endpoints, its ready endpoints.
+func (su *ServiceUpdater) GetServiceBackend(service *api.Service, name string, servicePort api.ServicePort) (Backend, error) {
+	if !su.Configuration.RouteToEndpoints {
+		return Backend{
+			Name:    name,
+			Address: service.Spec.ClusterIP,
+			Port:    int(servicePort.Port),
+		}, nil
+	}
+
+	endpoints, err := su.EndpointsRepository.GetEndpoints(service.ObjectMeta.Namespace, service.ObjectMeta.Name)
+	if err != nil {
+		return Backend{}, err
+	}
+
+	return Backend{
+		Name:      name,
+		Endpoints: GetEndpointsOfPort(endpoints, servicePort),
+	}, nil
+}
+
+// START
+// Implementation of `TcpRoutingResolver`
+//
+func (su *ServiceUpdater) GetTcpRules(object KubernetesBackendObject) ([]TcpRule, error) {
+	service, ok := object.(*api.Service)
+	if !ok {
+		return nil, fmt.Errorf("Get get only from `Service` objects")
+	}
+
+	rules := []TcpRule{}
+	if !su.Configuration.EnableTcpRoutes {
+		return rules, nil
+	}
+
+	allocatedPorts := GetServiceAllocatedTcpPorts(service)
+	for _, servicePort := range GetServiceTcpPorts(service) {
+		routeName := GetTcpRouteName(service, servicePort)
+
+		backend, err := su.GetServiceBackend(service, routeName, servicePort)
+		if err != nil {
+			return nil, err
+		}
+
+		rules = append(rules, TcpRule{
+			RouteName: routeName,
+			Port:      allocatedPorts[strconv.Itoa(int(servicePort.Port))],
+			Backend:   backend,
+		})
+	}
+
+	return rules, nil
+}
+
+func (su *ServiceUpdater) UpdateObjectWithTcpPorts(object KubernetesBackendObject, routePorts map[string]int) error {
+	service, ok := object.(*api.Service)
+	if !ok {
+		return fmt.Errorf("Get get only from `Service` objects")
+	}
+
+	allocatedPorts := make(map[string]int)
+	for _, servicePort := range GetServiceTcpPorts(service) {
+		routePort, found := routePorts[GetTcpRouteName(service, servicePort)]
+		if found {
+			allocatedPorts[strconv.Itoa(int(servicePort.Port))] = routePort
+		}
+	}
+
+	if reflect.DeepEqual(allocatedPorts, GetServiceAllocatedTcpPorts(service)) {
+		return nil
+	}
+
+	value, err := json.Marshal(allocatedPorts)
+	if err != nil {
+		return err
+	}
+
+	log.Println("Updating the allocated TCP ports of the service", service.ObjectMeta.Name, "to", string(value))
+
+	updatedService, err := su.ServiceRepository.UpdateAnnotation(service, TcpPortsAnnotation, string(value))
+	if err != nil {
+		return err
+	}
+
+	// Keeps the resource version for the next updates of the object
+	*service = *updatedService
+
+	return nil
+}
+
+// Implementation of `TcpRoutingResolver`
+// END
+
+// START
+// Implementation of `TlsPassthroughResolver`
+//
+func (su *ServiceUpdater) GetTlsPassthroughBackend(object KubernetesBackendObject, domainName string) (Backend, error) {
+	service, ok := object.(*api.Service)
+	if !ok {
+		return Backend{}, fmt.Errorf("Get get only from `Service` objects")
+	}
+
+	servicePort, err := GetServiceTlsPort(service)
+	if err != nil {
+		return Backend{}, err
+	}
+
+	return su.GetServiceBackend(service, GetRouteNameFromObjectMetadata(service.ObjectMeta, GetDomainSeparator()), *servicePort)
+}
+
+// Implementation of `TlsPassthroughResolver`
+// END
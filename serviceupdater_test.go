@@ -3,6 +3,9 @@ package k8svamprouter
 import (
 	"errors"
 	"fmt"
+	"strings"
+	"sync"
+	"time"
 	"github.com/DATA-DOG/godog"
 	"github.com/sroze/kubernetes-vamp-router/vamprouter"
 	api "k8s.io/client-go/pkg/api/v1"
@@ -11,6 +14,20 @@ import (
 type InMemoryVampRouterClient struct {
 	Routes        map[string]*vamprouter.Route
 	UpdatedRoutes []*vamprouter.Route
+
+	// Names of the services sent on their own
+	UpdatedServices []string
+
+	// Error answered to every request, when the router is failing
+	Failure error
+
+	// Time taken to answer the reads of the routes
+	Latency time.Duration
+
+	// Number of routes read
+	Reads int
+
+	mutex sync.Mutex
 }
 
 func NewInMemoryVampRouterClient() *InMemoryVampRouterClient {
@@ -24,42 +41,243 @@ func NewInMemoryVampRouterClient() *InMemoryVampRouterClient {
 }
 
 func (client *InMemoryVampRouterClient) Clear() {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
 	client.UpdatedRoutes = []*vamprouter.Route{}
+	client.UpdatedServices = []string{}
+}
+
+func (client *InMemoryVampRouterClient) UpdatedRouteCount() int {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	return len(client.UpdatedRoutes)
+}
+
+func (client *InMemoryVampRouterClient) SetFailure(failure error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	client.Failure = failure
+}
+
+func (client *InMemoryVampRouterClient) ReadCount() int {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	return client.Reads
+}
+
+func NewRouteNotFoundError() error {
+	return vamprouter.NewError(404, "Route not found", nil)
 }
 
 func (client *InMemoryVampRouterClient) GetRoute(name string) (*vamprouter.Route, error) {
+	client.mutex.Lock()
+	client.Reads++
+	route, err := client.getRoute(name)
+	client.mutex.Unlock()
+
+	time.Sleep(client.Latency)
+
+	return route, err
+}
+
+func (client *InMemoryVampRouterClient) getRoute(name string) (*vamprouter.Route, error) {
+	if client.Failure != nil {
+		return nil, client.Failure
+	}
+
 	route, found := client.Routes[name]
 	if found {
-		return route, nil
+		return CopyRoute(route), nil
 	}
 
-	return nil, errors.New("Route do not exists")
+	return nil, NewRouteNotFoundError()
 }
 
 func (client *InMemoryVampRouterClient) UpdateRoute(route *vamprouter.Route) (*vamprouter.Route, error) {
-	_, found := client.Routes[route.Name]
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	err := client.updateRoute(route)
+	if err != nil {
+		return nil, err
+	}
+
+	return CopyRoute(client.Routes[route.Name]), nil
+}
+
+func (client *InMemoryVampRouterClient) updateRoute(route *vamprouter.Route) error {
+	if client.Failure != nil {
+		return client.Failure
+	}
+
+	if _, found := client.Routes[route.Name]; !found {
+		return NewRouteNotFoundError()
+	}
+
+	client.Routes[route.Name] = CopyRoute(route)
+	client.UpdatedRoutes = append(client.UpdatedRoutes, CopyRoute(route))
+
+	return nil
+}
+
+func (client *InMemoryVampRouterClient) DeleteRoute(name string) error {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	if client.Failure != nil {
+		return client.Failure
+	}
+
+	_, found := client.Routes[name]
 	if !found {
-		return nil, errors.New("Route not found")
+		return NewRouteNotFoundError()
 	}
 
-	client.Routes[route.Name] = route
+	delete(client.Routes, name)
 
-	return route, nil
+	return nil
 }
 
 func (client *InMemoryVampRouterClient) CreateRoute(route *vamprouter.Route) (*vamprouter.Route, error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	if client.Failure != nil {
+		return nil, client.Failure
+	}
+
 	_, found := client.Routes[route.Name]
 	if found {
-		return nil, errors.New("Route already exists")
+		return nil, vamprouter.NewError(409, "Route already exists", nil)
+	}
+
+	client.Routes[route.Name] = CopyRoute(route)
+
+	return CopyRoute(client.Routes[route.Name]), nil
+}
+
+func (client *InMemoryVampRouterClient) ListRoutes() ([]vamprouter.Route, error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	if client.Failure != nil {
+		return nil, client.Failure
 	}
 
-	client.Routes[route.Name] = route
+	routes := []vamprouter.Route{}
+	for _, route := range client.Routes {
+		routes = append(routes, *CopyRoute(route))
+	}
+
+	return routes, nil
+}
+
+// The changes made to the services and filters of a route are recorded as
+// updates of the route.
+func (client *InMemoryVampRouterClient) UpdateRouteWith(routeName string, change func(route *vamprouter.Route) error) error {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	route, err := client.getRoute(routeName)
+	if err != nil {
+		return err
+	}
+
+	err = change(route)
+	if err != nil {
+		return err
+	}
+
+	return client.updateRoute(route)
+}
+
+func (client *InMemoryVampRouterClient) CreateService(routeName string, service *vamprouter.Service) (*vamprouter.Service, error) {
+	return service, client.UpdateRouteWith(routeName, func(route *vamprouter.Route) error {
+		client.UpdatedServices = append(client.UpdatedServices, service.Name)
+
+		if _, err := GetServiceInRoute(route, service.Name); err == nil {
+			return vamprouter.NewError(409, "Service already exists", nil)
+		}
 
-	return route, nil
+		route.Services = append(route.Services, *service)
+
+		return nil
+	})
+}
+
+func (client *InMemoryVampRouterClient) UpdateService(routeName string, service *vamprouter.Service) (*vamprouter.Service, error) {
+	return service, client.UpdateRouteWith(routeName, func(route *vamprouter.Route) error {
+		client.UpdatedServices = append(client.UpdatedServices, service.Name)
+
+		return ReplaceServiceInRoute(route, service.Name, service)
+	})
+}
+
+func (client *InMemoryVampRouterClient) DeleteService(routeName string, serviceName string) error {
+	return client.UpdateRouteWith(routeName, func(route *vamprouter.Route) error {
+		if !RemoveServiceFromRoute(route, serviceName) {
+			return vamprouter.NewError(404, "Service not found", nil)
+		}
+
+		return nil
+	})
+}
+
+func (client *InMemoryVampRouterClient) CreateFilter(routeName string, filter *vamprouter.Filter) (*vamprouter.Filter, error) {
+	return filter, client.UpdateRouteWith(routeName, func(route *vamprouter.Route) error {
+		if _, err := GetFilterInRoute(route, filter.Name); err == nil {
+			return vamprouter.NewError(409, "Filter already exists", nil)
+		}
+
+		route.Filters = append(route.Filters, *filter)
+
+		return nil
+	})
+}
+
+func (client *InMemoryVampRouterClient) UpdateFilter(routeName string, filter *vamprouter.Filter) (*vamprouter.Filter, error) {
+	return filter, client.UpdateRouteWith(routeName, func(route *vamprouter.Route) error {
+		for index := range route.Filters {
+			if route.Filters[index].Name == filter.Name {
+				route.Filters[index] = *filter
+
+				return nil
+			}
+		}
+
+		return vamprouter.NewError(404, "Filter not found", nil)
+	})
+}
+
+func (client *InMemoryVampRouterClient) DeleteFilter(routeName string, filterName string) error {
+	return client.UpdateRouteWith(routeName, func(route *vamprouter.Route) error {
+		for index := range route.Filters {
+			if route.Filters[index].Name == filterName {
+				route.Filters = append(route.Filters[:index], route.Filters[index+1:]...)
+
+				return nil
+			}
+		}
+
+		return vamprouter.NewError(404, "Filter not found", nil)
+	})
 }
 
 var routeManager *VampRouteManager
 
+// The router client of the scenario, behind the route cache when there is one
+func GetInMemoryRouterClient() *InMemoryVampRouterClient {
+	if routeManager.RouteCache != nil {
+		return routeManager.RouteCache.Interface.(*InMemoryVampRouterClient)
+	}
+
+	return routeManager.RouterClient.(*InMemoryVampRouterClient)
+}
+
 func GetCreatedServiceInRoute(route *vamprouter.Route, serviceName string) (vamprouter.Service, error) {
 	for _, service := range route.Services {
 		if service.Name == serviceName {
@@ -93,10 +311,92 @@ func aVampRouteNamedAlreadyExists(routeName string) error {
 	return err
 }
 
+func theVampRouteRoutesToTheBackend(routeName string, domainName string, backendName string) error {
+	client := GetInMemoryRouterClient()
+	route, found := client.Routes[routeName]
+	if !found {
+		return errors.New("Route do not exists")
+	}
+
+	route.Services = append(route.Services, vamprouter.Service{
+		Name: backendName,
+		Servers: []vamprouter.Server{
+			vamprouter.Server{
+				Name: backendName,
+				Host: "9.9.9.9",
+				Port: 80,
+			},
+		},
+	})
+
+	route.Filters = append(route.Filters, vamprouter.Filter{
+		Name:        GetDNSIdentifier(domainName),
+		Condition:   GetFilterCondition(domainName, ""),
+		Destination: backendName,
+	})
+
+	return nil
+}
+
+// The routing was created by the controller for the object, since deleted.
+func theVampRouteRoutesToTheBackendForTheKsObject(routeName string, domainName string, backendName string, objectName string) error {
+	err := theVampRouteRoutesToTheBackend(routeName, domainName, backendName)
+	if err != nil {
+		return err
+	}
+
+	return routeManager.Ownership.Transaction(routeName, func() error {
+		owner := Owner{
+			UID:    "uid-" + objectName,
+			Object: "qwerty/" + objectName,
+		}
+
+		ownership := routeManager.Ownership.Get(routeName)
+		ownership.ClaimService(backendName, owner)
+		ownership.ClaimFilter(GetDNSIdentifier(domainName), owner)
+
+		return nil
+	})
+}
+
+func theVampFilterNamedOfTheVampRouteHasTheCondition(filterName string, routeName string, condition string) error {
+	client := GetInMemoryRouterClient()
+	route, found := client.Routes[routeName]
+	if !found {
+		return errors.New("Route do not exists")
+	}
+
+	for index := range route.Filters {
+		if route.Filters[index].Name == filterName {
+			route.Filters[index].Condition = condition
+
+			return nil
+		}
+	}
+
+	return errors.New(fmt.Sprintf("Unable to find filter named %s", filterName))
+}
+
 /**
  * WHEN
  */
 
+func theRoutesAreReconciled() error {
+	reconciler := &Reconciler{
+		RouterClient: routeManager.RouterClient,
+		RouteLocks:   routeManager.RouteLocks,
+		Ownership:    routeManager.Ownership,
+		Sources: []ReconciliationSource{
+			ReconciliationSource{
+				ObjectLister: repository,
+				RouteManager: routeManager,
+			},
+		},
+	}
+
+	return reconciler.Reconcile()
+}
+
 func theKsServiceNamedisCreated(serviceName string) error {
 	service, err := repository.Get(serviceName)
 	if err != nil {
@@ -115,12 +415,38 @@ func theKsServiceNamedisUpdated(serviceName string) error {
 	return routeManager.UpdateObjectRouting(service)
 }
 
+func theKsServiceNamedIsSynced(serviceName string) error {
+	service, err := repository.Get(serviceName)
+	if err != nil {
+		return err
+	}
+
+	return routeManager.SyncObject(service)
+}
+
+func theKsServiceNamedisDeleted(serviceName string) error {
+	service, err := repository.Get(serviceName)
+	if err != nil {
+		return err
+	}
+
+	return routeManager.RemoveObjectRouting(service)
+}
+
 func aKsServiceNamedIsCreatedInTheNamespace(serviceName string, namespaceName string) error {
 	return routeManager.CreateObjectRoute(&api.Service{
 		ObjectMeta: api.ObjectMeta{
 			Name:      serviceName,
 			Namespace: namespaceName,
 		},
+		Spec: api.ServiceSpec{
+			Ports: []api.ServicePort{
+				api.ServicePort{
+					Name: "http",
+					Port: 80,
+				},
+			},
+		},
 	})
 }
 
@@ -132,6 +458,12 @@ func aKsServiceNamedIsCreatedInTheNamespaceWithTheIP(serviceName string, namespa
 		},
 		Spec: api.ServiceSpec{
 			ClusterIP: IP,
+			Ports: []api.ServicePort{
+				api.ServicePort{
+					Name: "http",
+					Port: 80,
+				},
+			},
 		},
 	})
 }
@@ -144,6 +476,12 @@ func aKsServiceNamedIsUpdatedInTheNamespaceWithTheIP(serviceName string, namespa
 		},
 		Spec: api.ServiceSpec{
 			ClusterIP: IP,
+			Ports: []api.ServicePort{
+				api.ServicePort{
+					Name: "http",
+					Port: 80,
+				},
+			},
 		},
 	})
 }
@@ -179,27 +517,88 @@ func theVampFilterNamedShouldBeCreated(filterName string) error {
 }
 
 func theVampRouteShouldNotBeUpdated() error {
-	client := routeManager.RouterClient.(*InMemoryVampRouterClient)
+	client := GetInMemoryRouterClient()
 	defer client.Clear()
 
-	if len(client.UpdatedRoutes) > 0 {
-		return errors.New(fmt.Sprintf("Found %d updated routes will expecting 0", len(client.UpdatedRoutes)))
+	if client.UpdatedRouteCount() > 0 {
+		return errors.New(fmt.Sprintf("Found %d updated routes will expecting 0", client.UpdatedRouteCount()))
 	}
 
 	return nil
 }
 
 func theVampRouteShouldBeUpdated() error {
-	client := routeManager.RouterClient.(*InMemoryVampRouterClient)
+	client := GetInMemoryRouterClient()
 	defer client.Clear()
 
-	if len(client.UpdatedRoutes) != 0 {
+	if client.UpdatedRouteCount() == 0 {
 		return errors.New("Found 0 updated routes will expecting at least one")
 	}
 
 	return nil
 }
 
+func theVampRouteShouldBeUpdatedOnce() error {
+	client := GetInMemoryRouterClient()
+	defer client.Clear()
+
+	if client.UpdatedRouteCount() != 1 {
+		return errors.New(fmt.Sprintf("Found %d updated routes will expecting exactly one", client.UpdatedRouteCount()))
+	}
+
+	return nil
+}
+
+func theVampServiceShouldNotExist(serviceName string) error {
+	route, err := routeManager.RouterClient.GetRoute("http")
+	if err != nil {
+		return err
+	}
+
+	_, err = GetCreatedServiceInRoute(route, serviceName)
+	if err == nil {
+		return errors.New(fmt.Sprintf("The service %s still exists", serviceName))
+	}
+
+	return nil
+}
+
+func theVampFilterNamedShouldNotExist(filterName string) error {
+	route, err := routeManager.RouterClient.GetRoute("http")
+	if err != nil {
+		return err
+	}
+
+	_, err = GetCreatedFilterInRoute(route, filterName)
+	if err == nil {
+		return errors.New(fmt.Sprintf("The filter %s still exists", filterName))
+	}
+
+	return nil
+}
+
+func theVampRouteShouldNotExist(routeName string) error {
+	_, err := routeManager.RouterClient.GetRoute(routeName)
+	if err == nil {
+		return errors.New(fmt.Sprintf("The route %s exists", routeName))
+	}
+
+	return nil
+}
+
+func theVampRouteIsDeletedByTheOperators(routeName string) error {
+	return routeManager.RouterClient.DeleteRoute(routeName)
+}
+
+func theVampRouteShouldNotBeOwnedByTheController(routeName string) error {
+	ownership := routeManager.Ownership.Get(routeName)
+	if len(ownership.Services) > 0 || len(ownership.Filters) > 0 {
+		return errors.New(fmt.Sprintf("The route %s is still owned by %v", routeName, ownership.Services))
+	}
+
+	return nil
+}
+
 func theVampServiceShouldOnlyContainTheBackend(serviceName string, IP string) error {
 	route, err := routeManager.RouterClient.GetRoute("http")
 	if err != nil {
@@ -222,10 +621,316 @@ func theVampServiceShouldOnlyContainTheBackend(serviceName string, IP string) er
 	return nil
 }
 
+func theVampServiceShouldOnlyContainTheBackendOnThePort(serviceName string, IP string, port int) error {
+	err := theVampServiceShouldOnlyContainTheBackend(serviceName, IP)
+	if err != nil {
+		return err
+	}
+
+	route, err := routeManager.RouterClient.GetRoute("http")
+	if err != nil {
+		return err
+	}
+
+	service, err := GetCreatedServiceInRoute(route, serviceName)
+	if err != nil {
+		return err
+	}
+
+	if port != service.Servers[0].Port {
+		return errors.New(fmt.Sprintf("Expected to find the port %d, but found %d", port, service.Servers[0].Port))
+	}
+
+	return nil
+}
+
+func theKsServiceShouldBeHandled(serviceName string) error {
+	service, err := repository.Get(serviceName)
+	if err != nil {
+		return err
+	}
+
+	if !routeManager.ShouldHandleObject(service) {
+		return errors.New(fmt.Sprintf("The service %s is not handled", serviceName))
+	}
+
+	return nil
+}
+
+func theKsServiceShouldNotBeHandled(serviceName string) error {
+	service, err := repository.Get(serviceName)
+	if err != nil {
+		return err
+	}
+
+	if routeManager.ShouldHandleObject(service) {
+		return errors.New(fmt.Sprintf("The service %s is handled", serviceName))
+	}
+
+	return nil
+}
+
+func tcpRoutesAreEnabledWithThePortRange(portRange string) error {
+	portAllocator, err := ParsePortRange(portRange)
+	if err != nil {
+		return err
+	}
+
+	routeManager.TcpPortAllocator = portAllocator
+	routeManager.ObjectRoutingResolver.(*ServiceUpdater).Configuration.EnableTcpRoutes = true
+
+	return nil
+}
+
+func theAllocatedTcpPortsAreReserved() error {
+	objects, _, err := repository.List()
+	if err != nil {
+		return err
+	}
+
+	routeManager.ReserveTcpPorts(objects)
+
+	return nil
+}
+
+func theKsServiceNamedCannotBeCreated(serviceName string) error {
+	err := theKsServiceNamedisCreated(serviceName)
+	if err == nil {
+		return errors.New(fmt.Sprintf("The service %s was created", serviceName))
+	}
+
+	return nil
+}
+
+func theKsServiceNamedWaitsForTheWeightsOfItsHosts(serviceName string) error {
+	err := theKsServiceNamedisCreated(serviceName)
+	if err == nil || !strings.Contains(err.Error(), "instead of 100") {
+		return errors.New(fmt.Sprintf("Expected the service %s to wait for the weights of its hosts, got: %v", serviceName, err))
+	}
+
+	return nil
+}
+
+func theKsServiceNamedCannotBeDeleted(serviceName string) error {
+	err := theKsServiceNamedisDeleted(serviceName)
+	if err == nil {
+		return errors.New(fmt.Sprintf("The service %s was deleted", serviceName))
+	}
+
+	return nil
+}
+
+func theTlsConnectionsArePassedThroughOnThePort(port int) error {
+	routeManager.TlsPassthroughPort = port
+	ingressRouteManager.TlsPassthroughPort = port
+
+	return nil
+}
+
+func theVampRouteShouldListenOnThePort(routeName string, port int) error {
+	route, err := routeManager.RouterClient.GetRoute(routeName)
+	if err != nil {
+		return err
+	}
+
+	if route.Port != port {
+		return errors.New(fmt.Sprintf("Expected the route to listen on the port %d, found %d", port, route.Port))
+	}
+
+	return nil
+}
+
+func theVampRouteShouldOnlyContainTheBackendOnThePort(routeName string, IP string, port int) error {
+	route, err := routeManager.RouterClient.GetRoute(routeName)
+	if err != nil {
+		return err
+	}
+
+	if len(route.Services) != 1 || len(route.Services[0].Servers) != 1 {
+		return errors.New("Expected to have 1 service with 1 server in the route")
+	}
+
+	server := route.Services[0].Servers[0]
+	if server.Host != IP || server.Port != port {
+		return errors.New(fmt.Sprintf("Expected the backend %s:%d, found %s:%d", IP, port, server.Host, server.Port))
+	}
+
+	return nil
+}
+
+func theRouterPortOfThePortOfTheKsServiceShouldBe(servicePort string, serviceName string, port int) error {
+	service, err := repository.Get(serviceName)
+	if err != nil {
+		return err
+	}
+
+	allocatedPort := GetServiceAllocatedTcpPorts(service)[servicePort]
+	if allocatedPort != port {
+		return errors.New(fmt.Sprintf("Expected the router port %d, found %d", port, allocatedPort))
+	}
+
+	return nil
+}
+
+func theRouterPortsAreAllocatedFromTheRange(portRange string) error {
+	portAllocator, err := ParsePortRange(portRange)
+	if err != nil {
+		return err
+	}
+
+	routeManager.TcpPortAllocator = portAllocator
+
+	return nil
+}
+
+func noRouterPortRangeIsConfigured() error {
+	routeManager.TcpPortAllocator = nil
+
+	return nil
+}
+
+func theVampRouteAlreadyListensOnThePort(routeName string, port int) error {
+	_, err := routeManager.RouterClient.CreateRoute(&vamprouter.Route{
+		Name:     routeName,
+		Port:     port,
+		Protocol: vamprouter.ProtocolHttp,
+	})
+
+	return err
+}
+
+func theVampTcpRouteAlreadyListensOnThePort(routeName string, port int) error {
+	_, err := routeManager.RouterClient.CreateRoute(&vamprouter.Route{
+		Name:     routeName,
+		Port:     port,
+		Protocol: vamprouter.ProtocolTcp,
+	})
+
+	return err
+}
+
+func thePortsOfTheWeightedRoutesAreReserved() error {
+	routes, err := routeManager.RouterClient.ListRoutes()
+	if err != nil {
+		return err
+	}
+
+	routeManager.ReserveWeightedRoutePorts(routes)
+
+	return nil
+}
+
+func theVampServiceOfTheVampRouteShouldHaveTheWeight(serviceName string, routeName string, weight int) error {
+	route, err := routeManager.RouterClient.GetRoute(routeName)
+	if err != nil {
+		return err
+	}
+
+	service, err := GetCreatedServiceInRoute(route, serviceName)
+	if err != nil {
+		return err
+	}
+
+	if service.Weight != weight {
+		return errors.New(fmt.Sprintf("Expected the weight %d, found %d", weight, service.Weight))
+	}
+
+	return nil
+}
+
+func theWeightsOfTheVampRouteShouldAddUpTo100(routeName string) error {
+	route, err := routeManager.RouterClient.GetRoute(routeName)
+	if err != nil {
+		return err
+	}
+
+	return ValidateRouteWeights(route)
+}
+
+func theWeightsOfTheVampRouteShouldNotAddUpTo100(routeName string) error {
+	route, err := routeManager.RouterClient.GetRoute(routeName)
+	if err != nil {
+		return err
+	}
+
+	if ValidateRouteWeights(route) == nil {
+		return errors.New(fmt.Sprintf("The weights of the route %s add up to 100", routeName))
+	}
+
+	return nil
+}
+
+func theVampRouterFailsWithTheStatus(statusCode int) error {
+	client := GetInMemoryRouterClient()
+	client.SetFailure(vamprouter.NewError(statusCode, "Router failure", nil))
+
+	return nil
+}
+
+func theVampRouterRecovers() error {
+	client := GetInMemoryRouterClient()
+	client.SetFailure(nil)
+
+	return nil
+}
+
+func theVampRouteHasTheVampServiceWithoutFilter(routeName string, serviceName string) error {
+	client := GetInMemoryRouterClient()
+	route, found := client.Routes[routeName]
+	if !found {
+		return errors.New("Route do not exists")
+	}
+
+	route.Services = append(route.Services, vamprouter.Service{
+		Name: serviceName,
+		Servers: []vamprouter.Server{
+			vamprouter.Server{
+				Name: serviceName,
+				Host: "9.9.9.9",
+				Port: 80,
+			},
+		},
+	})
+
+	return nil
+}
+
+func theVampServiceOfTheVampRouteIsReplacedByTheVampService(serviceName string, routeName string, replacementName string) error {
+	originalRoute, err := routeManager.RouterClient.GetRoute(routeName)
+	if err != nil {
+		return err
+	}
+
+	route := CopyRoute(originalRoute)
+	for index := range route.Services {
+		if route.Services[index].Name == serviceName {
+			route.Services[index].Name = replacementName
+		}
+	}
+
+	return routeManager.SendRouteChanges(originalRoute, route)
+}
+
+func onlyTheVampServiceShouldHaveBeenSent(serviceName string) error {
+	client := GetInMemoryRouterClient()
+
+	if len(client.UpdatedServices) != 1 || client.UpdatedServices[0] != serviceName {
+		return errors.New(fmt.Sprintf("Expected only the service %s to be sent, found %v", serviceName, client.UpdatedServices))
+	}
+
+	return nil
+}
+
 func FeatureContext(s *godog.Suite) {
 	s.BeforeScenario(func(interface{}) {
+		ResetMetrics()
+		backgroundSyncs = nil
+		ResetConfiguration()
+		ResetClusterClientConfig()
+
+		routerClient := NewInMemoryVampRouterClient()
 		routeManager = &VampRouteManager{
-			RouterClient: NewInMemoryVampRouterClient(),
+			RouterClient: routerClient,
 			ObjectRoutingResolver: &ServiceUpdater{
 				ServiceRepository: NewInMemoryServiceRepository(),
 				Configuration: Configuration{
@@ -233,6 +938,22 @@ func FeatureContext(s *godog.Suite) {
 				},
 			},
 		}
+
+		NewServiceWatcher()
+		NewIngressRouteManager(routerClient)
+		routeManager.RouteLocks = NewRouteLocks()
+		routeManager.Ownership = NewOwnershipRegistry(nil)
+		ingressRouteManager.RouteLocks = routeManager.RouteLocks
+		ingressRouteManager.Ownership = routeManager.Ownership
+		NewServiceWorkQueue()
+	})
+
+	s.AfterScenario(func(interface{}, error) {
+		CloseRouterApi()
+		StopServiceWorkQueue()
+		ReleaseStuckHandler()
+		ResetConfiguration()
+		ResetClusterClientConfig()
 	})
 
 	s.Step(`^a k8s service named "([^"]*)" is created in the namespace "([^"]*)"$`, aKsServiceNamedIsCreatedInTheNamespace)
@@ -245,9 +966,181 @@ func FeatureContext(s *godog.Suite) {
 	s.Step(`^a k8s service named "([^"]*)" is updated in the namespace "([^"]*)" with the IP "([^"]*)"$`, aKsServiceNamedIsUpdatedInTheNamespaceWithTheIP)
 	s.Step(`^the vamp route should not be updated$`, theVampRouteShouldNotBeUpdated)
 	s.Step(`^the vamp route should be updated$`, theVampRouteShouldBeUpdated)
+	s.Step(`^the vamp route should be updated once$`, theVampRouteShouldBeUpdatedOnce)
+	s.Step(`^the vamp service "([^"]*)" should not exist$`, theVampServiceShouldNotExist)
+	s.Step(`^the vamp filter named "([^"]*)" should not exist$`, theVampFilterNamedShouldNotExist)
+	s.Step(`^the vamp route "([^"]*)" should not exist$`, theVampRouteShouldNotExist)
+	s.Step(`^the vamp route "([^"]*)" is deleted by the operators$`, theVampRouteIsDeletedByTheOperators)
+	s.Step(`^the vamp route "([^"]*)" should not be owned by the controller$`, theVampRouteShouldNotBeOwnedByTheController)
+	s.Step(`^the k8s service named "([^"]*)" is deleted$`, theKsServiceNamedisDeleted)
+	s.Step(`^the k8s service "([^"]*)" is a load-balancer exposing the port (\d+)$`, theKsServiceIsALoadBalancerExposingThePort)
+	s.Step(`^the vamp route "([^"]*)" routes "([^"]*)" to the backend "([^"]*)"$`, theVampRouteRoutesToTheBackend)
+	s.Step(`^the vamp route "([^"]*)" routes "([^"]*)" to the backend "([^"]*)" for the k8s (?:service|ingress) "([^"]*)"$`, theVampRouteRoutesToTheBackendForTheKsObject)
+	s.Step(`^the ownership of the vamp routes is stored in the k8s config map "([^"]*)"$`, theOwnershipOfTheVampRoutesIsStoredInTheKsConfigMap)
+	s.Step(`^the controller is restarted$`, theControllerIsRestarted)
+	s.Step(`^the metric '([^']*)' should be ([0-9.]+)$`, theMetricShouldBe)
+	s.Step(`^the metric '([^']*)' should not be exposed$`, theMetricShouldNotBeExposed)
+	s.Step(`^the health of the controller is checked with a router silence of (\d+) milliseconds$`, theHealthOfTheControllerIsCheckedWithARouterSilenceOfMilliseconds)
+	s.Step(`^the routes are reconciled at startup$`, theRoutesAreReconciledAtStartup)
+	s.Step(`^the watcher of the k8s services is dead after (\d+) milliseconds$`, theWatcherOfTheKsServicesIsDeadAfterMilliseconds)
+	s.Step(`^the handling of the k8s services is stuck$`, theHandlingOfTheKsServicesIsStuck)
+	s.Step(`^the controller should be alive$`, theControllerShouldBeAlive)
+	s.Step(`^the controller should not be alive$`, theControllerShouldNotBeAlive)
+	s.Step(`^the controller should be ready$`, theControllerShouldBeReady)
+	s.Step(`^the controller should not be ready$`, theControllerShouldNotBeReady)
+	s.Step(`^the replicas "([^"]*)" and "([^"]*)" compete for a lease of (\d+) milliseconds$`, theReplicasAndCompeteForALeaseOfMilliseconds)
+	s.Step(`^the replicas "([^"]*)" and "([^"]*)" compete for a lease of (\d+) milliseconds in the k8s config map "([^"]*)"$`, theReplicasAndCompeteForALeaseOfMillisecondsInTheKsConfigMap)
+	s.Step(`^the replica "([^"]*)" tries to acquire the lease$`, theReplicaTriesToAcquireTheLease)
+	s.Step(`^the replica "([^"]*)" releases the lease$`, theReplicaReleasesTheLease)
+	s.Step(`^the replica "([^"]*)" should lead$`, theReplicaShouldLead)
+	s.Step(`^the replica "([^"]*)" should not lead$`, theReplicaShouldNotLead)
+	s.Step(`^the k8s services are routed by the replica "([^"]*)"$`, theServicesAreRoutedByTheReplica)
+	s.Step(`^the routes are reconciled by the replica "([^"]*)"$`, theRoutesAreReconciledByTheReplica)
+	s.Step(`^the routes are reconciled$`, theRoutesAreReconciled)
+	s.Step(`^the vamp service "([^"]*)" should only contain the backend "([^"]*)" on the port (\d+)$`, theVampServiceShouldOnlyContainTheBackendOnThePort)
+	s.Step(`^the k8s service "([^"]*)" exposes the port (\d+) named "([^"]*)"$`, theKsServiceExposesThePortNamed)
+	s.Step(`^the k8s service "([^"]*)" should be handled$`, theKsServiceShouldBeHandled)
+	s.Step(`^the k8s service "([^"]*)" should not be handled$`, theKsServiceShouldNotBeHandled)
+	s.Step(`^the k8s service "([^"]*)" in the namespace "([^"]*)" exposes the port (\d+) named "([^"]*)"$`, theKsServiceInTheNamespaceExposesThePortNamed)
+	s.Step(`^TCP routes are enabled with the port range "([^"]*)"$`, tcpRoutesAreEnabledWithThePortRange)
+	s.Step(`^the TLS connections are passed through on the port (\d+)$`, theTlsConnectionsArePassedThroughOnThePort)
+	s.Step(`^the allocated TCP ports are reserved$`, theAllocatedTcpPortsAreReserved)
+	s.Step(`^the k8s service named "([^"]*)" cannot be created$`, theKsServiceNamedCannotBeCreated)
+	s.Step(`^the k8s service named "([^"]*)" waits for the weights of its hosts$`, theKsServiceNamedWaitsForTheWeightsOfItsHosts)
+	s.Step(`^the k8s service named "([^"]*)" cannot be deleted$`, theKsServiceNamedCannotBeDeleted)
+	s.Step(`^the vamp route "([^"]*)" should listen on the port (\d+)$`, theVampRouteShouldListenOnThePort)
+	s.Step(`^the vamp route "([^"]*)" should only contain the backend "([^"]*)" on the port (\d+)$`, theVampRouteShouldOnlyContainTheBackendOnThePort)
+	s.Step(`^the router port of the port (\d+) of the k8s service "([^"]*)" should be (\d+)$`, theRouterPortOfThePortOfTheKsServiceShouldBe)
+	s.Step(`^the k8s ingress named "([^"]*)" cannot be created$`, theKsIngressNamedCannotBeCreated)
+	s.Step(`^the certificates of the ingresses cannot be provisioned$`, theCertificatesOfTheIngressesCannotBeProvisioned)
+	s.Step(`^the ingress routes are reconciled$`, theIngressRoutesAreReconciled)
+	s.Step(`^the k8s ingress "([^"]*)" has the following annotations:$`, theKsIngressHasTheFollowingAnnotations)
+	s.Step(`^the k8s ingress "([^"]*)" terminates TLS for the hosts "([^"]*)" with the secret "([^"]*)"$`, theKsIngressTerminatesTLSForTheHostsWithTheSecret)
+	s.Step(`^the k8s secret "([^"]*)" in the namespace "([^"]*)" contains the certificate "([^"]*)" and the key "([^"]*)"$`, theKsSecretInTheNamespaceContainsTheCertificateAndTheKey)
+	s.Step(`^the HTTPS redirects are served by "([^"]*)"$`, theHTTPSRedirectsAreServedBy)
+	s.Step(`^the certificate "([^"]*)" should contain the certificate "([^"]*)" and the key "([^"]*)"$`, theCertificateShouldContainTheCertificateAndTheKey)
+	s.Step(`^the certificate "([^"]*)" should not be stored$`, theCertificateShouldNotBeStored)
+	s.Step(`^the k8s secret "([^"]*)" in the namespace "([^"]*)" is rotated to the certificate "([^"]*)" and the key "([^"]*)"$`, theKsSecretInTheNamespaceIsRotatedToTheCertificateAndTheKey)
+	s.Step(`^the certificate "([^"]*)" is stored by the operators$`, theCertificateIsStoredByTheOperators)
+	s.Step(`^the k8s ingress "([^"]*)" is deleted while not watching$`, theKsIngressIsDeletedWhileNotWatching)
+	s.Step(`^the HTTPS redirect of "([^"]*)" should be "([^"]*)"$`, theHTTPSRedirectOfShouldBe)
+	s.Step(`^the vamp filter named "([^"]*)" of the vamp route "([^"]*)" should route to the vamp service "([^"]*)"$`, theVampFilterNamedOfTheVampRouteShouldRouteToTheVampService)
+	s.Step(`^the vamp filter named "([^"]*)" of the vamp route "([^"]*)" should have the condition "([^"]*)"$`, theVampFilterNamedOfTheVampRouteShouldHaveTheCondition)
+	s.Step(`^the vamp filter named "([^"]*)" of the vamp route "([^"]*)" should not exist$`, theVampFilterNamedOfTheVampRouteShouldNotExist)
+	s.Step(`^routing to the endpoints is enabled$`, routingToTheEndpointsIsEnabled)
+	s.Step(`^the k8s service "([^"]*)" is headless$`, theKsServiceIsHeadless)
+	s.Step(`^the k8s service "([^"]*)" has the ready endpoint "([^"]*)" on the port (\d+) named "([^"]*)"$`, theKsServiceHasTheReadyEndpointOnThePortNamed)
+	s.Step(`^the k8s service "([^"]*)" has the not ready endpoint "([^"]*)" on the port (\d+) named "([^"]*)"$`, theKsServiceHasTheNotReadyEndpointOnThePortNamed)
+	s.Step(`^the k8s service "([^"]*)" loses the endpoint "([^"]*)"$`, theKsServiceLosesTheEndpoint)
+	s.Step(`^the endpoints of the k8s service "([^"]*)" are updated$`, theEndpointsOfTheKsServiceAreUpdated)
+	s.Step(`^the endpoints "([^"]*)" in the namespace "([^"]*)" are updated$`, theEndpointsInTheNamespaceAreUpdated)
+	s.Step(`^the vamp service "([^"]*)" should contain the servers:$`, theVampServiceShouldContainTheServers)
+	s.Step(`^the router ports are allocated from the range "([^"]*)"$`, theRouterPortsAreAllocatedFromTheRange)
+	s.Step(`^no router port range is configured$`, noRouterPortRangeIsConfigured)
+	s.Step(`^the vamp route "([^"]*)" already listens on the port (\d+)$`, theVampRouteAlreadyListensOnThePort)
+	s.Step(`^the ports of the weighted routes are reserved$`, thePortsOfTheWeightedRoutesAreReserved)
+	s.Step(`^the vamp service "([^"]*)" of the vamp route "([^"]*)" should have the weight (\d+)$`, theVampServiceOfTheVampRouteShouldHaveTheWeight)
+	s.Step(`^the weights of the vamp route "([^"]*)" should add up to 100$`, theWeightsOfTheVampRouteShouldAddUpTo100)
+	s.Step(`^the weights of the vamp route "([^"]*)" should not add up to 100$`, theWeightsOfTheVampRouteShouldNotAddUpTo100)
+	s.Step(`^the vamp router fails with the status (\d+)$`, theVampRouterFailsWithTheStatus)
+	s.Step(`^the vamp router recovers$`, theVampRouterRecovers)
+	s.Step(`^only the vamp service "([^"]*)" should have been sent$`, onlyTheVampServiceShouldHaveBeenSent)
+	s.Step(`^the vamp route "([^"]*)" has the vamp service "([^"]*)" without filter$`, theVampRouteHasTheVampServiceWithoutFilter)
+	s.Step(`^the vamp service "([^"]*)" of the vamp route "([^"]*)" is replaced by the vamp service "([^"]*)"$`, theVampServiceOfTheVampRouteIsReplacedByTheVampService)
+	s.Step(`^the k8s ingress "([^"]*)" is in the namespace "([^"]*)"$`, theKsIngressIsInTheNamespace)
+	s.Step(`^the k8s ingress "([^"]*)" has the default backend "([^"]*)" on the port "([^"]*)"$`, theKsIngressHasTheDefaultBackendOnThePort)
+	s.Step(`^the k8s ingress "([^"]*)" has the following rules:$`, theKsIngressHasTheFollowingRules)
+	s.Step(`^the k8s ingress named "([^"]*)" is created$`, theKsIngressNamedIsCreated)
+	s.Step(`^the k8s ingress named "([^"]*)" is deleted$`, theKsIngressNamedIsDeleted)
+	s.Step(`^the k8s ingress named "([^"]*)" is updated$`, theKsIngressNamedIsUpdated)
+	s.Step(`^the k8s ingress "([^"]*)" no longer terminates TLS$`, theKsIngressNoLongerTerminatesTls)
+	s.Step(`^the vamp filter named "([^"]*)" should have the condition "([^"]*)"$`, theVampFilterNamedShouldHaveTheCondition)
+	s.Step(`^the vamp filter named "([^"]*)" should route to the vamp service "([^"]*)"$`, theVampFilterNamedShouldRouteToTheVampService)
+	s.Step(`^the vamp filter named "([^"]*)" should be before the vamp filter named "([^"]*)"$`, theVampFilterNamedShouldBeBeforeTheVampFilterNamed)
+	s.Step(`^the request to "([^"]*)" should be routed to the vamp service "([^"]*)"$`, theRequestToShouldBeRoutedToTheVampService)
+	s.Step(`^the request to "([^"]*)" should not be routed$`, theRequestToShouldNotBeRouted)
+	s.Step(`^the k8s services are listed at the resource version "([^"]*)"$`, theKsServicesAreListedAtTheResourceVersion)
+	s.Step(`^the k8s service "([^"]*)" will be modified at the resource version "([^"]*)"$`, theKsServiceWillBeModifiedAtTheResourceVersion)
+	s.Step(`^the k8s service "([^"]*)" will be deleted at the resource version "([^"]*)"$`, theKsServiceWillBeDeletedAtTheResourceVersion)
+	s.Step(`^the watch of the k8s services will fail because the resource version is too old$`, theWatchOfTheKsServicesWillFailBecauseTheResourceVersionIsTooOld)
+	s.Step(`^the k8s service "([^"]*)" is removed from the cluster$`, theKsServiceIsRemovedFromTheCluster)
+	s.Step(`^the k8s services are watched$`, theKsServicesAreWatched)
+	s.Step(`^the k8s services should be watched with an error$`, theKsServicesShouldBeWatchedWithAnError)
+	s.Step(`^the k8s services should have been listed (\d+) times?$`, theKsServicesShouldHaveBeenListedTimes)
+	s.Step(`^the k8s services should have been watched from the resource version "([^"]*)"$`, theKsServicesShouldHaveBeenWatchedFromTheResourceVersion)
 	s.Step(`^the k8s service "([^"]*)" is in the namespace "([^"]*)"$`, theKsServiceisInTheNamespace)
 	s.Step(`^the k8s service "([^"]*)" IP is "([^"]*)"$`, theKsServiceIPIs)
 	s.Step(`^the k8s service named "([^"]*)" is created$`, theKsServiceNamedisCreated)
 	s.Step(`^the k8s service named "([^"]*)" is updated$`, theKsServiceNamedisUpdated)
+	s.Step(`^the k8s service named "([^"]*)" is synced$`, theKsServiceNamedIsSynced)
+	s.Step(`^the k8s service "([^"]*)" no longer exposes the port (\d+)$`, theKsServiceNoLongerExposesThePort)
+	s.Step(`^the k8s service "([^"]*)" is no longer a load-balancer$`, theKsServiceIsNoLongerALoadBalancer)
+	s.Step(`^the k8s service "([^"]*)" is deleted while not watching$`, theKsServiceIsDeletedWhileNotWatching)
+	s.Step(`^the vamp TCP route "([^"]*)" already listens on the port (\d+)$`, theVampTcpRouteAlreadyListensOnThePort)
 	s.Step(`^the k8s service "([^"]*)" has the following annotations:$`, theKsServicehasTheFollowingAnnotations)
+
+	s.Step(`^the vamp router API answers with the statuses "([^"]*)"$`, theVampRouterApiAnswersWithTheStatuses)
+	s.Step(`^the vamp router API answers after (\d+) milliseconds$`, theVampRouterApiAnswersAfterMilliseconds)
+	s.Step(`^the vamp router client times out after (\d+) milliseconds$`, theVampRouterClientTimesOutAfterMilliseconds)
+	s.Step(`^the vamp router client retries (\d+) times$`, theVampRouterClientRetriesTimes)
+	s.Step(`^the circuit breaker opens after (\d+) failures$`, theCircuitBreakerOpensAfterFailures)
+	s.Step(`^the circuit breaker is reset after (\d+) milliseconds$`, theCircuitBreakerIsResetAfterMilliseconds)
+	s.Step(`^the vamp route "([^"]*)" is requested from the API$`, theVampRouteIsRequestedFromTheApi)
+	s.Step(`^the vamp route "([^"]*)" is created through the API$`, theVampRouteIsCreatedThroughTheApi)
+	s.Step(`^the vamp router API should have received (\d+) requests?$`, theVampRouterApiShouldHaveReceivedRequests)
+	s.Step(`^the request should succeed$`, theRequestShouldSucceed)
+	s.Step(`^the request should fail with the status (\d+)$`, theRequestShouldFailWithTheStatus)
+	s.Step(`^the request should time out$`, theRequestShouldTimeOut)
+	s.Step(`^the request should be rejected by the circuit breaker$`, theRequestShouldBeRejectedByTheCircuitBreaker)
+	s.Step(`^the circuit breaker should be "([^"]*)"$`, theCircuitBreakerShouldBe)
+	s.Step(`^the circuit breaker should have gone from "([^"]*)" to "([^"]*)"$`, theCircuitBreakerShouldHaveGoneFrom)
+	s.Step(`^(\d+) milliseconds have passed$`, waitingForMilliseconds)
+	s.Step(`^the vamp router API requires the bearer token "([^"]*)"$`, theVampRouterApiRequiresTheBearerToken)
+	s.Step(`^the vamp router API requires the username "([^"]*)" and the password "([^"]*)"$`, theVampRouterApiRequiresTheUsernameAndThePassword)
+	s.Step(`^the vamp router client reads its token from a file containing "([^"]*)"$`, theVampRouterClientReadsItsTokenFromAFileContaining)
+	s.Step(`^the token file now contains "([^"]*)"$`, theTokenFileNowContains)
+	s.Step(`^the vamp router client reads its username and password from files containing "([^"]*)" and "([^"]*)"$`, theVampRouterClientReadsItsUsernameAndPasswordFromFilesContainingAnd)
+	s.Step(`^the vamp router API is served over TLS$`, theVampRouterApiIsServedOverTLS)
+	s.Step(`^the vamp router API requires a client certificate$`, theVampRouterApiRequiresAClientCertificate)
+	s.Step(`^the vamp router client trusts the authority of the API$`, theVampRouterClientTrustsTheAuthorityOfTheApi)
+	s.Step(`^the vamp router client presents a client certificate$`, theVampRouterClientPresentsAClientCertificate)
+	s.Step(`^the request should fail$`, theRequestShouldFail)
+	s.Step(`^(\d+) k8s services and (\d+) k8s ingresses are created concurrently$`, ksServicesAndKsIngressesAreCreatedConcurrently)
+	s.Step(`^the vamp route "([^"]*)" should have (\d+) filters and (\d+) services$`, theVampRouteShouldHaveFiltersAndServices)
+	s.Step(`^the vamp router takes (\d+) milliseconds? to answer$`, theVampRouterTakesMillisecondsToAnswer)
+	s.Step(`^the k8s service "([^"]*)" is queued (\d+) times?$`, theKsServiceIsQueuedTimes)
+	s.Step(`^the deletion of the k8s service "([^"]*)" is queued$`, theDeletionOfTheKsServiceIsQueued)
+	s.Step(`^the work queue is run with (\d+) workers?$`, theWorkQueueIsRunWithWorkers)
+	s.Step(`^the work queue should become idle$`, theWorkQueueShouldBecomeIdle)
+	s.Step(`^the k8s service "([^"]*)" should be retried$`, theKsServiceShouldBeRetried)
+	s.Step(`^the k8s objects should have been synced (\d+) times?$`, theKsObjectsShouldHaveBeenSyncedTimes)
+	s.Step(`^at most (\d+) k8s objects should have been synced at once$`, atMostKsObjectsShouldHaveBeenSyncedAtOnce)
+	s.Step(`^the retry delay after (\d+) failures? should be "([^"]*)"$`, theRetryDelayAfterFailuresShouldBe)
+	s.Step(`^the route changes are batched for (\d+) milliseconds$`, theRouteChangesAreBatchedForMilliseconds)
+	s.Step(`^the batched changes of the vamp route "([^"]*)" are sent$`, theBatchedChangesOfTheVampRouteAreSent)
+	s.Step(`^the batched changes of the vamp route "([^"]*)" cannot be sent$`, theBatchedChangesOfTheVampRouteCannotBeSent)
+	s.Step(`^the k8s service named "([^"]*)" is created in the background$`, theKsServiceNamedIsCreatedInTheBackground)
+	s.Step(`^the k8s service named "([^"]*)" is deleted in the background$`, theKsServiceNamedIsDeletedInTheBackground)
+	s.Step(`^the background syncs should succeed$`, theBackgroundSyncsShouldSucceed)
+	s.Step(`^the background syncs should fail$`, theBackgroundSyncsShouldFail)
+	s.Step(`^the vamp filter named "([^"]*)" of the vamp route "([^"]*)" has the condition "([^"]*)"$`, theVampFilterNamedOfTheVampRouteHasTheCondition)
+	s.Step(`^the vamp routes are cached for (\d+) milliseconds$`, theVampRoutesAreCachedForMilliseconds)
+	s.Step(`^the vamp routes should have been read (\d+) times?$`, theVampRoutesShouldHaveBeenReadTimes)
+	s.Step(`^the environment variable "([^"]*)" is "([^"]*)"$`, theEnvironmentVariableIs)
+	s.Step(`^the configuration file contains:$`, theConfigurationFileContains)
+	s.Step(`^the controller is configured with the arguments "([^"]*)"$`, theControllerIsConfiguredWithTheArguments)
+	s.Step(`^the controller is configured without arguments$`, theControllerIsConfiguredWithoutArguments)
+	s.Step(`^the setting "([^"]*)" should be "([^"]*)" \((.*)\)$`, theSettingShouldBe)
+	s.Step(`^the configuration should be invalid because "(.*)"$`, theConfigurationShouldBeInvalidBecause)
+	s.Step(`^the controller should watch the k8s services but not the k8s ingresses$`, theControllerShouldWatchTheKsServicesButNotTheKsIngresses)
+	s.Step(`^the certificate of the k8s cluster should not be checked$`, theCertificateOfTheKsClusterShouldNotBeChecked)
+	s.Step(`^the controller runs in a pod with the service account token "([^"]*)"$`, theControllerRunsInAPodWithTheServiceAccountToken)
+	s.Step(`^the service account token is rotated to "([^"]*)"$`, theServiceAccountTokenIsRotatedTo)
+	s.Step(`^the token file of the k8s API contains "([^"]*)"$`, theTokenFileOfTheKsApiContains)
+	s.Step(`^the k8s client is configured$`, theKsClientIsConfigured)
+	s.Step(`^the k8s API should be "([^"]*)"$`, theKsApiShouldBe)
+	s.Step(`^the requests to the k8s API should have the bearer token "([^"]*)"$`, theRequestsToTheKsApiShouldHaveTheBearerToken)
+	s.Step(`^the k8s client should authenticate with the username "([^"]*)" and the password "([^"]*)"$`, theKsClientShouldAuthenticateWithTheUsernameAndThePassword)
+	s.Step(`^the certificate of the k8s API should be checked with the CA of the service account$`, theCertificateOfTheKsApiShouldBeCheckedWithTheCaOfTheServiceAccount)
+	s.Step(`^the k8s client configuration should fail because "(.*)"$`, theKsClientConfigurationShouldFailBecause)
 }
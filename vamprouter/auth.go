@@ -0,0 +1,84 @@
+package vamprouter
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Adds the credentials of the controller to the requests sent to the router.
+type Authenticator interface {
+	Authenticate(req *http.Request) error
+}
+
+type BasicAuth struct {
+	Username string
+	Password string
+}
+
+func (a *BasicAuth) Authenticate(req *http.Request) error {
+	req.SetBasicAuth(a.Username, a.Password)
+
+	return nil
+}
+
+type BearerToken struct {
+	Token string
+}
+
+func (a *BearerToken) Authenticate(req *http.Request) error {
+	req.Header.Set("Authorization", "Bearer "+a.Token)
+
+	return nil
+}
+
+// Basic authentication with the username and password read from files, so
+// that the rotated credentials are used without restarting the controller.
+type BasicAuthFiles struct {
+	Username *ReloadableFile
+	Password *ReloadableFile
+}
+
+func NewBasicAuthFiles(usernamePath string, passwordPath string) *BasicAuthFiles {
+	return &BasicAuthFiles{
+		Username: NewReloadableFile(usernamePath),
+		Password: NewReloadableFile(passwordPath),
+	}
+}
+
+func (a *BasicAuthFiles) Authenticate(req *http.Request) error {
+	username, _, err := a.Username.Load()
+	if err != nil {
+		return err
+	}
+
+	password, _, err := a.Password.Load()
+	if err != nil {
+		return err
+	}
+
+	req.SetBasicAuth(strings.TrimSpace(string(username)), strings.TrimSpace(string(password)))
+
+	return nil
+}
+
+// Bearer token read from a file, such as the token of a service account.
+type BearerTokenFile struct {
+	Token *ReloadableFile
+}
+
+func NewBearerTokenFile(path string) *BearerTokenFile {
+	return &BearerTokenFile{
+		Token: NewReloadableFile(path),
+	}
+}
+
+func (a *BearerTokenFile) Authenticate(req *http.Request) error {
+	token, _, err := a.Token.Load()
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+
+	return nil
+}
@@ -0,0 +1,159 @@
+package vamprouter
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// Returned without reaching the router while the circuit is open.
+var ErrCircuitOpen = errors.New("The circuit to the Vamp Router is open")
+
+type CircuitState int
+
+const (
+	// The requests are sent to the router
+	CircuitClosed CircuitState = iota
+
+	// The router is failing, the requests are rejected
+	CircuitOpen
+
+	// A single request is sent to check if the router is back
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+
+	return "closed"
+}
+
+// Stops sending requests to a router that keeps failing. The circuit opens
+// after `FailureThreshold` consecutive failures and lets a request through
+// every `ResetTimeout` to check whether the router recovered.
+type CircuitBreaker struct {
+	FailureThreshold int
+	ResetTimeout     time.Duration
+
+	// Called, outside of any lock, every time the state of the circuit changes
+	OnStateChange func(from CircuitState, to CircuitState)
+
+	mutex    sync.Mutex
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	probing  bool
+
+	// Clock of the breaker, replaced by the tests
+	now func() time.Time
+}
+
+func NewCircuitBreaker(failureThreshold int, resetTimeout time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		FailureThreshold: failureThreshold,
+		ResetTimeout:     resetTimeout,
+	}
+}
+
+func (b *CircuitBreaker) State() CircuitState {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.state
+}
+
+// Number of consecutive failures since the last success.
+func (b *CircuitBreaker) Failures() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.failures
+}
+
+// Returns `ErrCircuitOpen` when the request must not be sent.
+func (b *CircuitBreaker) Allow() error {
+	b.mutex.Lock()
+	from := b.state
+
+	switch b.state {
+	case CircuitOpen:
+		if b.clock().Sub(b.openedAt) < b.ResetTimeout {
+			b.mutex.Unlock()
+
+			return ErrCircuitOpen
+		}
+
+		b.state = CircuitHalfOpen
+		b.probing = true
+	case CircuitHalfOpen:
+		if b.probing {
+			b.mutex.Unlock()
+
+			return ErrCircuitOpen
+		}
+
+		b.probing = true
+	}
+
+	to := b.state
+	b.mutex.Unlock()
+
+	b.notify(from, to)
+
+	return nil
+}
+
+func (b *CircuitBreaker) RecordSuccess() {
+	b.mutex.Lock()
+	from := b.state
+	b.state = CircuitClosed
+	b.failures = 0
+	b.probing = false
+	b.mutex.Unlock()
+
+	b.notify(from, CircuitClosed)
+}
+
+func (b *CircuitBreaker) RecordFailure() {
+	b.mutex.Lock()
+	from := b.state
+	b.failures++
+	b.probing = false
+
+	if b.state == CircuitHalfOpen || b.failures >= b.FailureThreshold {
+		b.state = CircuitOpen
+		b.openedAt = b.clock()
+	}
+
+	to := b.state
+	b.mutex.Unlock()
+
+	b.notify(from, to)
+}
+
+// Ends a request that tells nothing about the health of the router, such as
+// a request cancelled by its caller.
+func (b *CircuitBreaker) RecordIgnored() {
+	b.mutex.Lock()
+	b.probing = false
+	b.mutex.Unlock()
+}
+
+func (b *CircuitBreaker) clock() time.Time {
+	if b.now != nil {
+		return b.now()
+	}
+
+	return time.Now()
+}
+
+func (b *CircuitBreaker) notify(from CircuitState, to CircuitState) {
+	if from != to && b.OnStateChange != nil {
+		b.OnStateChange(from, to)
+	}
+}
@@ -2,19 +2,24 @@ package vamprouter
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"io"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"net/http/httputil"
 	"os"
 	"reflect"
 	"strings"
+	"time"
 )
 
 type Interface interface {
 	RouteRepository
+	ServiceRepository
+	FilterRepository
 }
 
 type Client struct {
@@ -26,26 +31,60 @@ type Client struct {
 
 	// Debug mode to dump requests
 	Debug bool
+
+	// Deadline of each attempt of a request, `DefaultTimeout` when zero
+	Timeout time.Duration
+
+	// Retries of the idempotent requests, `DefaultBackoff` when nil
+	Backoff *Backoff
+
+	// Optional circuit breaker guarding the router
+	CircuitBreaker *CircuitBreaker
+
+	// Optional credentials of the controller on the router API
+	Authenticator Authenticator
+
+	lastResponse lastResponse
 }
 
 func (c *Client) Get(v interface{}, path string) error {
-	return c.APIReq(v, "GET", path, nil)
+	return c.GetContext(context.Background(), v, path)
 }
 
 func (c *Client) Patch(v interface{}, path string, body interface{}) error {
-	return c.APIReq(v, "PATCH", path, body)
+	return c.PatchContext(context.Background(), v, path, body)
 }
 
 func (c *Client) Post(v interface{}, path string, body interface{}) error {
-	return c.APIReq(v, "POST", path, body)
+	return c.PostContext(context.Background(), v, path, body)
 }
 
 func (c *Client) Put(v interface{}, path string, body interface{}) error {
-	return c.APIReq(v, "PUT", path, body)
+	return c.PutContext(context.Background(), v, path, body)
 }
 
 func (c *Client) Delete(path string) error {
-	return c.APIReq(nil, "DELETE", path, nil)
+	return c.DeleteContext(context.Background(), path)
+}
+
+func (c *Client) GetContext(ctx context.Context, v interface{}, path string) error {
+	return c.APIReqContext(ctx, v, "GET", path, nil)
+}
+
+func (c *Client) PatchContext(ctx context.Context, v interface{}, path string, body interface{}) error {
+	return c.APIReqContext(ctx, v, "PATCH", path, body)
+}
+
+func (c *Client) PostContext(ctx context.Context, v interface{}, path string, body interface{}) error {
+	return c.APIReqContext(ctx, v, "POST", path, body)
+}
+
+func (c *Client) PutContext(ctx context.Context, v interface{}, path string, body interface{}) error {
+	return c.APIReqContext(ctx, v, "PUT", path, body)
+}
+
+func (c *Client) DeleteContext(ctx context.Context, path string) error {
+	return c.APIReqContext(ctx, nil, "DELETE", path, nil)
 }
 
 // Generates an HTTP request for but does not perform the request.
@@ -94,6 +133,12 @@ func (c *Client) NewRequest(method, path string, body interface{}) (*http.Reques
 		req.Header.Set("Content-Type", ctype)
 	}
 
+	if c.Authenticator != nil {
+		if err = c.Authenticator.Authenticate(req); err != nil {
+			return nil, err
+		}
+	}
+
 	return req, nil
 }
 
@@ -102,11 +147,61 @@ func (c *Client) NewRequest(method, path string, body interface{}) (*http.Reques
 // encode the request body. As described in DoReq(), the type of
 // v determines how to handle the response body.
 func (c *Client) APIReq(v interface{}, meth, path string, body interface{}) error {
+	return c.APIReqContext(context.Background(), v, meth, path, body)
+}
+
+// Sends the request until it succeeds, with a deadline for each attempt. The
+// idempotent requests are retried with an exponential backoff when the router
+// cannot be reached or answers with a 5xx. A body given as an `io.Reader` can
+// only be sent once, so such requests are never retried.
+func (c *Client) APIReqContext(ctx context.Context, v interface{}, meth, path string, body interface{}) error {
+	_, bodyIsReader := body.(io.Reader)
+	backoff := c.backoff()
+
+	for retry := 0; ; retry++ {
+		err := c.attempt(ctx, v, meth, path, body)
+		if err == nil || ctx.Err() != nil || bodyIsReader || !IsRetryable(meth, err) || retry >= backoff.MaxRetries {
+			return err
+		}
+
+		log.Println("[error] Request", meth, path, "to the Vamp Router failed, retrying:", err)
+		if waitErr := backoff.Wait(ctx, retry); waitErr != nil {
+			return err
+		}
+	}
+}
+
+func (c *Client) attempt(ctx context.Context, v interface{}, meth, path string, body interface{}) error {
+	if c.CircuitBreaker != nil {
+		if err := c.CircuitBreaker.Allow(); err != nil {
+			return err
+		}
+	}
+
 	req, err := c.NewRequest(meth, path, body)
 	if err != nil {
+		if c.CircuitBreaker != nil {
+			c.CircuitBreaker.RecordIgnored()
+		}
+
 		return err
 	}
-	return c.DoReq(req, v)
+
+	attemptCtx, cancel := context.WithTimeout(ctx, c.timeout())
+	defer cancel()
+
+	err = c.DoReq(req.WithContext(attemptCtx), v)
+	if c.CircuitBreaker != nil {
+		if ctx.Err() != nil {
+			c.CircuitBreaker.RecordIgnored()
+		} else if IsRouterFailure(err) {
+			c.CircuitBreaker.RecordFailure()
+		} else {
+			c.CircuitBreaker.RecordSuccess()
+		}
+	}
+
+	return err
 }
 
 // Submits an HTTP request, checks its response, and deserializes
@@ -119,7 +214,19 @@ func (c *Client) APIReq(v interface{}, meth, path string, body interface{}) erro
 //
 func (c *Client) DoReq(req *http.Request, v interface{}) error {
 	if c.Debug {
-		dump, err := httputil.DumpRequestOut(req, true)
+		// The credentials are not dumped
+		dumpedReq := *req
+		dumpedReq.Header = http.Header{}
+		for name, values := range req.Header {
+			dumpedReq.Header[name] = values
+		}
+
+		if dumpedReq.Header.Get("Authorization") != "" {
+			dumpedReq.Header.Set("Authorization", "[redacted]")
+		}
+
+		dump, err := httputil.DumpRequestOut(&dumpedReq, true)
+		req.Body = dumpedReq.Body
 		if err != nil {
 			log.Println(err)
 		} else {
@@ -128,16 +235,15 @@ func (c *Client) DoReq(req *http.Request, v interface{}) error {
 		}
 	}
 
-	httpClient := c.HTTP
-	if httpClient == nil {
-		httpClient = http.DefaultClient
-	}
-
-	res, err := httpClient.Do(req)
+	start := time.Now()
+	res, err := c.httpClient().Do(req)
 	if err != nil {
+		c.observeRequest(req.Method, 0, start)
+
 		return err
 	}
 	defer res.Body.Close()
+	defer c.observeRequest(req.Method, res.StatusCode, start)
 	if c.Debug {
 		dump, err := httputil.DumpResponse(res, true)
 		if err != nil {
@@ -164,6 +270,19 @@ func (c *Client) DoReq(req *http.Request, v interface{}) error {
 type Error struct {
 	error
 	Status string
+
+	// HTTP status code and body of the response
+	StatusCode int
+	Body       []byte
+}
+
+func NewError(statusCode int, status string, body []byte) Error {
+	return Error{
+		error:      errors.New(status),
+		Status:     status,
+		StatusCode: statusCode,
+		Body:       body,
+	}
 }
 
 type errorResp struct {
@@ -172,13 +291,42 @@ type errorResp struct {
 
 func checkResp(res *http.Response) error {
 	if res.StatusCode/100 != 2 { // 200, 201, 202, etc
-		var e errorResp
-		err := json.NewDecoder(res.Body).Decode(&e)
+		body, err := ioutil.ReadAll(res.Body)
 		if err != nil {
-			return errors.New("Unexpected error: " + res.Status)
+			return NewError(res.StatusCode, "Unexpected error: "+res.Status, nil)
+		}
+
+		var e errorResp
+		err = json.Unmarshal(body, &e)
+		if err != nil || e.Status == "" {
+			return NewError(res.StatusCode, "Unexpected error: "+res.Status, body)
 		}
-		return Error{error: errors.New(e.Status), Status: e.Status}
+		return NewError(res.StatusCode, e.Status, body)
 	}
 
 	return nil
 }
+
+// Returns the status code of the API error, 0 for the other errors.
+func StatusCode(err error) int {
+	switch e := err.(type) {
+	case Error:
+		return e.StatusCode
+	case *Error:
+		return e.StatusCode
+	}
+
+	return 0
+}
+
+func IsNotFound(err error) bool {
+	return StatusCode(err) == http.StatusNotFound
+}
+
+func IsConflict(err error) bool {
+	return StatusCode(err) == http.StatusConflict
+}
+
+func IsServerError(err error) bool {
+	return StatusCode(err)/100 == 5
+}
@@ -0,0 +1,55 @@
+package vamprouter
+
+import (
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// A file, such as a key of a mounted Kubernetes Secret, that is read again
+// when it changes on the disk.
+type ReloadableFile struct {
+	Path string
+
+	mutex   sync.Mutex
+	content []byte
+	modTime time.Time
+	size    int64
+	loaded  bool
+}
+
+func NewReloadableFile(path string) *ReloadableFile {
+	return &ReloadableFile{
+		Path: path,
+	}
+}
+
+// Returns the content of the file, and whether it was read again since the
+// previous call.
+func (f *ReloadableFile) Load() ([]byte, bool, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	// Follows the symbolic links through which Kubernetes swaps the Secrets
+	info, err := os.Stat(f.Path)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if f.loaded && info.ModTime().Equal(f.modTime) && info.Size() == f.size {
+		return f.content, false, nil
+	}
+
+	content, err := ioutil.ReadFile(f.Path)
+	if err != nil {
+		return nil, false, err
+	}
+
+	f.content = content
+	f.modTime = info.ModTime()
+	f.size = info.Size()
+	f.loaded = true
+
+	return content, true, nil
+}
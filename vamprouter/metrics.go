@@ -0,0 +1,72 @@
+package vamprouter
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	RequestsCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "k8svamprouter_vamp_api_requests_total",
+			Help: "Requests sent to the Vamp Router API, by method and status. The status is `error` when the router could not be reached.",
+		},
+		[]string{"method", "status"},
+	)
+
+	RequestsDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name: "k8svamprouter_vamp_api_request_duration_seconds",
+			Help: "Duration of the requests sent to the Vamp Router API, by method and status.",
+		},
+		[]string{"method", "status"},
+	)
+)
+
+// Registers the metrics of the requests sent by the clients.
+func Register(registerer prometheus.Registerer) error {
+	for _, collector := range []prometheus.Collector{RequestsCount, RequestsDuration} {
+		err := registerer.Register(collector)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// When the router last answered a request of the client without failing.
+type lastResponse struct {
+	mutex sync.Mutex
+	at    time.Time
+}
+
+// Records a request that got the response of the given status code, 0 when
+// the router could not be reached.
+func (c *Client) observeRequest(method string, statusCode int, start time.Time) {
+	status := "error"
+	if statusCode != 0 {
+		status = strconv.Itoa(statusCode)
+	}
+
+	RequestsCount.WithLabelValues(method, status).Inc()
+	RequestsDuration.WithLabelValues(method, status).Observe(time.Since(start).Seconds())
+
+	if statusCode != 0 && statusCode < 500 {
+		c.lastResponse.mutex.Lock()
+		c.lastResponse.at = time.Now()
+		c.lastResponse.mutex.Unlock()
+	}
+}
+
+// Returns when the router last answered a request of the client without
+// failing, zero when it never did.
+func (c *Client) LastResponseTime() time.Time {
+	c.lastResponse.mutex.Lock()
+	defer c.lastResponse.mutex.Unlock()
+
+	return c.lastResponse.at
+}
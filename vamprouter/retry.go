@@ -0,0 +1,108 @@
+package vamprouter
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+const (
+	DefaultTimeout    = 10 * time.Second
+	DefaultMaxRetries = 3
+)
+
+// Exponential backoff between the attempts of a request.
+type Backoff struct {
+	// Delay before the first retry, doubled after every attempt
+	InitialInterval time.Duration
+
+	// Upper bound of the delay
+	MaxInterval time.Duration
+
+	// Number of retries after the first attempt
+	MaxRetries int
+}
+
+var DefaultBackoff = Backoff{
+	InitialInterval: 100 * time.Millisecond,
+	MaxInterval:     5 * time.Second,
+	MaxRetries:      DefaultMaxRetries,
+}
+
+// Returns the delay before the given retry, starting at 0.
+func (b Backoff) Interval(retry int) time.Duration {
+	interval := b.InitialInterval
+	for i := 0; i < retry && interval < b.MaxInterval; i++ {
+		interval *= 2
+	}
+
+	if b.MaxInterval > 0 && interval > b.MaxInterval {
+		interval = b.MaxInterval
+	}
+
+	return interval
+}
+
+// Waits for the delay of the retry, unless the context is done first.
+func (b Backoff) Wait(ctx context.Context, retry int) error {
+	timer := time.NewTimer(b.Interval(retry))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Sending again these requests has the same effect on the router as sending
+// them once.
+func IsIdempotent(method string) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS", "PUT", "DELETE":
+		return true
+	}
+
+	return false
+}
+
+// The router is failing when it cannot be reached or answers with a 5xx.
+// The other API errors, such as a 404, are answers to the request itself.
+func IsRouterFailure(err error) bool {
+	if err == nil || err == ErrCircuitOpen {
+		return false
+	} else if StatusCode(err) != 0 {
+		return IsServerError(err)
+	}
+
+	return true
+}
+
+func IsRetryable(method string, err error) bool {
+	return IsIdempotent(method) && IsRouterFailure(err)
+}
+
+func (c *Client) timeout() time.Duration {
+	if c.Timeout != 0 {
+		return c.Timeout
+	}
+
+	return DefaultTimeout
+}
+
+func (c *Client) backoff() Backoff {
+	if c.Backoff != nil {
+		return *c.Backoff
+	}
+
+	return DefaultBackoff
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTP != nil {
+		return c.HTTP
+	}
+
+	return http.DefaultClient
+}
@@ -1,9 +1,29 @@
 package vamprouter
 
+import (
+	"context"
+)
+
 type RouteRepository interface {
+	ListRoutes() ([]Route, error)
 	GetRoute(name string) (*Route, error)
 	UpdateRoute(route *Route) (*Route, error)
 	CreateRoute(route *Route) (*Route, error)
+	DeleteRoute(name string) error
+}
+
+// Manages the services of a route without sending the whole route
+type ServiceRepository interface {
+	CreateService(routeName string, service *Service) (*Service, error)
+	UpdateService(routeName string, service *Service) (*Service, error)
+	DeleteService(routeName string, serviceName string) error
+}
+
+// Manages the filters of a route without sending the whole route
+type FilterRepository interface {
+	CreateFilter(routeName string, filter *Filter) (*Filter, error)
+	UpdateFilter(routeName string, filter *Filter) (*Filter, error)
+	DeleteFilter(routeName string, filterName string) error
 }
 
 type Filter struct {
@@ -46,20 +66,106 @@ type Route struct {
 	Services []Service `json:"services"`
 }
 
+func (c *Client) ListRoutes() ([]Route, error) {
+	return c.ListRoutesContext(context.Background())
+}
+
+func (c *Client) ListRoutesContext(ctx context.Context) ([]Route, error) {
+	var routes []Route
+	err := c.GetContext(ctx, &routes, "/v1/routes")
+	if err != nil {
+		return nil, err
+	}
+
+	return routes, nil
+}
+
 // Get a route by its name
 //
 //
 func (c *Client) GetRoute(name string) (*Route, error) {
+	return c.GetRouteContext(context.Background(), name)
+}
+
+func (c *Client) GetRouteContext(ctx context.Context, name string) (*Route, error) {
 	var route Route
-	return &route, c.Get(&route, "/v1/routes/"+name)
+	return &route, c.GetContext(ctx, &route, "/v1/routes/"+name)
 }
 
 func (c *Client) UpdateRoute(route *Route) (*Route, error) {
+	return c.UpdateRouteContext(context.Background(), route)
+}
+
+func (c *Client) UpdateRouteContext(ctx context.Context, route *Route) (*Route, error) {
 	var resp errorResp
-	return route, c.Put(&resp, "/v1/routes/"+route.Name, route)
+	return route, c.PutContext(ctx, &resp, "/v1/routes/"+route.Name, route)
 }
 
 func (c *Client) CreateRoute(route *Route) (*Route, error) {
+	return c.CreateRouteContext(context.Background(), route)
+}
+
+func (c *Client) CreateRouteContext(ctx context.Context, route *Route) (*Route, error) {
 	var resp errorResp
-	return route, c.Post(&resp, "/v1/routes", route)
+	return route, c.PostContext(ctx, &resp, "/v1/routes", route)
+}
+
+func (c *Client) DeleteRoute(name string) error {
+	return c.DeleteRouteContext(context.Background(), name)
+}
+
+func (c *Client) DeleteRouteContext(ctx context.Context, name string) error {
+	return c.DeleteContext(ctx, "/v1/routes/"+name)
+}
+
+func (c *Client) CreateService(routeName string, service *Service) (*Service, error) {
+	return c.CreateServiceContext(context.Background(), routeName, service)
+}
+
+func (c *Client) CreateServiceContext(ctx context.Context, routeName string, service *Service) (*Service, error) {
+	var resp errorResp
+	return service, c.PostContext(ctx, &resp, "/v1/routes/"+routeName+"/services", service)
+}
+
+func (c *Client) UpdateService(routeName string, service *Service) (*Service, error) {
+	return c.UpdateServiceContext(context.Background(), routeName, service)
+}
+
+func (c *Client) UpdateServiceContext(ctx context.Context, routeName string, service *Service) (*Service, error) {
+	var resp errorResp
+	return service, c.PutContext(ctx, &resp, "/v1/routes/"+routeName+"/services/"+service.Name, service)
+}
+
+func (c *Client) DeleteService(routeName string, serviceName string) error {
+	return c.DeleteServiceContext(context.Background(), routeName, serviceName)
+}
+
+func (c *Client) DeleteServiceContext(ctx context.Context, routeName string, serviceName string) error {
+	return c.DeleteContext(ctx, "/v1/routes/"+routeName+"/services/"+serviceName)
+}
+
+func (c *Client) CreateFilter(routeName string, filter *Filter) (*Filter, error) {
+	return c.CreateFilterContext(context.Background(), routeName, filter)
+}
+
+func (c *Client) CreateFilterContext(ctx context.Context, routeName string, filter *Filter) (*Filter, error) {
+	var resp errorResp
+	return filter, c.PostContext(ctx, &resp, "/v1/routes/"+routeName+"/filters", filter)
+}
+
+func (c *Client) UpdateFilter(routeName string, filter *Filter) (*Filter, error) {
+	return c.UpdateFilterContext(context.Background(), routeName, filter)
+}
+
+func (c *Client) UpdateFilterContext(ctx context.Context, routeName string, filter *Filter) (*Filter, error) {
+	var resp errorResp
+	return filter, c.PutContext(ctx, &resp, "/v1/routes/"+routeName+"/filters/"+filter.Name, filter)
+}
+
+func (c *Client) DeleteFilter(routeName string, filterName string) error {
+	return c.DeleteFilterContext(context.Background(), routeName, filterName)
+}
+
+func (c *Client) DeleteFilterContext(ctx context.Context, routeName string, filterName string) error {
+	return c.DeleteContext(ctx, "/v1/routes/"+routeName+"/filters/"+filterName)
 }
@@ -0,0 +1,113 @@
+package vamprouter
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// Transport of the HTTPS requests to a router whose certificate is signed by
+// a custom authority, optionally authenticating the controller with a client
+// certificate. The files are checked before every request and the connections
+// are made with the new ones once they changed.
+type TLSTransport struct {
+	// PEM bundle of the authorities trusted to sign the router certificate,
+	// the system ones are used when nil
+	CA *ReloadableFile
+
+	// PEM client certificate and key, for mutual TLS
+	Certificate *ReloadableFile
+	Key         *ReloadableFile
+
+	mutex     sync.Mutex
+	transport *http.Transport
+}
+
+// Empty paths are ignored.
+func NewTLSTransport(caPath string, certificatePath string, keyPath string) (*TLSTransport, error) {
+	transport := &TLSTransport{}
+	if caPath != "" {
+		transport.CA = NewReloadableFile(caPath)
+	}
+
+	if certificatePath != "" || keyPath != "" {
+		if certificatePath == "" || keyPath == "" {
+			return nil, fmt.Errorf("The client certificate and its key must be given together")
+		}
+
+		transport.Certificate = NewReloadableFile(certificatePath)
+		transport.Key = NewReloadableFile(keyPath)
+	}
+
+	_, err := transport.GetTransport()
+
+	return transport, err
+}
+
+func (t *TLSTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	transport, err := t.GetTransport()
+	if err != nil {
+		return nil, err
+	}
+
+	return transport.RoundTrip(req)
+}
+
+// Returns the transport for the current files, building a new one when they
+// changed.
+func (t *TLSTransport) GetTransport() (*http.Transport, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	files := []*ReloadableFile{t.CA, t.Certificate, t.Key}
+	contents := make([][]byte, len(files))
+	reloaded := t.transport == nil
+
+	for index, file := range files {
+		if file == nil {
+			continue
+		}
+
+		content, fileReloaded, err := file.Load()
+		if err != nil {
+			return nil, err
+		}
+
+		contents[index] = content
+		reloaded = reloaded || fileReloaded
+	}
+
+	if !reloaded {
+		return t.transport, nil
+	}
+
+	config := &tls.Config{}
+	if t.CA != nil {
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(contents[0]) {
+			return nil, fmt.Errorf("No certificate found in the CA bundle %s", t.CA.Path)
+		}
+	}
+
+	if t.Certificate != nil {
+		keyPair, err := tls.X509KeyPair(contents[1], contents[2])
+		if err != nil {
+			return nil, err
+		}
+
+		config.Certificates = []tls.Certificate{keyPair}
+	}
+
+	if t.transport != nil {
+		t.transport.CloseIdleConnections()
+	}
+
+	t.transport = &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: config,
+	}
+
+	return t.transport, nil
+}
@@ -0,0 +1,285 @@
+package k8svamprouter
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/client-go/pkg/api/errors"
+	"k8s.io/client-go/pkg/watch"
+)
+
+type ObjectListWatcher interface {
+	ObjectLister
+
+	// Watches the changes made after the given resource version
+	Watch(resourceVersion string) (watch.Interface, error)
+}
+
+type ObjectEventHandler interface {
+	OnObjectUpdated(object KubernetesBackendObject)
+	OnObjectDeleted(object KubernetesBackendObject)
+}
+
+// The object watcher lists the objects and then watches them from the resource
+// version of the list. When the API server closes the watch, it watches again
+// from the last seen resource version. When this version is too old, it lists
+// the objects again and sends the deletion of the objects that disappeared in
+// the meantime.
+type ObjectWatcher struct {
+	// Name of the watched objects, used in the logs
+	Name string
+
+	ListWatcher ObjectListWatcher
+	Handler     ObjectEventHandler
+
+	// Time to wait before listing or watching again after an error
+	RetryPeriod time.Duration
+
+	// Duration after which a watcher still handling the same events, or still
+	// failing to watch, is considered dead. `DefaultLivenessTimeout` when zero
+	LivenessTimeout time.Duration
+
+	resourceVersion string
+	objects         map[string]KubernetesBackendObject
+
+	mutex        sync.Mutex
+	listed       bool
+	busySince    time.Time
+	failingSince time.Time
+}
+
+const DefaultLivenessTimeout = 5 * time.Minute
+
+// Watches the objects until the `stop` channel is closed.
+func (w *ObjectWatcher) Run(stop <-chan struct{}) {
+	log.Println("Watching Kubernetes", w.Name)
+
+	for {
+		err := w.ListAndWatch(stop)
+		if err != nil {
+			log.Println("[error] Unable to watch the", w.Name, err)
+		}
+
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		if err != nil {
+			select {
+			case <-stop:
+				return
+			case <-time.After(w.RetryPeriod):
+			}
+		}
+	}
+}
+
+// Lists the objects if needed and watches them until the watch is closed, an
+// error happens or the `stop` channel is closed.
+func (w *ObjectWatcher) ListAndWatch(stop <-chan struct{}) (err error) {
+	defer func() {
+		w.recordFailure(err)
+	}()
+
+	if w.resourceVersion == "" {
+		err := w.List()
+		if err != nil {
+			return err
+		}
+	}
+
+	watcher, err := w.ListWatcher.Watch(w.resourceVersion)
+	if err != nil {
+		if IsResourceVersionTooOld(err) {
+			w.resourceVersion = ""
+		}
+
+		return err
+	}
+
+	defer watcher.Stop()
+	w.recordFailure(nil)
+
+	for {
+		select {
+		case <-stop:
+			return nil
+		case event, ok := <-watcher.ResultChan():
+			if !ok {
+				log.Println("The watch of the", w.Name, "has been closed at the resource version", w.resourceVersion)
+
+				return nil
+			}
+
+			err = w.HandleEvent(event)
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (w *ObjectWatcher) List() error {
+	w.setBusy(true)
+	defer w.setBusy(false)
+
+	objects, resourceVersion, err := w.ListWatcher.List()
+	if err != nil {
+		return err
+	}
+
+	listedObjects := make(map[string]KubernetesBackendObject)
+	for _, object := range objects {
+		key, err := GetObjectKey(object)
+		if err != nil {
+			return err
+		}
+
+		listedObjects[key] = object
+	}
+
+	w.mutex.Lock()
+	previousObjects := w.objects
+	w.objects = listedObjects
+	w.mutex.Unlock()
+
+	for key, object := range previousObjects {
+		if _, found := listedObjects[key]; !found {
+			w.Handler.OnObjectDeleted(object)
+		}
+	}
+
+	for _, object := range objects {
+		w.Handler.OnObjectUpdated(object)
+	}
+
+	w.resourceVersion = resourceVersion
+
+	w.mutex.Lock()
+	w.listed = true
+	w.mutex.Unlock()
+
+	return nil
+}
+
+func (w *ObjectWatcher) HandleEvent(event watch.Event) error {
+	if event.Type == watch.Error {
+		err := apierrors.FromObject(event.Object)
+		if IsResourceVersionTooOld(err) {
+			log.Println("The resource version", w.resourceVersion, "of the", w.Name, "is too old, they will be listed again")
+			w.resourceVersion = ""
+		}
+
+		return err
+	}
+
+	metadata, err := GetObjectMeta(event.Object)
+	if err != nil {
+		return err
+	}
+
+	key, err := GetObjectKey(event.Object)
+	if err != nil {
+		return err
+	}
+
+	w.setBusy(true)
+	defer w.setBusy(false)
+
+	switch event.Type {
+	case watch.Added, watch.Modified:
+		w.mutex.Lock()
+		w.objects[key] = event.Object
+		w.mutex.Unlock()
+
+		w.Handler.OnObjectUpdated(event.Object)
+	case watch.Deleted:
+		w.mutex.Lock()
+		delete(w.objects, key)
+		w.mutex.Unlock()
+
+		w.Handler.OnObjectDeleted(event.Object)
+	default:
+		return fmt.Errorf("Unexpected event %s", event.Type)
+	}
+
+	w.resourceVersion = metadata.ResourceVersion
+
+	return nil
+}
+
+// Returns an error when the watcher has been handling the same events, or
+// failing to watch, for longer than its liveness timeout. A watcher waiting
+// for events is alive.
+func (w *ObjectWatcher) CheckLiveness() error {
+	timeout := w.LivenessTimeout
+	if timeout == 0 {
+		timeout = DefaultLivenessTimeout
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if !w.busySince.IsZero() && time.Since(w.busySince) > timeout {
+		return fmt.Errorf("The watcher of the %s has been handling the same events since %s", w.Name, w.busySince.Format(time.RFC3339))
+	} else if !w.failingSince.IsZero() && time.Since(w.failingSince) > timeout {
+		return fmt.Errorf("The watcher of the %s has been failing to watch since %s", w.Name, w.failingSince.Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+// Returns the last seen version of the watched object of the given key, so
+// that it does not have to be requested from the API.
+func (w *ObjectWatcher) GetObject(key string) (KubernetesBackendObject, bool) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	object, found := w.objects[key]
+
+	return object, found
+}
+
+// Whether the objects have been listed at least once.
+func (w *ObjectWatcher) HasListed() bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return w.listed
+}
+
+func (w *ObjectWatcher) setBusy(busy bool) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if !busy {
+		w.busySince = time.Time{}
+	} else if w.busySince.IsZero() {
+		w.busySince = time.Now()
+	}
+}
+
+// Records the start of a streak of failures, or its end when there is no error.
+func (w *ObjectWatcher) recordFailure(err error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if err == nil {
+		w.failingSince = time.Time{}
+	} else if w.failingSince.IsZero() {
+		w.failingSince = time.Now()
+	}
+}
+
+// The API server answers with a "410 Gone" when the requested resource version
+// has been compacted.
+func IsResourceVersionTooOld(err error) bool {
+	statusError, ok := err.(*apierrors.StatusError)
+
+	return ok && statusError.ErrStatus.Code == http.StatusGone
+}
@@ -0,0 +1,168 @@
+package k8svamprouter
+
+import (
+	"errors"
+	"fmt"
+
+	"k8s.io/client-go/pkg/api/unversioned"
+	"k8s.io/client-go/pkg/watch"
+)
+
+// Sends the given events and is then closed, as when the API server closes the
+// watch.
+type ClosedWatch struct {
+	result chan watch.Event
+}
+
+func NewClosedWatch(events []watch.Event) *ClosedWatch {
+	result := make(chan watch.Event, len(events))
+	for _, event := range events {
+		result <- event
+	}
+
+	close(result)
+
+	return &ClosedWatch{
+		result: result,
+	}
+}
+
+func (w *ClosedWatch) Stop() {
+}
+
+func (w *ClosedWatch) ResultChan() <-chan watch.Event {
+	return w.result
+}
+
+type InMemoryServiceListWatcher struct {
+	Repository      *InMemoryServiceRepository
+	ResourceVersion string
+
+	// Events sent by the next watch
+	PendingEvents []watch.Event
+
+	ListCalls               int
+	WatchedResourceVersions []string
+}
+
+func (lw *InMemoryServiceListWatcher) List() ([]KubernetesBackendObject, string, error) {
+	lw.ListCalls++
+
+	objects, _, err := lw.Repository.List()
+
+	return objects, lw.ResourceVersion, err
+}
+
+func (lw *InMemoryServiceListWatcher) Watch(resourceVersion string) (watch.Interface, error) {
+	lw.WatchedResourceVersions = append(lw.WatchedResourceVersions, resourceVersion)
+
+	events := lw.PendingEvents
+	lw.PendingEvents = []watch.Event{}
+
+	return NewClosedWatch(events), nil
+}
+
+var serviceListWatcher *InMemoryServiceListWatcher
+var serviceWatcher *ObjectWatcher
+var watchError error
+
+func NewServiceWatcher() {
+	serviceListWatcher = &InMemoryServiceListWatcher{
+		Repository: repository,
+	}
+
+	serviceWatcher = &ObjectWatcher{
+		Name:        "services",
+		ListWatcher: serviceListWatcher,
+		Handler:     routeManager,
+	}
+
+	watchError = nil
+}
+
+// FEATURES
+func theKsServicesAreListedAtTheResourceVersion(resourceVersion string) error {
+	serviceListWatcher.ResourceVersion = resourceVersion
+
+	return nil
+}
+
+func theKsServiceWillBeModifiedAtTheResourceVersion(serviceName string, resourceVersion string) error {
+	service := GetOrCreateService(repository, serviceName)
+	service.ObjectMeta.ResourceVersion = resourceVersion
+
+	serviceListWatcher.PendingEvents = append(serviceListWatcher.PendingEvents, watch.Event{
+		Type:   watch.Modified,
+		Object: service,
+	})
+
+	return nil
+}
+
+func theKsServiceWillBeDeletedAtTheResourceVersion(serviceName string, resourceVersion string) error {
+	service := GetOrCreateService(repository, serviceName)
+	service.ObjectMeta.ResourceVersion = resourceVersion
+	repository.Remove(serviceName)
+
+	serviceListWatcher.PendingEvents = append(serviceListWatcher.PendingEvents, watch.Event{
+		Type:   watch.Deleted,
+		Object: service,
+	})
+
+	return nil
+}
+
+func theWatchOfTheKsServicesWillFailBecauseTheResourceVersionIsTooOld() error {
+	serviceListWatcher.PendingEvents = append(serviceListWatcher.PendingEvents, watch.Event{
+		Type: watch.Error,
+		Object: &unversioned.Status{
+			Status:  "Failure",
+			Message: "too old resource version",
+			Reason:  "Gone",
+			Code:    410,
+		},
+	})
+
+	return nil
+}
+
+func theKsServiceIsRemovedFromTheCluster(serviceName string) error {
+	repository.Remove(serviceName)
+
+	return nil
+}
+
+func theKsServicesAreWatched() error {
+	watchError = serviceWatcher.ListAndWatch(make(chan struct{}))
+
+	return nil
+}
+
+func theKsServicesShouldBeWatchedWithAnError() error {
+	if watchError == nil {
+		return errors.New("Expected the watch to fail")
+	}
+
+	return nil
+}
+
+func theKsServicesShouldHaveBeenListedTimes(times int) error {
+	if serviceListWatcher.ListCalls != times {
+		return errors.New(fmt.Sprintf("Expected %d listings, found %d", times, serviceListWatcher.ListCalls))
+	}
+
+	return nil
+}
+
+func theKsServicesShouldHaveBeenWatchedFromTheResourceVersion(resourceVersion string) error {
+	watchedVersions := serviceListWatcher.WatchedResourceVersions
+	if len(watchedVersions) == 0 {
+		return errors.New("The services were not watched")
+	}
+
+	if lastVersion := watchedVersions[len(watchedVersions)-1]; lastVersion != resourceVersion {
+		return errors.New(fmt.Sprintf("Expected to watch from %s, but watched from %s", resourceVersion, lastVersion))
+	}
+
+	return nil
+}
@@ -0,0 +1,322 @@
+package k8svamprouter
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/sroze/kubernetes-vamp-router/vamprouter"
+)
+
+// Vamp only applies the weights of the services to the requests matching no
+// filter of their route. The hosts shared by weighted backends are therefore
+// routed from the HTTP route to a dedicated route, listening on a port of the
+// router, whose only services are these backends.
+const WeightedRouteAddress = "127.0.0.1"
+
+func GetWeightedRouteName(host string) string {
+	return GetDNSIdentifier("weighted-" + host)
+}
+
+// Returns the port of the weighted route, keeping the one of the existing
+// route when possible.
+func (rm *VampRouteManager) GetWeightedRoutePort(routeName string) (int, error) {
+	if rm.TcpPortAllocator == nil {
+		return 0, fmt.Errorf("No port range is configured for the weighted routes")
+	}
+
+	preferredPort := 0
+	route, err := rm.RouterClient.GetRoute(routeName)
+	if err == nil {
+		preferredPort = route.Port
+	} else if !vamprouter.IsNotFound(err) {
+		return 0, err
+	}
+
+	return rm.TcpPortAllocator.Allocate(routeName, preferredPort)
+}
+
+// Reserves the ports of the existing weighted routes, so that they are not
+// given to the TCP routes of the services routed before them.
+func (rm *VampRouteManager) ReserveWeightedRoutePorts(routes []vamprouter.Route) {
+	if rm.TcpPortAllocator == nil {
+		return
+	}
+
+	for _, route := range routes {
+		if IsWeightedRouteName(route.Name) && !rm.TcpPortAllocator.Reserve(route.Name, route.Port) {
+			log.Println("[error] The port", route.Port, "of the weighted route", route.Name, "is not available")
+		}
+	}
+}
+
+// The backend of the HTTP route sending the requests of the host to its
+// weighted route.
+func (rm *VampRouteManager) GetWeightedRouteBackend(host string) (Backend, error) {
+	routeName := GetWeightedRouteName(host)
+
+	port, err := rm.GetWeightedRoutePort(routeName)
+	if err != nil {
+		return Backend{}, err
+	}
+
+	return Backend{
+		Name:    routeName,
+		Address: WeightedRouteAddress,
+		Port:    port,
+	}, nil
+}
+
+func IsWeightedRouteName(routeName string) bool {
+	return strings.HasPrefix(routeName, "weighted-")
+}
+
+// Converges the backends of the object in the weighted routes: they are added
+// to the routes of the hosts it weights, and removed from the other weighted
+// routes, such as when a host has been dropped from its weights. Fails while
+// the weights of one of its hosts don't add up to 100, so that the host is
+// not routed to its weighted route before the other services are added.
+func (rm *VampRouteManager) UpdateWeightedRoutesIfNeeded(object KubernetesBackendObject) error {
+	rules, err := rm.ObjectRoutingResolver.GetRoutingRules(object)
+	if err != nil {
+		return err
+	}
+
+	owner, err := GetObjectOwner(object)
+	if err != nil {
+		return err
+	}
+
+	weightedRouteNames := []string{}
+	var weightsErr error
+	for _, rule := range rules {
+		if rule.Backend.Weight == 0 {
+			continue
+		}
+
+		route, err := rm.UpdateWeightedRouteIfNeeded(rule, owner)
+		if err != nil {
+			return err
+		}
+
+		if err = ValidateRouteWeights(route); err != nil && weightsErr == nil {
+			weightsErr = err
+		}
+
+		weightedRouteNames = append(weightedRouteNames, GetWeightedRouteName(rule.Host))
+	}
+
+	err = rm.RemoveObsoleteWeightedBackends(object, owner, weightedRouteNames)
+	if err != nil {
+		return err
+	}
+
+	return weightsErr
+}
+
+// Adds the backend of the rule to the weighted route of its host, unless it
+// would make the weights of the host add up to more than 100. The backend is
+// added while they add up to less, for the other services to complete them.
+func (rm *VampRouteManager) UpdateWeightedRouteIfNeeded(rule RoutingRule, owner Owner) (*vamprouter.Route, error) {
+	routeName := GetWeightedRouteName(rule.Host)
+
+	var weightedRoute *vamprouter.Route
+	err := rm.MutateRoute(routeName, func() error {
+		port, err := rm.GetWeightedRoutePort(routeName)
+		if err != nil {
+			return err
+		}
+
+		route, err := GetOrCreateRoute(rm.RouterClient, routeName, port, vamprouter.ProtocolHttp)
+		if err != nil {
+			return err
+		}
+
+		_, updated, err := rm.GetCreateOrUpdateBackend(route, rule.Backend)
+		if err != nil {
+			return err
+		}
+
+		if total := GetRouteWeightsTotal(route); total > 100 {
+			return fmt.Errorf("The weight %d of the backend %s would make the weights of the route %s add up to %d", rule.Backend.Weight, rule.Backend.Name, routeName, total)
+		}
+
+		rm.Ownership.Get(routeName).ClaimService(rule.Backend.Name, owner)
+
+		if route.Port != port {
+			route.Port = port
+			updated = true
+		}
+
+		if updated {
+			log.Println("Updated the weighted route", routeName, "with the backend", rule.Backend.Name, "weighting", rule.Backend.Weight)
+			_, err = rm.RouterClient.UpdateRoute(route)
+			if err != nil {
+				return err
+			}
+		}
+
+		weightedRoute = route
+
+		return nil
+	})
+
+	return weightedRoute, err
+}
+
+// Removes the backends of the object from the weighted routes. The routes
+// without backend left are deleted, with their backend in the HTTP route.
+func (rm *VampRouteManager) RemoveWeightedRoutesIfNeeded(object KubernetesBackendObject) error {
+	owner, err := GetObjectOwner(object)
+	if err != nil {
+		return err
+	}
+
+	return rm.RemoveObsoleteWeightedBackends(object, owner, []string{})
+}
+
+// Removes the backends of the object from the weighted routes it has backends
+// in, or whose host it weights, other than the given ones.
+func (rm *VampRouteManager) RemoveObsoleteWeightedBackends(object KubernetesBackendObject, owner Owner, weightedRouteNames []string) error {
+	backendNames, err := rm.ObjectRoutingResolver.GetBackendNames(object)
+	if err != nil {
+		return err
+	}
+
+	routeNames, err := rm.GetObjectWeightedRouteNames(object, owner)
+	if err != nil {
+		return err
+	}
+
+	for _, routeName := range routeNames {
+		if ContainsString(weightedRouteNames, routeName) {
+			continue
+		}
+
+		deleted, err := rm.RemoveBackendsFromWeightedRoute(routeName, backendNames)
+		if err != nil {
+			return err
+		} else if !deleted {
+			continue
+		}
+
+		// The HTTP route is updated once the lock of the weighted route is released
+		if rm.TcpPortAllocator != nil {
+			rm.TcpPortAllocator.Release(routeName)
+		}
+
+		err = rm.RemoveBackendsFromRoute(HttpRouteName, GetWeightedRouteOwner(routeName), []string{routeName})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// The weighted routes the object owns backends in, and the ones of the hosts
+// it currently weights, whose backends might have been added before their
+// ownership was recorded.
+func (rm *VampRouteManager) GetObjectWeightedRouteNames(object KubernetesBackendObject, owner Owner) ([]string, error) {
+	if rm.Ownership == nil {
+		return nil, ErrNoOwnershipRegistry
+	}
+
+	ownedRouteNames, err := rm.Ownership.GetOwnedRouteNames(owner)
+	if err != nil {
+		return nil, err
+	}
+
+	routeNames := []string{}
+	for _, routeName := range ownedRouteNames {
+		if IsWeightedRouteName(routeName) {
+			routeNames = append(routeNames, routeName)
+		}
+	}
+
+	rules, err := rm.ObjectRoutingResolver.GetRoutingRules(object)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, rule := range rules {
+		routeName := GetWeightedRouteName(rule.Host)
+		if rule.Backend.Weight > 0 && !ContainsString(routeNames, routeName) {
+			routeNames = append(routeNames, routeName)
+		}
+	}
+
+	return routeNames, nil
+}
+
+// Returns whether the weighted route has been deleted, having no backend left.
+func (rm *VampRouteManager) RemoveBackendsFromWeightedRoute(routeName string, backendNames []string) (bool, error) {
+	deleted := false
+	err := rm.MutateRoute(routeName, func() error {
+		route, err := rm.RouterClient.GetRoute(routeName)
+		if vamprouter.IsNotFound(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		ownership := rm.Ownership.Get(routeName)
+		removedBackendNames := []string{}
+		for _, backendName := range backendNames {
+			if RemoveServiceFromRoute(route, backendName) {
+				ownership.ReleaseService(backendName)
+				removedBackendNames = append(removedBackendNames, backendName)
+			}
+		}
+
+		if len(removedBackendNames) == 0 {
+			return nil
+		}
+
+		if len(route.Services) > 0 {
+			log.Println("Removed the backends", removedBackendNames, "from the weighted route", routeName)
+			_, err = rm.RouterClient.UpdateRoute(route)
+			if err != nil {
+				return err
+			}
+
+			LogInvalidRouteWeights(route)
+
+			return nil
+		}
+
+		log.Println("Removing the weighted route", routeName)
+		err = rm.RouterClient.DeleteRoute(routeName)
+		deleted = err == nil
+
+		return err
+	})
+
+	return deleted, err
+}
+
+// The weights of the services sharing a host are percentages of its traffic.
+func ValidateRouteWeights(route *vamprouter.Route) error {
+	if total := GetRouteWeightsTotal(route); total != 100 {
+		return fmt.Errorf("The weights of the route %s add up to %d instead of 100", route.Name, total)
+	}
+
+	return nil
+}
+
+// The weights left by a removed service can't be rejected, so this is only
+// reported.
+func LogInvalidRouteWeights(route *vamprouter.Route) {
+	if err := ValidateRouteWeights(route); err != nil {
+		log.Println("[error]", err)
+	}
+}
+
+func GetRouteWeightsTotal(route *vamprouter.Route) int {
+	total := 0
+	for _, service := range route.Services {
+		total += service.Weight
+	}
+
+	return total
+}
@@ -0,0 +1,246 @@
+package k8svamprouter
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// Routes or removes the routing of an object, returning the error to retry.
+type ObjectSyncer interface {
+	SyncObject(object KubernetesBackendObject) error
+	SyncDeletedObject(object KubernetesBackendObject) error
+}
+
+// The last known state of a queued object.
+type queuedObject struct {
+	object  KubernetesBackendObject
+	deleted bool
+}
+
+// The work queue receives the events of the watchers and gives the objects to
+// a bounded pool of workers. The objects are keyed by `namespace/name`: the
+// events received while an object waits to be processed collapse into its last
+// state, and an object is never processed by two workers at once. The objects
+// that fail to be synced are queued again after a delay growing with each
+// consecutive failure.
+type WorkQueue struct {
+	// Name of the queued objects, used in the logs
+	Name string
+
+	Syncer ObjectSyncer
+
+	// Delay before the first retry of an object, doubled after every failure
+	// up to `MaxRetryDelay`
+	InitialRetryDelay time.Duration
+	MaxRetryDelay     time.Duration
+
+	mutex      sync.Mutex
+	cond       *sync.Cond
+	keys       []string
+	objects    map[string]queuedObject
+	queued     map[string]bool
+	processing map[string]bool
+	failures   map[string]int
+	retries    int
+	paused     bool
+	shutDown   bool
+}
+
+func NewWorkQueue(name string, syncer ObjectSyncer) *WorkQueue {
+	q := &WorkQueue{
+		Name:              name,
+		Syncer:            syncer,
+		InitialRetryDelay: 1 * time.Second,
+		MaxRetryDelay:     5 * time.Minute,
+		objects:           make(map[string]queuedObject),
+		queued:            make(map[string]bool),
+		processing:        make(map[string]bool),
+		failures:          make(map[string]int),
+	}
+
+	q.cond = sync.NewCond(&q.mutex)
+
+	return q
+}
+
+// Implementation of `ObjectEventHandler`
+func (q *WorkQueue) OnObjectUpdated(object KubernetesBackendObject) {
+	q.Add(object, false)
+}
+
+func (q *WorkQueue) OnObjectDeleted(object KubernetesBackendObject) {
+	q.Add(object, true)
+}
+
+func (q *WorkQueue) Add(object KubernetesBackendObject, deleted bool) {
+	key, err := GetObjectKey(object)
+	if err != nil {
+		log.Println("[error] Unable to queue the object:", err)
+
+		return
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.objects[key] = queuedObject{
+		object:  object,
+		deleted: deleted,
+	}
+
+	q.enqueue(key)
+}
+
+// Queues the key unless it is already queued. The key of an object being
+// processed is queued once it is done.
+func (q *WorkQueue) enqueue(key string) {
+	if q.shutDown || q.queued[key] || q.processing[key] {
+		return
+	} else if _, found := q.objects[key]; !found {
+		return
+	}
+
+	q.queued[key] = true
+	q.keys = append(q.keys, key)
+	q.cond.Signal()
+}
+
+// Processes the objects with the given number of workers until the `stop`
+// channel is closed.
+func (q *WorkQueue) Run(workers int, stop <-chan struct{}) {
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for q.ProcessNextObject() {
+			}
+		}()
+	}
+
+	<-stop
+	q.ShutDown()
+	wg.Wait()
+}
+
+func (q *WorkQueue) ShutDown() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.shutDown = true
+	q.cond.Broadcast()
+}
+
+// Keeps queueing the objects without syncing them, until resumed. The objects
+// being synced are not interrupted.
+func (q *WorkQueue) Pause() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.paused = true
+}
+
+func (q *WorkQueue) Resume() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.paused = false
+	q.cond.Broadcast()
+}
+
+// Waits for an object and syncs it. Returns false once the queue is shut down.
+func (q *WorkQueue) ProcessNextObject() bool {
+	q.mutex.Lock()
+	for (len(q.keys) == 0 || q.paused) && !q.shutDown {
+		q.cond.Wait()
+	}
+
+	if q.shutDown {
+		q.mutex.Unlock()
+
+		return false
+	}
+
+	key := q.keys[0]
+	q.keys = q.keys[1:]
+	delete(q.queued, key)
+
+	queued := q.objects[key]
+	delete(q.objects, key)
+	q.processing[key] = true
+	q.mutex.Unlock()
+
+	var err error
+	if queued.deleted {
+		err = q.Syncer.SyncDeletedObject(queued.object)
+	} else {
+		err = q.Syncer.SyncObject(queued.object)
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	delete(q.processing, key)
+	if err == nil {
+		delete(q.failures, key)
+		q.enqueue(key)
+
+		return true
+	}
+
+	q.failures[key]++
+	delay := q.GetRetryDelay(q.failures[key])
+	log.Println("[error] Unable to sync the", q.Name, key, "retrying in", delay, err)
+
+	// A newer state received in the meantime is synced right away
+	if _, found := q.objects[key]; found {
+		q.enqueue(key)
+
+		return true
+	}
+
+	q.objects[key] = queued
+	q.retries++
+	time.AfterFunc(delay, func() {
+		q.mutex.Lock()
+		defer q.mutex.Unlock()
+
+		q.retries--
+		q.enqueue(key)
+	})
+
+	return true
+}
+
+// Returns the delay before retrying an object that failed the given number of
+// consecutive times.
+func (q *WorkQueue) GetRetryDelay(failures int) time.Duration {
+	delay := q.InitialRetryDelay
+	for i := 1; i < failures && delay < q.MaxRetryDelay; i++ {
+		delay *= 2
+	}
+
+	if delay > q.MaxRetryDelay {
+		delay = q.MaxRetryDelay
+	}
+
+	return delay
+}
+
+// Number of consecutive failures of the object with the given key.
+func (q *WorkQueue) Failures(key string) int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	return q.failures[key]
+}
+
+// Whether there is nothing queued, being processed or waiting to be retried.
+func (q *WorkQueue) IsIdle() bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	return len(q.keys) == 0 && len(q.processing) == 0 && q.retries == 0
+}
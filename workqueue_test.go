@@ -0,0 +1,178 @@
+package k8svamprouter
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Counts the syncs, and the most syncs running at once.
+type RecordingObjectSyncer struct {
+	Syncer ObjectSyncer
+
+	mutex      sync.Mutex
+	Syncs      int
+	running    int
+	MaxRunning int
+}
+
+func (s *RecordingObjectSyncer) SyncObject(object KubernetesBackendObject) error {
+	s.start()
+	defer s.end()
+
+	return s.Syncer.SyncObject(object)
+}
+
+func (s *RecordingObjectSyncer) SyncDeletedObject(object KubernetesBackendObject) error {
+	s.start()
+	defer s.end()
+
+	return s.Syncer.SyncDeletedObject(object)
+}
+
+func (s *RecordingObjectSyncer) start() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.Syncs++
+	s.running++
+	if s.running > s.MaxRunning {
+		s.MaxRunning = s.running
+	}
+}
+
+func (s *RecordingObjectSyncer) end() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.running--
+}
+
+var serviceWorkQueue *WorkQueue
+var serviceSyncer *RecordingObjectSyncer
+var stopServiceWorkQueue chan struct{}
+
+func NewServiceWorkQueue() {
+	serviceSyncer = &RecordingObjectSyncer{
+		Syncer: routeManager,
+	}
+
+	serviceWorkQueue = NewWorkQueue("service", serviceSyncer)
+	serviceWorkQueue.InitialRetryDelay = 5 * time.Millisecond
+	serviceWorkQueue.MaxRetryDelay = 50 * time.Millisecond
+	stopServiceWorkQueue = nil
+}
+
+func StopServiceWorkQueue() {
+	if stopServiceWorkQueue != nil {
+		close(stopServiceWorkQueue)
+		stopServiceWorkQueue = nil
+	}
+}
+
+// Polls the condition until it is met or a second has passed.
+func Eventually(condition func() bool) bool {
+	deadline := time.Now().Add(time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			return false
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	return true
+}
+
+func theKsServiceIsQueuedTimes(serviceName string, times int) error {
+	service, err := repository.Get(serviceName)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < times; i++ {
+		serviceWorkQueue.OnObjectUpdated(service)
+	}
+
+	return nil
+}
+
+func theDeletionOfTheKsServiceIsQueued(serviceName string) error {
+	service, err := repository.Get(serviceName)
+	if err != nil {
+		return err
+	}
+
+	serviceWorkQueue.OnObjectDeleted(service)
+
+	return nil
+}
+
+func theWorkQueueIsRunWithWorkers(workers int) error {
+	stopServiceWorkQueue = make(chan struct{})
+	go serviceWorkQueue.Run(workers, stopServiceWorkQueue)
+
+	return nil
+}
+
+func theWorkQueueShouldBecomeIdle() error {
+	if !Eventually(serviceWorkQueue.IsIdle) {
+		return errors.New("The work queue is still busy")
+	}
+
+	return nil
+}
+
+func theKsServiceShouldBeRetried(serviceName string) error {
+	service, err := repository.Get(serviceName)
+	if err != nil {
+		return err
+	}
+
+	key, err := GetObjectKey(service)
+	if err != nil {
+		return err
+	}
+
+	if !Eventually(func() bool { return serviceWorkQueue.Failures(key) > 1 }) {
+		return errors.New(fmt.Sprintf("The service %s has not been retried", serviceName))
+	}
+
+	return nil
+}
+
+func theKsObjectsShouldHaveBeenSyncedTimes(times int) error {
+	serviceSyncer.mutex.Lock()
+	defer serviceSyncer.mutex.Unlock()
+
+	if serviceSyncer.Syncs != times {
+		return errors.New(fmt.Sprintf("The objects have been synced %d times while expecting %d", serviceSyncer.Syncs, times))
+	}
+
+	return nil
+}
+
+func atMostKsObjectsShouldHaveBeenSyncedAtOnce(count int) error {
+	serviceSyncer.mutex.Lock()
+	defer serviceSyncer.mutex.Unlock()
+
+	if serviceSyncer.MaxRunning > count {
+		return errors.New(fmt.Sprintf("%d objects have been synced at once", serviceSyncer.MaxRunning))
+	}
+
+	return nil
+}
+
+func theRetryDelayAfterFailuresShouldBe(failures int, expectedDelay string) error {
+	delay, err := time.ParseDuration(expectedDelay)
+	if err != nil {
+		return err
+	}
+
+	if actualDelay := serviceWorkQueue.GetRetryDelay(failures); actualDelay != delay {
+		return errors.New(fmt.Sprintf("The retry delay is %s while expecting %s", actualDelay, delay))
+	}
+
+	return nil
+}